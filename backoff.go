@@ -0,0 +1,63 @@
+// Copyright 2022 The incite Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package incite
+
+import (
+	"math/rand"
+	"time"
+)
+
+func (b Backoff) enabled() bool {
+	return b.Base > 0
+}
+
+func (b Backoff) max() time.Duration {
+	if b.Max <= 0 {
+		return DefaultMaxBackoff
+	}
+	return b.Max
+}
+
+// delay returns the jittered delay before retrying after the nth
+// consecutive temporary error.
+func (b Backoff) delay(n int) time.Duration {
+	d := b.Base
+	max := b.max()
+	for i := 1; i < n && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// slowDown increases the worker-wide extra delay between requests after
+// a throttling error.
+func (w *worker) slowDown() {
+	b := w.m.Backoff
+	if w.penalty < b.Base {
+		w.penalty = b.Base
+	} else {
+		w.penalty *= 2
+	}
+	if max := b.max(); w.penalty > max {
+		w.penalty = max
+	}
+	w.m.logEvent(w.name, "slowing down after throttling, extra delay "+w.penalty.String())
+}
+
+// speedUp decreases the worker-wide extra delay between requests after
+// a request which was not throttled.
+func (w *worker) speedUp() {
+	if w.penalty == 0 {
+		return
+	}
+	w.penalty /= 2
+	if w.penalty < w.m.Backoff.Base/2 {
+		w.penalty = 0
+		w.m.logEvent(w.name, "back to full speed")
+	}
+}
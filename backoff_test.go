@@ -0,0 +1,110 @@
+// Copyright 2022 The incite Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package incite
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestBackoff_delay(t *testing.T) {
+	testCases := []struct {
+		name    string
+		backoff Backoff
+		n       int
+		max     time.Duration
+	}{
+		{"First", Backoff{Base: 100 * time.Millisecond}, 1, 100 * time.Millisecond},
+		{"Second", Backoff{Base: 100 * time.Millisecond}, 2, 200 * time.Millisecond},
+		{"Fourth", Backoff{Base: 100 * time.Millisecond}, 4, 800 * time.Millisecond},
+		{"Capped", Backoff{Base: 100 * time.Millisecond, Max: 300 * time.Millisecond}, 4, 300 * time.Millisecond},
+		{"Default Max", Backoff{Base: time.Second}, 100, DefaultMaxBackoff},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			for i := 0; i < 50; i++ {
+				d := testCase.backoff.delay(testCase.n)
+				assert.GreaterOrEqual(t, d, testCase.max/2)
+				assert.LessOrEqual(t, d, testCase.max)
+			}
+		})
+	}
+}
+
+func TestWorker_slowDown(t *testing.T) {
+	logger := newMockLogger(t)
+	logger.ExpectPrintf("incite: QueryManager(%s) %s %s", mock.Anything, "test", mock.Anything)
+	w := &worker{
+		m: &mgr{
+			Config: Config{
+				Logger:  logger,
+				Backoff: Backoff{Base: 100 * time.Millisecond, Max: 350 * time.Millisecond},
+			},
+		},
+		name: "test",
+	}
+
+	w.slowDown()
+	assert.Equal(t, 100*time.Millisecond, w.penalty)
+	w.slowDown()
+	assert.Equal(t, 200*time.Millisecond, w.penalty)
+	w.slowDown()
+	assert.Equal(t, 350*time.Millisecond, w.penalty)
+	w.speedUp()
+	assert.Equal(t, 175*time.Millisecond, w.penalty)
+	w.speedUp()
+	assert.Equal(t, 87500*time.Microsecond, w.penalty)
+	w.speedUp()
+	assert.Equal(t, time.Duration(0), w.penalty)
+	w.speedUp()
+	assert.Equal(t, time.Duration(0), w.penalty)
+	logger.AssertNumberOfCalls(t, "Printf", 4)
+}
+
+func TestWorker_handle_Backoff(t *testing.T) {
+	t.Run("Disabled", func(t *testing.T) {
+		w := &worker{m: &mgr{}}
+		c := &chunk{err: cwlErr("Throttling", "slow down")}
+
+		w.handle(manipulation{c, temporaryError})
+
+		assert.True(t, c.due.IsZero())
+		assert.Equal(t, time.Duration(0), w.penalty)
+		assert.Equal(t, 1, w.numChunks)
+	})
+
+	t.Run("Enabled", func(t *testing.T) {
+		logger := newMockLogger(t)
+		logger.ExpectPrintf("incite: QueryManager(%s) %s %s", mock.Anything, "test", mock.Anything).Maybe()
+		w := &worker{
+			m: &mgr{
+				Config: Config{
+					Logger:  logger,
+					Backoff: Backoff{Base: time.Second},
+				},
+			},
+			name: "test",
+		}
+		c := &chunk{err: cwlErr(cloudwatchlogs.ErrCodeServiceUnavailableException, "oops")}
+		before := time.Now()
+
+		w.handle(manipulation{c, temporaryError})
+
+		assert.False(t, c.due.Before(before.Add(500*time.Millisecond)))
+		assert.Equal(t, time.Duration(0), w.penalty, "not throttled, so no worker-wide penalty")
+
+		c.err = cwlErr("ThrottlingException", "slow down")
+		w.handle(manipulation{c, temporaryError})
+
+		assert.False(t, c.due.Before(before.Add(time.Second)))
+		assert.Equal(t, time.Second, w.penalty)
+		assert.Equal(t, 2, w.numChunks)
+	})
+}
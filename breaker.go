@@ -0,0 +1,212 @@
+// Copyright 2022 The incite Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package incite
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// A breaker implements the circuit breaker configured by the
+// CircuitBreaker field of Config. It is safe for concurrent use: it is
+// consulted by Query, the mgr loop, and the starter, and it is informed
+// of request results by the starter and poller.
+//
+// While closed, the breaker counts StartQuery and GetQueryResults
+// requests, and the failures among them, over a fixed window. When the
+// failure rate reaches the threshold, the breaker opens. While open,
+// new queries and chunks waiting to start fail fast. When the cooldown
+// has elapsed, the breaker goes half-open and lets one StartQuery
+// request through as a probe: if the probe succeeds the breaker
+// closes, and if it fails the breaker opens again. Chunks waiting to
+// start while the probe is in flight stay in the ready queue until the
+// probe's result is known.
+type breaker struct {
+	m           *mgr          // Owning mgr, for logging
+	rate        float64       // Failure rate at which the breaker opens
+	minRequests int           // Minimum requests in window before the breaker may open
+	window      time.Duration // Length of the counting window
+	cooldown    time.Duration // Time the breaker stays open before probing
+
+	lock     sync.Mutex
+	state    breakerState
+	since    time.Time // Start of counting window if closed, otherwise time opened
+	requests int       // Requests counted in the current window
+	failures int       // Failures counted in the current window
+	probing  bool      // True if the half-open probe request is in flight
+	cause    error     // Most recent failure which contributed to opening
+}
+
+func newBreaker(m *mgr) *breaker {
+	cfg := m.CircuitBreaker
+	if cfg.FailureRate <= 0 {
+		return nil
+	}
+	b := &breaker{
+		m:           m,
+		rate:        cfg.FailureRate,
+		minRequests: cfg.MinRequests,
+		window:      cfg.Window,
+		cooldown:    cfg.Cooldown,
+		since:       time.Now(),
+	}
+	if b.minRequests <= 0 {
+		b.minRequests = DefaultCircuitMinRequests
+	}
+	if b.window <= 0 {
+		b.window = DefaultCircuitWindow
+	}
+	if b.cooldown <= 0 {
+		b.cooldown = DefaultCircuitCooldown
+	}
+	return b
+}
+
+// check returns a *CircuitOpenError if the breaker is open and its
+// cooldown has not elapsed, and nil otherwise. A nil breaker is never
+// open.
+func (b *breaker) check() error {
+	if b == nil {
+		return nil
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.state == breakerOpen && time.Since(b.since) < b.cooldown {
+		return b.openErr()
+	}
+	return nil
+}
+
+// awaitingProbe returns true if the breaker is half-open and its probe
+// request is in flight. A nil breaker never probes.
+func (b *breaker) awaitingProbe() bool {
+	if b == nil {
+		return false
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.state == breakerHalfOpen && b.probing
+}
+
+// allow is called before each StartQuery request. It returns a
+// *CircuitOpenError if the request must not be made because the
+// breaker is open, or errProbeInFlight if the request must wait for
+// the result of the half-open probe. Otherwise, it returns nil, and
+// probe is true if the request is the half-open probe whose result
+// decides whether the breaker closes.
+func (b *breaker) allow() (probe bool, err error) {
+	if b == nil {
+		return false, nil
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.since) < b.cooldown {
+			return false, b.openErr()
+		}
+		b.state = breakerHalfOpen
+		b.m.logEvent("", "circuit breaker half-open, probing")
+		fallthrough
+	case breakerHalfOpen:
+		if b.probing {
+			return false, errProbeInFlight
+		}
+		b.probing = true
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// record informs the breaker of the result of a StartQuery or
+// GetQueryResults request. If the request was the half-open probe,
+// probe must be true.
+func (b *breaker) record(probe bool, err error) {
+	if b == nil {
+		return
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	counted, failure := classifyForBreaker(err)
+	now := time.Now()
+
+	if probe {
+		b.probing = false
+		switch {
+		case !counted:
+			// Inconclusive probe, so stay half-open and probe again.
+		case failure:
+			b.state = breakerOpen
+			b.since = now
+			b.cause = err
+			b.m.logEvent("", "circuit breaker probe failed, reopened: "+err.Error())
+		default:
+			b.state = breakerClosed
+			b.reset(now)
+			b.m.logEvent("", "circuit breaker closed")
+		}
+		return
+	}
+
+	if b.state != breakerClosed || !counted {
+		return
+	}
+	if now.Sub(b.since) >= b.window {
+		b.reset(now)
+	}
+	b.requests++
+	if !failure {
+		return
+	}
+	b.failures++
+	b.cause = err
+	if b.requests >= b.minRequests && float64(b.failures) >= b.rate*float64(b.requests) {
+		b.state = breakerOpen
+		b.since = now
+		b.m.logEvent("", "circuit breaker opened: "+err.Error())
+	}
+}
+
+func (b *breaker) reset(now time.Time) {
+	b.since = now
+	b.requests = 0
+	b.failures = 0
+}
+
+func (b *breaker) openErr() error {
+	return &CircuitOpenError{
+		Until: b.since.Add(b.cooldown),
+		Cause: b.cause,
+	}
+}
+
+// classifyForBreaker decides whether a request result counts towards
+// the breaker's failure rate and, if so, whether it is a failure.
+// Throttling and cancellation say nothing about the health of the
+// service, so they are not counted. Only errors which are likely to be
+// temporary, such as service unavailability and network failures, are
+// counted as failures: the remaining errors, such as invalid queries,
+// show that the service is responding.
+func classifyForBreaker(err error) (counted bool, failure bool) {
+	if err == nil {
+		return true, false
+	}
+	if isThrottled(err) || isLimitExceeded(err) ||
+		errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false, false
+	}
+	return true, isTemporary(err)
+}
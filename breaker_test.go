@@ -0,0 +1,329 @@
+// Copyright 2022 The incite Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package incite
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewBreaker(t *testing.T) {
+	t.Run("Disabled", func(t *testing.T) {
+		assert.Nil(t, newBreaker(&mgr{}))
+	})
+
+	t.Run("Defaults", func(t *testing.T) {
+		b := newBreaker(&mgr{Config: Config{CircuitBreaker: CircuitBreaker{FailureRate: 0.5}}})
+
+		require.NotNil(t, b)
+		assert.Equal(t, 0.5, b.rate)
+		assert.Equal(t, DefaultCircuitMinRequests, b.minRequests)
+		assert.Equal(t, DefaultCircuitWindow, b.window)
+		assert.Equal(t, DefaultCircuitCooldown, b.cooldown)
+		assert.Equal(t, breakerClosed, b.state)
+	})
+
+	t.Run("Explicit", func(t *testing.T) {
+		b := newBreaker(&mgr{Config: Config{CircuitBreaker: CircuitBreaker{
+			FailureRate: 1,
+			MinRequests: 3,
+			Window:      time.Second,
+			Cooldown:    time.Hour,
+		}}})
+
+		require.NotNil(t, b)
+		assert.Equal(t, 3, b.minRequests)
+		assert.Equal(t, time.Second, b.window)
+		assert.Equal(t, time.Hour, b.cooldown)
+	})
+}
+
+func TestBreaker(t *testing.T) {
+	unavailable := cwlErr(cloudwatchlogs.ErrCodeServiceUnavailableException, "down")
+
+	t.Run("Nil", func(t *testing.T) {
+		var b *breaker
+
+		assert.NoError(t, b.check())
+		probe, err := b.allow()
+		assert.False(t, probe)
+		assert.NoError(t, err)
+		b.record(false, unavailable)
+	})
+
+	t.Run("Stays Closed Below Rate", func(t *testing.T) {
+		b := newTestableBreaker(t, 0.5, 4, time.Hour)
+
+		b.record(false, nil)
+		b.record(false, unavailable)
+		b.record(false, nil)
+		b.record(false, cwlErr("ThrottlingException", "slow down"))
+		b.record(false, context.Canceled)
+		b.record(false, cwlErr(cloudwatchlogs.ErrCodeInvalidParameterException, "bad query"))
+
+		assert.Equal(t, breakerClosed, b.state)
+		assert.Equal(t, 4, b.requests)
+		assert.Equal(t, 1, b.failures)
+		assert.NoError(t, b.check())
+	})
+
+	t.Run("Success Does Not Open", func(t *testing.T) {
+		b := newTestableBreaker(t, 0.5, 4, time.Hour)
+
+		b.record(false, unavailable)
+		b.record(false, unavailable)
+		b.record(false, nil)
+		b.record(false, nil)
+
+		assert.Equal(t, breakerClosed, b.state)
+		assert.Equal(t, 4, b.requests)
+		assert.Equal(t, 2, b.failures)
+
+		b.record(false, unavailable)
+
+		assert.Equal(t, breakerOpen, b.state)
+		assert.Same(t, unavailable, b.cause)
+	})
+
+	t.Run("Window Resets", func(t *testing.T) {
+		b := newTestableBreaker(t, 0.5, 2, time.Hour)
+		b.window = time.Millisecond
+
+		b.record(false, unavailable)
+		time.Sleep(2 * time.Millisecond)
+		b.record(false, nil)
+
+		assert.Equal(t, breakerClosed, b.state)
+		assert.Equal(t, 1, b.requests)
+		assert.Equal(t, 0, b.failures)
+	})
+
+	t.Run("Opens, Probes, and Closes", func(t *testing.T) {
+		b := newTestableBreaker(t, 0.5, 2, 20*time.Millisecond)
+
+		b.record(false, nil)
+		b.record(false, unavailable)
+
+		assert.Equal(t, breakerOpen, b.state)
+		err := b.check()
+		var coe *CircuitOpenError
+		require.ErrorAs(t, err, &coe)
+		assert.Same(t, unavailable, coe.Cause)
+		assert.Same(t, unavailable, errors.Unwrap(err))
+		probe, err := b.allow()
+		assert.False(t, probe)
+		assert.ErrorAs(t, err, &coe)
+
+		time.Sleep(25 * time.Millisecond)
+
+		assert.NoError(t, b.check())
+		probe, err = b.allow()
+		assert.True(t, probe)
+		assert.NoError(t, err)
+		assert.Equal(t, breakerHalfOpen, b.state)
+		assert.True(t, b.awaitingProbe())
+		_, err = b.allow()
+		assert.Same(t, errProbeInFlight, err, "only one probe at a time")
+		b.record(false, nil)
+		assert.Equal(t, breakerHalfOpen, b.state, "non-probe result ignored")
+
+		b.record(true, nil)
+
+		assert.Equal(t, breakerClosed, b.state)
+		assert.Equal(t, 0, b.requests)
+		assert.False(t, b.awaitingProbe())
+		probe, err = b.allow()
+		assert.False(t, probe)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Failed Probe Reopens", func(t *testing.T) {
+		b := newTestableBreaker(t, 1, 1, time.Millisecond)
+		b.record(false, syscall.ECONNRESET)
+		time.Sleep(2 * time.Millisecond)
+		probe, err := b.allow()
+		require.True(t, probe)
+		require.NoError(t, err)
+
+		b.record(true, unavailable)
+
+		assert.Equal(t, breakerOpen, b.state)
+		assert.Same(t, unavailable, b.cause)
+	})
+
+	t.Run("Inconclusive Probe Allows Another", func(t *testing.T) {
+		b := newTestableBreaker(t, 1, 1, time.Millisecond)
+		b.record(false, syscall.ECONNRESET)
+		time.Sleep(2 * time.Millisecond)
+		probe, _ := b.allow()
+		require.True(t, probe)
+
+		b.record(true, cwlErr("ThrottlingException", "slow down"))
+
+		assert.Equal(t, breakerHalfOpen, b.state)
+		probe, err := b.allow()
+		assert.True(t, probe)
+		assert.NoError(t, err)
+	})
+}
+
+func TestQueryManager_CircuitBreaker(t *testing.T) {
+	t.Run("Bad Failure Rate", func(t *testing.T) {
+		assert.PanicsWithValue(t, badFailureRateMsg, func() {
+			NewQueryManager(Config{
+				Actions:        newMockActions(t),
+				CircuitBreaker: CircuitBreaker{FailureRate: 1.5},
+			})
+		})
+	})
+
+	t.Run("Opens and Fails Fast", func(t *testing.T) {
+		actions := newMockActions(t)
+		actions.
+			On("StartQueryWithContext", anyContext, mock.Anything).
+			Return(nil, cwlErr(cloudwatchlogs.ErrCodeServiceUnavailableException, "down")).
+			Times(2)
+		m := NewQueryManager(Config{
+			Actions:  actions,
+			Parallel: 1,
+			RPS:      lotsOfRPS,
+			RetryPolicy: RetryPolicyFunc(func(CloudWatchLogsAction, error, int) (bool, time.Duration) {
+				return true, 0
+			}),
+			CircuitBreaker: CircuitBreaker{
+				FailureRate: 1,
+				MinRequests: 2,
+				Cooldown:    time.Hour,
+			},
+		})
+		t.Cleanup(func() {
+			_ = m.Close()
+		})
+		var streams []Stream
+		for _, text := range []string{"first", "second"} {
+			s, err := m.Query(QuerySpec{
+				Text:   text,
+				Groups: []string{"grp"},
+				Start:  defaultStart,
+				End:    defaultEnd,
+			})
+			require.NoError(t, err)
+			streams = append(streams, s)
+		}
+
+		for _, s := range streams {
+			_, err := ReadAll(s)
+
+			var sqe *StartQueryError
+			require.ErrorAs(t, err, &sqe)
+			var coe *CircuitOpenError
+			assert.ErrorAs(t, err, &coe)
+		}
+		_, err := m.Query(QuerySpec{
+			Text:   "third",
+			Groups: []string{"grp"},
+			Start:  defaultStart,
+			End:    defaultEnd,
+		})
+		var coe *CircuitOpenError
+		assert.ErrorAs(t, err, &coe)
+		actions.AssertExpectations(t)
+	})
+
+	t.Run("Holds Chunks While Probing", func(t *testing.T) {
+		actions := newMockActions(t)
+		actions.
+			On("StartQueryWithContext", anyContext, startQueryInput("first", defaultStart, defaultEnd, DefaultLimit, "grp")).
+			Return(nil, cwlErr(cloudwatchlogs.ErrCodeServiceUnavailableException, "down")).
+			Once()
+		var probing sync.WaitGroup
+		probing.Add(1)
+		release := make(chan struct{})
+		actions.
+			On("StartQueryWithContext", anyContext, mock.Anything).
+			Run(func(_ mock.Arguments) {
+				probing.Done()
+				<-release
+			}).
+			Return(&cloudwatchlogs.StartQueryOutput{QueryId: sp("probe")}, nil).
+			Once()
+		actions.
+			On("StartQueryWithContext", anyContext, mock.Anything).
+			Return(&cloudwatchlogs.StartQueryOutput{QueryId: sp("held")}, nil).
+			Once()
+		for _, queryID := range []string{"probe", "held"} {
+			actions.
+				On("GetQueryResultsWithContext", anyContext, &cloudwatchlogs.GetQueryResultsInput{QueryId: sp(queryID)}).
+				Return(&cloudwatchlogs.GetQueryResultsOutput{Status: sp(cloudwatchlogs.QueryStatusComplete)}, nil).
+				Once()
+		}
+		m := NewQueryManager(Config{
+			Actions:     actions,
+			Parallel:    2,
+			RPS:         lotsOfRPS,
+			MaxInFlight: 2,
+			RetryPolicy: RetryPolicyFunc(func(CloudWatchLogsAction, error, int) (bool, time.Duration) {
+				return false, 0
+			}),
+			CircuitBreaker: CircuitBreaker{
+				FailureRate: 1,
+				MinRequests: 1,
+				Cooldown:    10 * time.Millisecond,
+			},
+		})
+		t.Cleanup(func() {
+			_ = m.Close()
+		})
+		query := func(text string) Stream {
+			s, err := m.Query(QuerySpec{
+				Text:   text,
+				Groups: []string{"grp"},
+				Start:  defaultStart,
+				End:    defaultEnd,
+			})
+			require.NoError(t, err)
+			return s
+		}
+		_, err := ReadAll(query("first"))
+		require.Error(t, err)
+		time.Sleep(20 * time.Millisecond)
+
+		second, third := query("second"), query("third")
+		probing.Wait()
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+
+		for _, s := range []Stream{second, third} {
+			_, err = ReadAll(s)
+			assert.NoError(t, err)
+		}
+		actions.AssertExpectations(t)
+	})
+}
+
+func newTestableBreaker(t *testing.T, rate float64, minRequests int, cooldown time.Duration) *breaker {
+	logger := newMockLogger(t)
+	logger.ExpectPrintf("incite: QueryManager(%s) %s", mock.Anything, mock.Anything).Maybe()
+	return newBreaker(&mgr{
+		Config: Config{
+			Logger: logger,
+			CircuitBreaker: CircuitBreaker{
+				FailureRate: rate,
+				MinRequests: minRequests,
+				Window:      time.Hour,
+				Cooldown:    cooldown,
+			},
+		},
+	})
+}
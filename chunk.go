@@ -29,7 +29,18 @@ type chunk struct {
 	err     error           // Chunk error
 	try     int             // Local attempt number within worker loop
 	tmp     int             // Local number of temporary errors within worker loop
+	delay   time.Duration   // Retry delay requested by the RetryPolicy after a temporary error
+	due     time.Time       // Earliest time the worker holding the chunk may manipulate it again
+	hold    time.Time       // Earliest time the next worker to receive the chunk may manipulate it
+	since   time.Time       // Time the chunk's Insights query was started or attached
+	expiry  time.Time       // Time the chunk's Insights query must be stopped, zero if none
 	restart int             // Number of times a chunk is restarted after CW Insights "Failed" status
+	counted bool            // True once the chunk's range has been counted in RangeStarted
+	limited int             // Number of times a chunk failed to start due to the concurrency limit
+	window  int             // Adaptive parallelism window in effect when the chunk was sent to the starter
+	preempt int32           // Set atomically by mgr loop to ask the poller to give back the chunk
+	results []Result        // Completed results awaiting hydration, nil if none
+	next    int             // Index of next result in results to hydrate
 }
 
 // A state contains the current status of a chunk. This is used by the
@@ -52,6 +63,12 @@ const (
 	polling
 	// The complete state indicates that the chunk has finished polling.
 	complete
+	// The hydrating state indicates that the chunk has been sent to the
+	// hydrator but its results are not yet all hydrated.
+	hydrating
+	// The hydrated state indicates that the chunk's results have been
+	// hydrated and sent to the stream.
+	hydrated
 	// The stopping state indicates that the chunk has been sent to the
 	// stopper but is not yet stopped.
 	stopping
@@ -64,9 +81,13 @@ func (c *chunk) duration() time.Duration {
 	return c.end.Sub(c.start)
 }
 
+// started counts a chunk whose start was attempted. A chunk's range is
+// only counted in RangeStarted the first time, so a chunk restarted
+// after being preempted, paused, or timed out is not counted twice.
 func (c *chunk) started() {
-	if c.gen == 0 {
+	if c.gen == 0 && !c.counted {
 		c.RangeStarted += c.duration()
+		c.counted = true
 	}
 	if c.err != nil {
 		c.RangeFailed += c.duration()
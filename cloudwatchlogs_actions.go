@@ -37,6 +37,62 @@ type CloudWatchLogsActions interface {
 	GetQueryResultsWithContext(context.Context, *cloudwatchlogs.GetQueryResultsInput, ...request.Option) (*cloudwatchlogs.GetQueryResultsOutput, error)
 }
 
+// LogGroupDescriber is an optional extension of CloudWatchLogsActions
+// which provides the CloudWatch Logs DescribeLogGroups act. If the
+// Actions field of the Config passed to NewQueryManager also implements
+// LogGroupDescriber, the resulting QueryManager can resolve the
+// GroupPrefixes and GroupPatterns fields of a QuerySpec into concrete
+// log group names.
+//
+// This interface is compatible with the AWS SDK for Go (v1)'s
+// cloudwatchlogsiface.CloudWatchLogsAPI interface and *cloudwatchlogs.CloudWatchLogs
+// type.
+type LogGroupDescriber interface {
+	DescribeLogGroupsWithContext(context.Context, *cloudwatchlogs.DescribeLogGroupsInput, ...request.Option) (*cloudwatchlogs.DescribeLogGroupsOutput, error)
+}
+
+// LogRecordGetter is an optional extension of CloudWatchLogsActions
+// which provides the CloudWatch Logs GetLogRecord act. If the Actions
+// field of the Config passed to NewQueryManager also implements
+// LogRecordGetter, the resulting QueryManager can run queries whose
+// QuerySpec has the Hydrate field set.
+//
+// This interface is compatible with the AWS SDK for Go (v1)'s
+// cloudwatchlogsiface.CloudWatchLogsAPI interface and *cloudwatchlogs.CloudWatchLogs
+// type.
+type LogRecordGetter interface {
+	GetLogRecordWithContext(context.Context, *cloudwatchlogs.GetLogRecordInput, ...request.Option) (*cloudwatchlogs.GetLogRecordOutput, error)
+}
+
+// QueryDefinitionActions provides access to the CloudWatch Logs actions
+// needed to list and save CloudWatch Logs Insights query definitions,
+// which are also known as saved queries. It is used by the
+// ListQueryDefinitions, GetQueryDefinition, and PutQueryDefinition
+// functions. If the Actions field of the Config passed to
+// NewQueryManager also implements QueryDefinitionActions, the resulting
+// QueryManager can run queries whose QuerySpec has the QueryDefinition
+// field set.
+//
+// This interface is compatible with the AWS SDK for Go (v1)'s
+// cloudwatchlogsiface.CloudWatchLogsAPI interface and *cloudwatchlogs.CloudWatchLogs
+// type.
+type QueryDefinitionActions interface {
+	DescribeQueryDefinitionsWithContext(context.Context, *cloudwatchlogs.DescribeQueryDefinitionsInput, ...request.Option) (*cloudwatchlogs.DescribeQueryDefinitionsOutput, error)
+	PutQueryDefinitionWithContext(context.Context, *cloudwatchlogs.PutQueryDefinitionInput, ...request.Option) (*cloudwatchlogs.PutQueryDefinitionOutput, error)
+}
+
+// QueryDescriber is an optional extension of CloudWatchLogsActions
+// which provides the CloudWatch Logs DescribeQueries act. It is needed
+// to find orphaned Insights queries using StopOrphanedQueries or the
+// StopOrphans field of Config.
+//
+// This interface is compatible with the AWS SDK for Go (v1)'s
+// cloudwatchlogsiface.CloudWatchLogsAPI interface and *cloudwatchlogs.CloudWatchLogs
+// type.
+type QueryDescriber interface {
+	DescribeQueriesWithContext(context.Context, *cloudwatchlogs.DescribeQueriesInput, ...request.Option) (*cloudwatchlogs.DescribeQueriesOutput, error)
+}
+
 // CloudWatchLogsAction represents a single enumerated CloudWatch Logs
 // act.
 type CloudWatchLogsAction int
@@ -48,9 +104,17 @@ const (
 	StopQuery
 	// GetQueryResults indicates the CloudWatchLogs GetQueryResults act.
 	GetQueryResults
-	// numActions is the number of CloudWatch Logs actions supported by
-	// CloudWatchLogsActions and required to construct a QueryManager
-	// using NewQueryManager.
+	// GetLogRecord indicates the CloudWatch Logs GetLogRecord act,
+	// which is only used if the Actions field of Config implements
+	// LogRecordGetter.
+	GetLogRecord
+	// DescribeLogGroups indicates the CloudWatch Logs DescribeLogGroups
+	// act, which is only used to resolve the GroupPrefixes and
+	// GroupPatterns of a QuerySpec if the Actions field of Config
+	// implements LogGroupDescriber.
+	DescribeLogGroups
+	// numActions is the number of CloudWatch Logs actions which a
+	// QueryManager rate limits.
 	numActions
 )
 
@@ -62,18 +126,52 @@ const (
 // The documented service quotas may increase over time, in which case
 // the map values should be updated to match the increases.
 var RPSQuotaLimits = map[CloudWatchLogsAction]int{
-	StartQuery:      5,
-	StopQuery:       5,
-	GetQueryResults: 5,
+	StartQuery:        5,
+	StopQuery:         5,
+	GetQueryResults:   5,
+	GetLogRecord:      5,
+	DescribeLogGroups: 5,
 }
 
 // RPSDefaults specifies the default maximum number of requests per
 // second which the QueryManager will make to the CloudWatch Logs web
 // service for each CloudWatch Logs act. These default values are
 // operative unless explicitly overwritten in the Config structure
-// passed to NewQueryManager.
+// passed to NewQueryManager, either directly in the RPS field or
+// indirectly by raising the quota in the RPSQuota field.
 var RPSDefaults = map[CloudWatchLogsAction]int{
-	StartQuery:      RPSQuotaLimits[StartQuery] - 2,
-	StopQuery:       RPSQuotaLimits[StopQuery] - 2,
-	GetQueryResults: RPSQuotaLimits[GetQueryResults] - 2,
+	StartQuery:        belowQuota(RPSQuotaLimits[StartQuery]),
+	StopQuery:         belowQuota(RPSQuotaLimits[StopQuery]),
+	GetQueryResults:   belowQuota(RPSQuotaLimits[GetQueryResults]),
+	GetLogRecord:      belowQuota(RPSQuotaLimits[GetLogRecord]),
+	DescribeLogGroups: belowQuota(RPSQuotaLimits[DescribeLogGroups]),
+}
+
+// validAction returns true if action is one of the CloudWatch Logs
+// actions known to the QueryManager.
+func validAction(action CloudWatchLogsAction) bool {
+	return action >= 0 && action < numActions
+}
+
+// rpsQuota returns the RPS quota for action, which is the value given
+// in quotas or, if none is given, the value in RPSQuotaLimits.
+func rpsQuota(quotas map[CloudWatchLogsAction]int, action CloudWatchLogsAction) int {
+	if quota := quotas[action]; quota > 0 {
+		return quota
+	}
+	return RPSQuotaLimits[action]
+}
+
+// quotaHeadroom is the amount by which the default parallelism and RPS
+// values are set below the corresponding service quota, to leave some
+// capacity available for other users of the same AWS account.
+const quotaHeadroom = 2
+
+// belowQuota returns the default value to use for a setting whose
+// service quota is quota. The result is always at least one.
+func belowQuota(quota int) int {
+	if quota <= quotaHeadroom {
+		return 1
+	}
+	return quota - quotaHeadroom
 }
@@ -21,6 +21,15 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+func TestBelowQuota(t *testing.T) {
+	assert.Equal(t, 1, belowQuota(0))
+	assert.Equal(t, 1, belowQuota(1))
+	assert.Equal(t, 1, belowQuota(2))
+	assert.Equal(t, 1, belowQuota(3))
+	assert.Equal(t, 3, belowQuota(5))
+	assert.Equal(t, 98, belowQuota(100))
+}
+
 func TestAWSSDKActions(t *testing.T) {
 	// The purpose of this test case is to verify and demonstrate the
 	// behavior of the CloudWatch Logs client in the AWS SDK for Go v1.
@@ -154,3 +163,58 @@ func (m *mockActions) GetQueryResultsWithContext(ctx context.Context, input *clo
 	}
 	return nil, args.Error(1)
 }
+
+func (m *mockActions) DescribeLogGroupsWithContext(ctx context.Context, input *cloudwatchlogs.DescribeLogGroupsInput, _ ...request.Option) (*cloudwatchlogs.DescribeLogGroupsOutput, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	args := m.Called(ctx, input)
+	if output, ok := args.Get(0).(*cloudwatchlogs.DescribeLogGroupsOutput); ok {
+		return output, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockActions) GetLogRecordWithContext(ctx context.Context, input *cloudwatchlogs.GetLogRecordInput, _ ...request.Option) (*cloudwatchlogs.GetLogRecordOutput, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	args := m.Called(ctx, input)
+	if output, ok := args.Get(0).(*cloudwatchlogs.GetLogRecordOutput); ok {
+		return output, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockActions) DescribeQueryDefinitionsWithContext(ctx context.Context, input *cloudwatchlogs.DescribeQueryDefinitionsInput, _ ...request.Option) (*cloudwatchlogs.DescribeQueryDefinitionsOutput, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	args := m.Called(ctx, input)
+	if output, ok := args.Get(0).(*cloudwatchlogs.DescribeQueryDefinitionsOutput); ok {
+		return output, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockActions) PutQueryDefinitionWithContext(ctx context.Context, input *cloudwatchlogs.PutQueryDefinitionInput, _ ...request.Option) (*cloudwatchlogs.PutQueryDefinitionOutput, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	args := m.Called(ctx, input)
+	if output, ok := args.Get(0).(*cloudwatchlogs.PutQueryDefinitionOutput); ok {
+		return output, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockActions) DescribeQueriesWithContext(ctx context.Context, input *cloudwatchlogs.DescribeQueriesInput, _ ...request.Option) (*cloudwatchlogs.DescribeQueriesOutput, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	args := m.Called(ctx, input)
+	if output, ok := args.Get(0).(*cloudwatchlogs.DescribeQueriesOutput); ok {
+		return output, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
@@ -20,6 +20,39 @@ var (
 	// ErrClosed is the error returned by a read or query operation
 	// when the underlying stream or query manager has been closed.
 	ErrClosed = errors.New("incite: operation on a closed object")
+
+	// ErrQueryDefinitionNotFound is wrapped by the error returned from
+	// GetQueryDefinition, or from a query operation, when no saved
+	// query definition has the requested name or ID.
+	ErrQueryDefinitionNotFound = errors.New("incite: query definition not found")
+
+	// ErrThrottled matches, using errors.Is, an incite error caused by
+	// CloudWatch Logs throttling a request for exceeding an RPS quota.
+	ErrThrottled = errors.New("incite: throttled by CloudWatch Logs")
+
+	// ErrAccessDenied matches, using errors.Is, an incite error caused
+	// by the AWS credentials in use lacking permission for a CloudWatch
+	// Logs action.
+	ErrAccessDenied = errors.New("incite: access denied by CloudWatch Logs")
+
+	// ErrLogGroupNotFound matches, using errors.Is, an incite error
+	// caused by a query naming a log group which does not exist, or by
+	// a query's log group prefixes and patterns matching no log group.
+	ErrLogGroupNotFound = errors.New("incite: log group not found")
+
+	// ErrMalformedQuery matches, using errors.Is, an incite error
+	// caused by CloudWatch Logs rejecting the query text as malformed.
+	ErrMalformedQuery = errors.New("incite: malformed query")
+
+	// ErrQueryTimeout matches, using errors.Is, a
+	// TerminalQueryStatusError indicating that a CloudWatch Logs
+	// Insights query timed out, or a DeadlineError.
+	ErrQueryTimeout = errors.New("incite: query timed out")
+
+	// ErrConcurrencyLimit matches, using errors.Is, an incite error
+	// caused by CloudWatch Logs refusing to start a query because too
+	// many queries were already running.
+	ErrConcurrencyLimit = errors.New("incite: query concurrency limit exceeded")
 )
 
 // StartQueryError is returned by Stream.Read to indicate that the
@@ -53,6 +86,13 @@ func (err *StartQueryError) Unwrap() error {
 	return err.Cause
 }
 
+// Is returns true if target is the sentinel error, such as
+// ErrMalformedQuery, which describes the category of the causing
+// error.
+func (err *StartQueryError) Is(target error) bool {
+	return isCategory(err.Cause, target)
+}
+
 // TerminalQueryStatusError is returned by Stream.Read when CloudWatch
 // Logs Insights indicated that a chunk of the stream's query is in a
 // failed status, such as Cancelled, Failed, or Timeout.
@@ -76,6 +116,17 @@ func (err *TerminalQueryStatusError) Error() string {
 	return fmt.Sprintf("incite: query ID %q has terminal status %q [query text %q]", err.QueryID, err.Status, err.Text)
 }
 
+// queryStatusTimeout is the CloudWatch Logs Insights query status for a
+// query which timed out. The AWS SDK for Go does not define a constant
+// for it.
+const queryStatusTimeout = "Timeout"
+
+// Is returns true if target is ErrQueryTimeout and the terminal status
+// is Timeout.
+func (err *TerminalQueryStatusError) Is(target error) bool {
+	return target == ErrQueryTimeout && err.Status == queryStatusTimeout
+}
+
 // UnexpectedQueryError is returned by Stream.Read when the CloudWatch
 // Logs Insights API behaved unexpectedly while Incite was polling a
 // chunk status via the CloudWatch Logs GetQueryResults API act.
@@ -101,6 +152,153 @@ func (err *UnexpectedQueryError) Unwrap() error {
 	return err.Cause
 }
 
+// Is returns true if target is the sentinel error, such as
+// ErrThrottled, which describes the category of the causing error.
+func (err *UnexpectedQueryError) Is(target error) bool {
+	return isCategory(err.Cause, target)
+}
+
+// LogRecordError is returned by Stream.Read when the CloudWatch Logs
+// service API returned a fatal error while Incite was hydrating a
+// result via the CloudWatch Logs GetLogRecord act.
+//
+// When LogRecordError is returned by Stream.Read, the stream's query
+// is considered failed and all subsequent reads on the stream will
+// return an error.
+type LogRecordError struct {
+	// Ptr is the @ptr value of the log record that could not be
+	// fetched.
+	Ptr string
+	// QueryID is the CloudWatch Logs Insights query ID of the chunk
+	// whose result could not be hydrated.
+	QueryID string
+	// Text is the text of the query for the chunk.
+	Text string
+	// Cause is the causing error, which will typically be an AWS SDK
+	// for Go error type.
+	Cause error
+}
+
+func (err *LogRecordError) Error() string {
+	return fmt.Sprintf("incite: CloudWatch Logs failed to get log record %q for query ID %q [query text %q]: %s", err.Ptr, err.QueryID, err.Text, err.Cause)
+}
+
+func (err *LogRecordError) Unwrap() error {
+	return err.Cause
+}
+
+// Is returns true if target is the sentinel error, such as
+// ErrAccessDenied, which describes the category of the causing error.
+func (err *LogRecordError) Is(target error) bool {
+	return isCategory(err.Cause, target)
+}
+
+// PartialResultsError is returned by Stream.Read in place of io.EOF,
+// after all results have been read, when the stream's QuerySpec has
+// AllowPartial set and at least one chunk of the stream's query failed.
+// The results read from the stream are complete except for the time
+// ranges listed in Failed.
+type PartialResultsError struct {
+	// Text is the text of the query.
+	Text string
+	// Failed lists the time ranges which failed, in the order in which
+	// they failed.
+	Failed []FailedRange
+}
+
+// FailedRange is a time range of a query, corresponding to one chunk,
+// for which no results could be obtained.
+type FailedRange struct {
+	// Start is the start of the failed time range, inclusive.
+	Start time.Time
+	// End is the end of the failed time range, exclusive.
+	End time.Time
+	// Cause is the error which caused the chunk to fail, such as a
+	// StartQueryError or TerminalQueryStatusError.
+	Cause error
+}
+
+func (err *PartialResultsError) Error() string {
+	first := err.Failed[0]
+	return fmt.Sprintf("incite: %d time range(s) failed for query %q, first [%s..%s): %s", len(err.Failed), err.Text, first.Start, first.End, first.Cause)
+}
+
+// Unwrap returns the cause of the first failed time range.
+func (err *PartialResultsError) Unwrap() error {
+	if len(err.Failed) == 0 {
+		return nil
+	}
+	return err.Failed[0].Cause
+}
+
+// Is returns true if target is the cause of any failed time range, or
+// the sentinel error, such as ErrThrottled, which describes the
+// category of any of the causes.
+func (err *PartialResultsError) Is(target error) bool {
+	for _, failed := range err.Failed {
+		if isCategory(failed.Cause, target) || errors.Is(failed.Cause, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// DeadlineError is returned by Stream.Read when the stream, or one
+// chunk of the stream's query, exceeded the client-side deadline set by
+// the Timeout or ChunkTimeout field of QuerySpec.
+type DeadlineError struct {
+	// Text is the text of the query.
+	Text string
+	// Start is the start of the time range which exceeded the deadline:
+	// either the whole query or one chunk.
+	Start time.Time
+	// End is the end of the time range which exceeded the deadline.
+	End time.Time
+	// Timeout is the timeout which was exceeded.
+	Timeout time.Duration
+}
+
+func (err *DeadlineError) Error() string {
+	return fmt.Sprintf("incite: query %q exceeded %s deadline for [%s..%s)", err.Text, err.Timeout, err.Start, err.End)
+}
+
+// Is returns true if target is ErrQueryTimeout.
+func (err *DeadlineError) Is(target error) bool {
+	return target == ErrQueryTimeout
+}
+
+// CircuitOpenError is returned by QueryManager.Query when the
+// QueryManager's circuit breaker is open. It is also returned by
+// Stream.Read, wrapped in a StartQueryError, when a chunk of the
+// stream's query could not be started because the circuit breaker was
+// open.
+//
+// The circuit breaker is configured by the CircuitBreaker field of
+// Config.
+type CircuitOpenError struct {
+	// Until is the earliest time at which the circuit breaker will
+	// let a probe request through to CloudWatch Logs.
+	Until time.Time
+	// Cause is the most recent failure which contributed to opening
+	// the circuit breaker.
+	Cause error
+}
+
+func (err *CircuitOpenError) Error() string {
+	return fmt.Sprintf("incite: circuit breaker open until %s: %s", err.Until, err.Cause)
+}
+
+func (err *CircuitOpenError) Unwrap() error {
+	return err.Cause
+}
+
+// Is returns true if target is the sentinel error, such as
+// ErrThrottled, which describes the category of the most recent
+// failure.
+func (err *CircuitOpenError) Is(target error) bool {
+	return isCategory(err.Cause, target)
+}
+
 func errNilStatus() error {
 	return errors.New(outputMissingStatusMsg)
 }
@@ -117,6 +315,119 @@ func errNoValue(key string) error {
 	return fmt.Errorf("incite: result field missing value for key %q", key)
 }
 
+// A categorizedError is an incite error, not of any exported error
+// type, which matches using errors.Is the sentinel error, such as
+// ErrAccessDenied, describing its category. The category is kind if
+// set, and otherwise the category of the causing error.
+type categorizedError struct {
+	msg   string
+	cause error
+	kind  error
+}
+
+func (err *categorizedError) Error() string {
+	if err.cause == nil {
+		return err.msg
+	}
+	return err.msg + ": " + err.cause.Error()
+}
+
+func (err *categorizedError) Unwrap() error {
+	return err.cause
+}
+
+func (err *categorizedError) Is(target error) bool {
+	if err.kind != nil {
+		return target == err.kind
+	}
+	return isCategory(err.cause, target)
+}
+
+func errNoGroupsMatched(prefixes, patterns []string) error {
+	return &categorizedError{
+		msg:  fmt.Sprintf("incite: no log groups match prefixes %q or patterns %q", prefixes, patterns),
+		kind: ErrLogGroupNotFound,
+	}
+}
+
+func errDescribeGroups(cause error) error {
+	return &categorizedError{msg: "incite: failed to describe log groups", cause: cause}
+}
+
+func errDescribeQueryDefinitions(cause error) error {
+	return &categorizedError{msg: "incite: failed to describe query definitions", cause: cause}
+}
+
+func errPutQueryDefinition(name string, cause error) error {
+	return &categorizedError{msg: fmt.Sprintf("incite: failed to put query definition %q", name), cause: cause}
+}
+
+func errDescribeQueries(cause error) error {
+	return &categorizedError{msg: "incite: failed to describe queries", cause: cause}
+}
+
+func errStopOrphan(queryID string, cause error) error {
+	return &categorizedError{msg: fmt.Sprintf("incite: failed to stop orphaned query ID %q", queryID), cause: cause}
+}
+
+// isLimitExceeded returns true if err is, or wraps, a CloudWatch Logs
+// LimitExceededException, which StartQuery returns when the Insights
+// query concurrency quota is exhausted.
+func isLimitExceeded(err error) bool {
+	var x awserr.Error
+	return errors.As(err, &x) && x.Code() == cloudwatchlogs.ErrCodeLimitExceededException
+}
+
+// isThrottled returns true if err is, or wraps, an error indicating
+// that CloudWatch Logs throttled the request for exceeding an RPS
+// quota.
+func isThrottled(err error) bool {
+	var f awserr.RequestFailure
+	if errors.As(err, &f) && f.StatusCode() == 429 {
+		return true
+	}
+	var x awserr.Error
+	if !errors.As(err, &x) {
+		return false
+	}
+	return strings.Contains(strings.ToLower(x.Code()), "throttl") ||
+		strings.Contains(strings.ToLower(x.Message()), "rate exceeded")
+}
+
+// category returns the sentinel error, such as ErrThrottled, which
+// describes the category of err, typically an AWS SDK for Go error.
+// If err does not belong to a known category, category returns nil.
+func category(err error) error {
+	if isLimitExceeded(err) {
+		return ErrConcurrencyLimit
+	}
+	if isThrottled(err) {
+		return ErrThrottled
+	}
+	var x awserr.Error
+	if !errors.As(err, &x) {
+		return nil
+	}
+	switch x.Code() {
+	case "AccessDeniedException", "AccessDenied":
+		return ErrAccessDenied
+	case cloudwatchlogs.ErrCodeMalformedQueryException:
+		return ErrMalformedQuery
+	case cloudwatchlogs.ErrCodeResourceNotFoundException:
+		if strings.Contains(strings.ToLower(x.Message()), "log group") {
+			return ErrLogGroupNotFound
+		}
+	}
+	return nil
+}
+
+// isCategory returns true if target is the sentinel error describing
+// the category of err.
+func isCategory(err, target error) bool {
+	c := category(err)
+	return c != nil && c == target
+}
+
 func isTemporary(err error) bool {
 	if x, ok := err.(awserr.Error); ok {
 		// Short-circuit if the HTTP status code indicates retryability.
@@ -171,26 +482,50 @@ const (
 	nilActionsMsg = "incite: nil actions"
 	nilStreamMsg  = "incite: nil stream"
 	nilContextMsg = "incite: nil context"
+	badActionMsg  = "incite: unknown CloudWatch Logs action"
+
+	badQueryConcurrencyQuotaMsg = "incite: negative query concurrency quota"
+	badRPSQuotaMsg              = "incite: negative RPS quota"
+	badFailureRateMsg           = "incite: circuit breaker failure rate greater than one"
+	badSchedulingPolicyMsg      = "incite: unknown scheduling policy"
+	badTenantWeightMsg          = "incite: negative tenant weight"
+	badQueryMarkerMsg           = "incite: query marker is empty or contains a line break"
+	noQueryDescriberMsg         = "incite: stopping orphans requires actions implementing QueryDescriber"
+	stopOrphansWithoutMarkerMsg = "incite: stopping orphans requires a query marker"
+
+	textBlankMsg                      = "incite: blank query text"
+	queryIDBlankMsg                   = "incite: blank query ID"
+	startSubMillisecondMsg            = "incite: start has sub-millisecond granularity"
+	endSubMillisecondMsg              = "incite: end has sub-millisecond granularity"
+	endNotBeforeStartMsg              = "incite: end not before start"
+	noGroupsMsg                       = "incite: no log groups"
+	noGroupDescriberMsg               = "incite: group prefixes or patterns require actions implementing LogGroupDescriber"
+	badGroupPatternMsg                = "incite: bad log group pattern"
+	exceededMaxLimitMsg               = "incite: exceeded MaxLimit"
+	exceededMaxGroupsMsg              = "incite: exceeded MaxGroups"
+	chunkSubMillisecondMsg            = "incite: chunk has sub-millisecond granularity"
+	splitUntilSubMillisecondMsg       = "incite: split-until has sub-millisecond granularity"
+	splitUntilWithPreviewMsg          = "incite: split-until incompatible with preview"
+	splitUntilWithoutMaxLimitMsg      = "incite: split-until requires MaxLimit"
+	splitOnStatusWithoutSplitUntilMsg = "incite: split-on-timeout and split-on-failed require split-until"
+	noLogRecordGetterMsg              = "incite: hydrate requires actions implementing LogRecordGetter"
+	hydrateWithPreviewMsg             = "incite: hydrate incompatible with preview"
+	noQueryDefinitionActionsMsg       = "incite: query definition requires actions implementing QueryDefinitionActions"
+	queryDefinitionBlankMsg           = "incite: blank query definition name or ID"
+	queryDefinitionNameBlankMsg       = "incite: blank query definition name"
 
-	textBlankMsg                 = "incite: blank query text"
-	startSubMillisecondMsg       = "incite: start has sub-millisecond granularity"
-	endSubMillisecondMsg         = "incite: end has sub-millisecond granularity"
-	endNotBeforeStartMsg         = "incite: end not before start"
-	noGroupsMsg                  = "incite: no log groups"
-	exceededMaxLimitMsg          = "incite: exceeded MaxLimit"
-	chunkSubMillisecondMsg       = "incite: chunk has sub-millisecond granularity"
-	splitUntilSubMillisecondMsg  = "incite: split-until has sub-millisecond granularity"
-	splitUntilWithPreviewMsg     = "incite: split-until incompatible with preview"
-	splitUntilWithoutMaxLimitMsg = "incite: split-until requires MaxLimit"
-
-	outputMissingQueryIDMsg = "incite: nil query ID in StartQuery output from CloudWatch Logs"
-	outputMissingStatusMsg  = "incite: nil status in GetQueryResults output from CloudWatch Logs"
-	fieldMissingKeyMsg      = "incite: result field missing key"
+	outputMissingQueryIDMsg           = "incite: nil query ID in StartQuery output from CloudWatch Logs"
+	outputMissingStatusMsg            = "incite: nil status in GetQueryResults output from CloudWatch Logs"
+	outputMissingQueryDefinitionIDMsg = "incite: nil query definition ID in PutQueryDefinition output from CloudWatch Logs"
+	fieldMissingKeyMsg                = "incite: result field missing key"
 )
 
 var (
-	errClosing      = errors.New("incite: closing")
-	errStopChunk    = errors.New("incite: owning stream died, cancel chunk")
-	errRestartChunk = errors.New("incite: transient chunk failure, restart chunk")
-	errSplitChunk   = errors.New("incite: chunk maxed, split chunk")
+	errClosing       = errors.New("incite: closing")
+	errStopChunk     = errors.New("incite: owning stream died, cancel chunk")
+	errRestartChunk  = errors.New("incite: transient chunk failure, restart chunk")
+	errSplitChunk    = errors.New("incite: chunk maxed, split chunk")
+	errChunkDeadline = errors.New("incite: chunk exceeded deadline, stop chunk")
+	errPreemptChunk  = errors.New("incite: chunk preempted, stop and restart chunk")
+	errProbeInFlight = errors.New("incite: circuit breaker probe in flight, hold chunk")
 )
@@ -167,6 +167,135 @@ func TestIsTemporary(t *testing.T) {
 	})
 }
 
+func TestIsLimitExceeded(t *testing.T) {
+	limitErr := cwlErr(cloudwatchlogs.ErrCodeLimitExceededException, "too many")
+
+	assert.True(t, isLimitExceeded(limitErr))
+	assert.True(t, isLimitExceeded(&StartQueryError{Cause: limitErr}))
+	assert.False(t, isLimitExceeded(nil))
+	assert.False(t, isLimitExceeded(errors.New("foo")))
+	assert.False(t, isLimitExceeded(cwlErr("Throttling", "slow down")))
+	assert.False(t, isLimitExceeded(cwlErr(cloudwatchlogs.ErrCodeServiceUnavailableException, "bar")))
+}
+
+func TestIsThrottled(t *testing.T) {
+	assert.True(t, isThrottled(cwlErr("ThrottlingException", "slow down")))
+	assert.True(t, isThrottled(&StartQueryError{Cause: cwlErr("Throttling", "slow down")}))
+	assert.True(t, isThrottled(cwlErr(cloudwatchlogs.ErrCodeServiceUnavailableException, "Rate exceeded")))
+	assert.True(t, isThrottled(issue13Error("foo", 429)))
+	assert.False(t, isThrottled(nil))
+	assert.False(t, isThrottled(errors.New("foo")))
+	assert.False(t, isThrottled(issue13Error("bar", 503)))
+	assert.False(t, isThrottled(cwlErr(cloudwatchlogs.ErrCodeServiceUnavailableException, "bar")))
+	assert.False(t, isThrottled(cwlErr(cloudwatchlogs.ErrCodeLimitExceededException, "too many")))
+}
+
+func TestErrorSentinels(t *testing.T) {
+	throttled := cwlErr("ThrottlingException", "Rate exceeded")
+	denied := cwlErr("AccessDeniedException", "not for you")
+	noGroup := cwlErr(cloudwatchlogs.ErrCodeResourceNotFoundException, "Log group 'foo' does not exist for account ID '123'")
+	noQuery := cwlErr(cloudwatchlogs.ErrCodeResourceNotFoundException, "Query does not exist")
+	malformed := cwlErr(cloudwatchlogs.ErrCodeMalformedQueryException, "unexpected symbol")
+	limit := cwlErr(cloudwatchlogs.ErrCodeLimitExceededException, "too many")
+	sentinels := []error{ErrThrottled, ErrAccessDenied, ErrLogGroupNotFound, ErrMalformedQuery, ErrQueryTimeout, ErrConcurrencyLimit}
+
+	testCases := []struct {
+		name     string
+		err      error
+		expected error
+	}{
+		{"StartQueryError Throttled", &StartQueryError{Cause: throttled}, ErrThrottled},
+		{"StartQueryError Throttled 429", &StartQueryError{Cause: issue13Error("foo", 429)}, ErrThrottled},
+		{"StartQueryError AccessDenied", &StartQueryError{Cause: denied}, ErrAccessDenied},
+		{"StartQueryError LogGroupNotFound", &StartQueryError{Cause: noGroup}, ErrLogGroupNotFound},
+		{"StartQueryError MalformedQuery", &StartQueryError{Cause: malformed}, ErrMalformedQuery},
+		{"StartQueryError ConcurrencyLimit", &StartQueryError{Cause: limit}, ErrConcurrencyLimit},
+		{"StartQueryError Unknown", &StartQueryError{Cause: errors.New("foo")}, nil},
+		{"StartQueryError Circuit Open", &StartQueryError{Cause: &CircuitOpenError{Cause: throttled}}, ErrThrottled},
+		{"UnexpectedQueryError Throttled", &UnexpectedQueryError{Cause: throttled}, ErrThrottled},
+		{"UnexpectedQueryError Query Not Found", &UnexpectedQueryError{Cause: noQuery}, nil},
+		{"LogRecordError AccessDenied", &LogRecordError{Cause: denied}, ErrAccessDenied},
+		{"TerminalQueryStatusError Timeout", &TerminalQueryStatusError{Status: "Timeout"}, ErrQueryTimeout},
+		{"TerminalQueryStatusError Failed", &TerminalQueryStatusError{Status: cloudwatchlogs.QueryStatusFailed}, nil},
+		{"DeadlineError", &DeadlineError{}, ErrQueryTimeout},
+		{"Wrapped", fmt.Errorf("context: %w", &StartQueryError{Cause: malformed}), ErrMalformedQuery},
+		{"DescribeGroups AccessDenied", errDescribeGroups(denied), ErrAccessDenied},
+		{"DescribeGroups Throttled", errDescribeGroups(throttled), ErrThrottled},
+		{"DescribeGroups Unknown", errDescribeGroups(errors.New("foo")), nil},
+		{"NoGroupsMatched", errNoGroupsMatched([]string{"foo"}, nil), ErrLogGroupNotFound},
+		{"DescribeQueryDefinitions AccessDenied", errDescribeQueryDefinitions(denied), ErrAccessDenied},
+		{"PutQueryDefinition AccessDenied", errPutQueryDefinition("foo", denied), ErrAccessDenied},
+		{"PutQueryDefinition Throttled", errPutQueryDefinition("foo", throttled), ErrThrottled},
+		{"DescribeQueries AccessDenied", errDescribeQueries(denied), ErrAccessDenied},
+		{"StopOrphan AccessDenied", errStopOrphan("foo", denied), ErrAccessDenied},
+		{"StopOrphan Query Not Found", errStopOrphan("foo", noQuery), nil},
+		{"CircuitOpenError Throttled", &CircuitOpenError{Cause: throttled}, ErrThrottled},
+		{"CircuitOpenError AccessDenied", &CircuitOpenError{Cause: denied}, ErrAccessDenied},
+		{"CircuitOpenError Unknown", &CircuitOpenError{Cause: errors.New("foo")}, nil},
+		{"PartialResultsError Throttled", &PartialResultsError{Failed: []FailedRange{{Cause: throttled}}}, ErrThrottled},
+		{"PartialResultsError StartQueryError", &PartialResultsError{Failed: []FailedRange{{Cause: &StartQueryError{Cause: malformed}}}}, ErrMalformedQuery},
+		{"PartialResultsError Later Range", &PartialResultsError{Failed: []FailedRange{{Cause: errors.New("foo")}, {Cause: &DeadlineError{}}}}, ErrQueryTimeout},
+		{"PartialResultsError Unknown", &PartialResultsError{Failed: []FailedRange{{Cause: errors.New("foo")}}}, nil},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			for _, sentinel := range sentinels {
+				assert.Equal(t, sentinel == testCase.expected, errors.Is(testCase.err, sentinel), "errors.Is(%v, %v)", testCase.err, sentinel)
+			}
+		})
+	}
+}
+
+func TestErrorCauses(t *testing.T) {
+	first, second := errors.New("first"), errors.New("second")
+
+	testCases := []struct {
+		name   string
+		err    error
+		causes []error
+	}{
+		{"CircuitOpenError", &CircuitOpenError{Cause: first}, []error{first}},
+		{"PartialResultsError", &PartialResultsError{Failed: []FailedRange{{Cause: first}, {Cause: second}}}, []error{first, second}},
+		{"PartialResultsError Wrapped Cause", &PartialResultsError{Failed: []FailedRange{{Cause: &StartQueryError{Cause: first}}}}, []error{first}},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert.NotNil(t, errors.Unwrap(testCase.err))
+			for _, cause := range testCase.causes {
+				assert.True(t, errors.Is(testCase.err, cause), "errors.Is(%v, %v)", testCase.err, cause)
+			}
+			assert.False(t, errors.Is(testCase.err, errors.New("other")))
+		})
+	}
+
+	t.Run("PartialResultsError Unwraps First Cause", func(t *testing.T) {
+		err := &PartialResultsError{Failed: []FailedRange{{Cause: first}, {Cause: second}}}
+
+		assert.Same(t, first, errors.Unwrap(err))
+	})
+}
+
+func TestCategorizedError(t *testing.T) {
+	cause := errors.New("bar")
+
+	t.Run("With Cause", func(t *testing.T) {
+		err := errPutQueryDefinition("foo", cause)
+
+		assert.EqualError(t, err, `incite: failed to put query definition "foo": bar`)
+		assert.Same(t, cause, errors.Unwrap(err))
+		assert.True(t, errors.Is(err, cause))
+	})
+
+	t.Run("Without Cause", func(t *testing.T) {
+		err := errNoGroupsMatched([]string{"foo"}, []string{"bar"})
+
+		assert.EqualError(t, err, `incite: no log groups match prefixes ["foo"] or patterns ["bar"]`)
+		assert.Nil(t, errors.Unwrap(err))
+	})
+}
+
 // issue13Error returns an error of the type that triggered issue #13,
 // https://github.com/gogama/incite/issues/13.
 func issue13Error(requestID string, statusCode int) error {
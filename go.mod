@@ -3,7 +3,7 @@ module github.com/gogama/incite
 go 1.14
 
 require (
-	github.com/aws/aws-sdk-go v1.21.6
+	github.com/aws/aws-sdk-go v1.30.23
 	github.com/stretchr/testify v1.7.0
 	golang.org/x/net v0.0.0-20210614182718-04defd469f4e // indirect
 )
@@ -0,0 +1,243 @@
+// Copyright 2022 The incite Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package incite
+
+import (
+	"context"
+	"errors"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+)
+
+// maxGroupCacheEntries is the maximum number of log group name prefixes
+// whose log group names a groupCache remembers.
+const maxGroupCacheEntries = 1000
+
+// A groupCache caches the log group names found under each log group
+// name prefix described using the CloudWatch Logs DescribeLogGroups
+// act. It is safe for concurrent use by multiple goroutines.
+type groupCache struct {
+	lock    sync.Mutex
+	entries map[string]groupCacheEntry // Keyed by log group name prefix
+}
+
+type groupCacheEntry struct {
+	names   []string  // Log group names having the prefix
+	expires time.Time // Time after which the entry is stale
+}
+
+func (gc *groupCache) get(prefix string, now time.Time) ([]string, bool) {
+	gc.lock.Lock()
+	defer gc.lock.Unlock()
+
+	e, ok := gc.entries[prefix]
+	if !ok {
+		return nil, false
+	}
+	if !now.Before(e.expires) {
+		delete(gc.entries, prefix)
+		return nil, false
+	}
+	return e.names, true
+}
+
+// put caches the log group names having a prefix. If the cache is full,
+// stale entries are discarded and, if none are stale, the entry which
+// expires soonest is discarded to make room.
+func (gc *groupCache) put(prefix string, names []string, expires time.Time) {
+	gc.lock.Lock()
+	defer gc.lock.Unlock()
+
+	if gc.entries == nil {
+		gc.entries = make(map[string]groupCacheEntry)
+	}
+	if _, ok := gc.entries[prefix]; !ok && len(gc.entries) >= maxGroupCacheEntries {
+		gc.evict(time.Now())
+	}
+	gc.entries[prefix] = groupCacheEntry{names, expires}
+}
+
+func (gc *groupCache) evict(now time.Time) {
+	var soonest string
+	for prefix, e := range gc.entries {
+		if !now.Before(e.expires) {
+			delete(gc.entries, prefix)
+		} else if soonest == "" || e.expires.Before(gc.entries[soonest].expires) {
+			soonest = prefix
+		}
+	}
+	if len(gc.entries) >= maxGroupCacheEntries {
+		delete(gc.entries, soonest)
+	}
+}
+
+// resolveGroups returns the complete, de-duplicated, list of log group
+// names to query for q, which is the union of the literal log groups in
+// q.Groups and the log groups matching q.GroupPrefixes and
+// q.GroupPatterns.
+func (m *mgr) resolveGroups(ctx context.Context, q *QuerySpec) ([]string, error) {
+	if len(q.GroupPrefixes) == 0 && len(q.GroupPatterns) == 0 {
+		return q.Groups, nil
+	}
+
+	describer, ok := m.Actions.(LogGroupDescriber)
+	if !ok {
+		return nil, errors.New(noGroupDescriberMsg)
+	}
+
+	seen := make(map[string]bool)
+	var groups []string
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			groups = append(groups, name)
+		}
+	}
+
+	var matched bool
+	for _, prefix := range q.GroupPrefixes {
+		names, err := m.describeGroups(ctx, describer, prefix)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			matched = true
+			add(name)
+		}
+	}
+
+	for _, pattern := range q.GroupPatterns {
+		names, err := m.describeGroups(ctx, describer, literalPrefix(pattern))
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			if ok, _ := path.Match(pattern, name); ok {
+				matched = true
+				add(name)
+			}
+		}
+	}
+
+	if !matched && len(q.Groups) == 0 {
+		return nil, errNoGroupsMatched(q.GroupPrefixes, q.GroupPatterns)
+	}
+
+	sort.Strings(groups)
+	for _, name := range q.Groups {
+		if !seen[name] {
+			seen[name] = true
+			groups = append(groups, name)
+		}
+	}
+
+	return groups, nil
+}
+
+// describeGroups returns the names of all log groups whose names begin
+// with prefix, either from the group cache or, on a cache miss, using
+// the CloudWatch Logs DescribeLogGroups act.
+func (m *mgr) describeGroups(ctx context.Context, describer LogGroupDescriber, prefix string) ([]string, error) {
+	ttl := m.GroupCacheTTL
+	if ttl == 0 {
+		ttl = DefaultGroupCacheTTL
+	}
+
+	now := time.Now()
+	if ttl > 0 {
+		if names, ok := m.groups.get(prefix, now); ok {
+			return names, nil
+		}
+	}
+
+	var names []string
+	input := cloudwatchlogs.DescribeLogGroupsInput{}
+	if prefix != "" {
+		input.LogGroupNamePrefix = &prefix
+	}
+	for {
+		if err := m.waitDescribeGroups(ctx); err != nil {
+			return nil, errDescribeGroups(err)
+		}
+		output, err := describer.DescribeLogGroupsWithContext(ctx, &input, request.WithAppendUserAgent(version()))
+		if err != nil {
+			return nil, errDescribeGroups(err)
+		}
+		for _, g := range output.LogGroups {
+			if g != nil && g.LogGroupName != nil {
+				names = append(names, *g.LogGroupName)
+			}
+		}
+		if output.NextToken == nil || *output.NextToken == "" {
+			break
+		}
+		input.NextToken = output.NextToken
+	}
+
+	if ttl > 0 {
+		m.groups.put(prefix, names, now.Add(ttl))
+	}
+	return names, nil
+}
+
+// waitDescribeGroups blocks until the mgr may make its next
+// DescribeLogGroups request, both under its own rate limit and under
+// the shared RateLimiter, if one is configured.
+func (m *mgr) waitDescribeGroups(ctx context.Context) error {
+	if err := m.groupLimit.wait(ctx); err != nil {
+		return err
+	}
+	if m.RateLimiter == nil {
+		return nil
+	}
+	return m.RateLimiter.Wait(ctx, DescribeLogGroups)
+}
+
+// groupLimits returns the RPS and token bucket size to use for the
+// DescribeLogGroups requests made by Query. They are derived from cfg
+// in the same way as for the other CloudWatch Logs actions: the burst
+// is capped so that a full bucket plus one second of refills does not
+// exceed the RPS quota.
+func groupLimits(cfg *Config) (rps, burst int) {
+	quota := rpsQuota(cfg.RPSQuota, DescribeLogGroups)
+	rps = cfg.RPS[DescribeLogGroups]
+	if rps <= 0 {
+		rps = belowQuota(quota)
+	}
+	burst = cfg.Burst[DescribeLogGroups]
+	if room := quota - rps; burst > room {
+		burst = room
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return
+}
+
+// validGroupPatterns returns true if every pattern in patterns is a
+// syntactically valid path.Match pattern.
+func validGroupPatterns(patterns []string) bool {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// literalPrefix returns the portion of a path.Match pattern preceding
+// its first special character.
+func literalPrefix(pattern string) string {
+	if i := strings.IndexAny(pattern, `*?[\`); i >= 0 {
+		return pattern[:i]
+	}
+	return pattern
+}
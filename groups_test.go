@@ -0,0 +1,429 @@
+// Copyright 2022 The incite Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package incite
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryManager_Query_GroupResolution(t *testing.T) {
+	text := "fields @message"
+
+	t.Run("Invalid Input", func(t *testing.T) {
+		testCases := []struct {
+			name    string
+			actions CloudWatchLogsActions
+			q       QuerySpec
+			err     string
+		}{
+			{
+				name:    "Pattern.Bad",
+				actions: newMockActions(t),
+				q: QuerySpec{
+					GroupPatterns: []string{"/aws/lambda/["},
+				},
+				err: badGroupPatternMsg,
+			},
+			{
+				name: "Actions.NotDescriber",
+				actions: struct {
+					CloudWatchLogsActions
+				}{newMockActions(t)},
+				q: QuerySpec{
+					GroupPrefixes: []string{"/aws/lambda/"},
+				},
+				err: noGroupDescriberMsg,
+			},
+		}
+
+		for _, testCase := range testCases {
+			t.Run(testCase.name, func(t *testing.T) {
+				m := NewQueryManager(Config{
+					Actions: testCase.actions,
+				})
+				t.Cleanup(func() {
+					_ = m.Close()
+				})
+				testCase.q.Text = text
+				testCase.q.Start = defaultStart
+				testCase.q.End = defaultEnd
+
+				s, err := m.Query(testCase.q)
+
+				assert.Nil(t, s)
+				assert.EqualError(t, err, testCase.err)
+			})
+		}
+	})
+
+	t.Run("No Match", func(t *testing.T) {
+		actions := newMockActions(t)
+		actions.
+			On("DescribeLogGroupsWithContext", anyContext, &cloudwatchlogs.DescribeLogGroupsInput{
+				LogGroupNamePrefix: sp("/aws/lambda/orders-"),
+			}).
+			Return(&cloudwatchlogs.DescribeLogGroupsOutput{
+				LogGroups: []*cloudwatchlogs.LogGroup{
+					{LogGroupName: sp("/aws/lambda/orders-api/extra")},
+				},
+			}, nil).
+			Once()
+		m := NewQueryManager(Config{
+			Actions: actions,
+		})
+		t.Cleanup(func() {
+			_ = m.Close()
+		})
+
+		s, err := m.Query(QuerySpec{
+			Text:          text,
+			Start:         defaultStart,
+			End:           defaultEnd,
+			GroupPatterns: []string{"/aws/lambda/orders-*"},
+		})
+
+		assert.Nil(t, s)
+		assert.EqualError(t, err, errNoGroupsMatched(nil, []string{"/aws/lambda/orders-*"}).Error())
+		actions.AssertExpectations(t)
+	})
+
+	t.Run("Describe Fails", func(t *testing.T) {
+		cause := errors.New("a most unlucky failure")
+		actions := newMockActions(t)
+		actions.
+			On("DescribeLogGroupsWithContext", anyContext, &cloudwatchlogs.DescribeLogGroupsInput{
+				LogGroupNamePrefix: sp("foo"),
+			}).
+			Return(nil, cause).
+			Once()
+		m := NewQueryManager(Config{
+			Actions: actions,
+		})
+		t.Cleanup(func() {
+			_ = m.Close()
+		})
+
+		s, err := m.Query(QuerySpec{
+			Text:          text,
+			Start:         defaultStart,
+			End:           defaultEnd,
+			GroupPrefixes: []string{"foo"},
+		})
+
+		assert.Nil(t, s)
+		assert.ErrorIs(t, err, cause)
+		actions.AssertExpectations(t)
+	})
+
+	t.Run("Prefixes and Patterns Resolved and Cached", func(t *testing.T) {
+		actions := newMockActions(t)
+		actions.
+			On("DescribeLogGroupsWithContext", anyContext, &cloudwatchlogs.DescribeLogGroupsInput{
+				LogGroupNamePrefix: sp("/aws/lambda/orders-"),
+			}).
+			Return(&cloudwatchlogs.DescribeLogGroupsOutput{
+				LogGroups: []*cloudwatchlogs.LogGroup{
+					{LogGroupName: sp("/aws/lambda/orders-b")},
+				},
+				NextToken: sp("page2"),
+			}, nil).
+			Once()
+		actions.
+			On("DescribeLogGroupsWithContext", anyContext, &cloudwatchlogs.DescribeLogGroupsInput{
+				LogGroupNamePrefix: sp("/aws/lambda/orders-"),
+				NextToken:          sp("page2"),
+			}).
+			Return(&cloudwatchlogs.DescribeLogGroupsOutput{
+				LogGroups: []*cloudwatchlogs.LogGroup{
+					{LogGroupName: sp("/aws/lambda/orders-a")},
+					{LogGroupName: sp("/aws/lambda/orders-a/nested")},
+				},
+			}, nil).
+			Once()
+		actions.
+			On("DescribeLogGroupsWithContext", anyContext, &cloudwatchlogs.DescribeLogGroupsInput{
+				LogGroupNamePrefix: sp("/ecs/"),
+			}).
+			Return(&cloudwatchlogs.DescribeLogGroupsOutput{
+				LogGroups: []*cloudwatchlogs.LogGroup{
+					{LogGroupName: sp("/ecs/orders")},
+				},
+			}, nil).
+			Once()
+		queryID := "resolved"
+		for i := 0; i < 2; i++ {
+			actions.
+				On("StartQueryWithContext", anyContext, startQueryInput(text, defaultStart, defaultEnd, DefaultLimit,
+					"/aws/lambda/orders-a", "/aws/lambda/orders-b", "/ecs/orders", "literal")).
+				Return(&cloudwatchlogs.StartQueryOutput{QueryId: &queryID}, nil).
+				Once()
+			actions.
+				On("GetQueryResultsWithContext", anyContext, &cloudwatchlogs.GetQueryResultsInput{QueryId: &queryID}).
+				Return(&cloudwatchlogs.GetQueryResultsOutput{
+					Status: sp(cloudwatchlogs.QueryStatusComplete),
+				}, nil).
+				Once()
+		}
+		m := NewQueryManager(Config{
+			Actions: actions,
+			RPS:     lotsOfRPS,
+		})
+		t.Cleanup(func() {
+			_ = m.Close()
+		})
+
+		for i := 0; i < 2; i++ {
+			s, err := m.Query(QuerySpec{
+				Text:          text,
+				Start:         defaultStart,
+				End:           defaultEnd,
+				Groups:        []string{"literal", "/ecs/orders"},
+				GroupPrefixes: []string{"/ecs/"},
+				GroupPatterns: []string{"/aws/lambda/orders-*"},
+			})
+			require.NoError(t, err)
+			require.NotNil(t, s)
+			r, err := ReadAll(s)
+			assert.NoError(t, err)
+			assert.Empty(t, r)
+		}
+
+		actions.AssertExpectations(t)
+	})
+
+	t.Run("Too Many Groups", func(t *testing.T) {
+		output := &cloudwatchlogs.DescribeLogGroupsOutput{}
+		for i := 0; i < MaxGroups; i++ {
+			output.LogGroups = append(output.LogGroups, &cloudwatchlogs.LogGroup{LogGroupName: sp(fmt.Sprintf("/ecs/%d", i))})
+		}
+		actions := newMockActions(t)
+		actions.
+			On("DescribeLogGroupsWithContext", anyContext, &cloudwatchlogs.DescribeLogGroupsInput{
+				LogGroupNamePrefix: sp("/ecs/"),
+			}).
+			Return(output, nil).
+			Once()
+		m := NewQueryManager(Config{
+			Actions: actions,
+		})
+		t.Cleanup(func() {
+			_ = m.Close()
+		})
+
+		s, err := m.Query(QuerySpec{
+			Text:          text,
+			Start:         defaultStart,
+			End:           defaultEnd,
+			Groups:        []string{"literal"},
+			GroupPrefixes: []string{"/ecs/"},
+		})
+
+		assert.Nil(t, s)
+		assert.EqualError(t, err, exceededMaxGroupsMsg)
+		actions.AssertExpectations(t)
+	})
+
+	t.Run("Shared Rate Limiter", func(t *testing.T) {
+		actions := newMockActions(t)
+		actions.
+			On("DescribeLogGroupsWithContext", anyContext, &cloudwatchlogs.DescribeLogGroupsInput{
+				LogGroupNamePrefix: sp("/ecs/"),
+			}).
+			Return(&cloudwatchlogs.DescribeLogGroupsOutput{
+				NextToken: sp("page2"),
+			}, nil).
+			Once()
+		actions.
+			On("DescribeLogGroupsWithContext", anyContext, &cloudwatchlogs.DescribeLogGroupsInput{
+				LogGroupNamePrefix: sp("/ecs/"),
+				NextToken:          sp("page2"),
+			}).
+			Return(&cloudwatchlogs.DescribeLogGroupsOutput{}, nil).
+			Once()
+		rl := &countingRateLimiter{RateLimiter: NewRateLimiter(lotsOfRPS, nil)}
+		m := NewQueryManager(Config{
+			Actions:     actions,
+			RPS:         lotsOfRPS,
+			RateLimiter: rl,
+		})
+		t.Cleanup(func() {
+			_ = m.Close()
+		})
+
+		_, err := m.Query(QuerySpec{
+			Text:          text,
+			Start:         defaultStart,
+			End:           defaultEnd,
+			GroupPrefixes: []string{"/ecs/"},
+		})
+
+		assert.Error(t, err)
+		assert.Equal(t, 2, rl.counts()[DescribeLogGroups])
+		actions.AssertExpectations(t)
+	})
+
+	t.Run("Shared Rate Limiter Fails", func(t *testing.T) {
+		cause := errors.New("no tokens for you")
+		actions := newMockActions(t)
+		m := NewQueryManager(Config{
+			Actions:     actions,
+			RateLimiter: failingRateLimiter{cause},
+		})
+		t.Cleanup(func() {
+			_ = m.Close()
+		})
+
+		s, err := m.Query(QuerySpec{
+			Text:          text,
+			Start:         defaultStart,
+			End:           defaultEnd,
+			GroupPrefixes: []string{"/ecs/"},
+		})
+
+		assert.Nil(t, s)
+		assert.ErrorIs(t, err, cause)
+		actions.AssertExpectations(t)
+	})
+
+	t.Run("Cache Disabled", func(t *testing.T) {
+		actions := newMockActions(t)
+		actions.
+			On("DescribeLogGroupsWithContext", anyContext, &cloudwatchlogs.DescribeLogGroupsInput{}).
+			Return(&cloudwatchlogs.DescribeLogGroupsOutput{}, nil).
+			Twice()
+		m := NewQueryManager(Config{
+			Actions:       actions,
+			GroupCacheTTL: -1,
+		})
+		t.Cleanup(func() {
+			_ = m.Close()
+		})
+
+		for i := 0; i < 2; i++ {
+			_, err := m.Query(QuerySpec{
+				Text:          text,
+				Start:         defaultStart,
+				End:           defaultEnd,
+				GroupPatterns: []string{"*"},
+			})
+			assert.Error(t, err)
+		}
+
+		actions.AssertExpectations(t)
+	})
+}
+
+func TestGroupCache(t *testing.T) {
+	var gc groupCache
+	now := time.Now()
+
+	_, ok := gc.get("foo", now)
+	assert.False(t, ok)
+
+	gc.put("foo", []string{"foo1", "foo2"}, now.Add(time.Minute))
+	names, ok := gc.get("foo", now)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"foo1", "foo2"}, names)
+
+	_, ok = gc.get("foo", now.Add(time.Minute))
+	assert.False(t, ok)
+	assert.Empty(t, gc.entries, "stale entry must be discarded")
+
+	t.Run("Full", func(t *testing.T) {
+		var gc groupCache
+		now := time.Now()
+		for i := 0; i < maxGroupCacheEntries; i++ {
+			gc.put(fmt.Sprint(i), nil, now.Add(time.Hour+time.Duration(i)*time.Second))
+		}
+
+		gc.put("new", nil, now.Add(time.Minute))
+
+		assert.Len(t, gc.entries, maxGroupCacheEntries)
+		_, ok := gc.get("0", now)
+		assert.False(t, ok, "entry expiring soonest must be evicted")
+		_, ok = gc.get("new", now)
+		assert.True(t, ok)
+	})
+
+	t.Run("Full With Stale Entries", func(t *testing.T) {
+		var gc groupCache
+		now := time.Now()
+		for i := 0; i < maxGroupCacheEntries; i++ {
+			expires := now.Add(time.Hour)
+			if i%2 == 0 {
+				expires = now.Add(-time.Minute)
+			}
+			gc.put(fmt.Sprint(i), nil, expires)
+		}
+
+		gc.put("new", nil, now.Add(time.Minute))
+
+		assert.Len(t, gc.entries, maxGroupCacheEntries/2+1)
+	})
+}
+
+func TestGroupLimits(t *testing.T) {
+	testCases := []struct {
+		name  string
+		cfg   Config
+		rps   int
+		burst int
+	}{
+		{
+			name:  "Default",
+			rps:   RPSDefaults[DescribeLogGroups],
+			burst: 1,
+		},
+		{
+			name: "Explicit",
+			cfg: Config{
+				RPS:   map[CloudWatchLogsAction]int{DescribeLogGroups: 2},
+				Burst: map[CloudWatchLogsAction]int{DescribeLogGroups: 3},
+			},
+			rps:   2,
+			burst: 3,
+		},
+		{
+			name: "Burst Capped by Quota",
+			cfg: Config{
+				Burst: map[CloudWatchLogsAction]int{DescribeLogGroups: 10},
+			},
+			rps:   RPSDefaults[DescribeLogGroups],
+			burst: RPSQuotaLimits[DescribeLogGroups] - RPSDefaults[DescribeLogGroups],
+		},
+		{
+			name: "Raised Quota",
+			cfg: Config{
+				RPSQuota: map[CloudWatchLogsAction]int{DescribeLogGroups: 20},
+				Burst:    map[CloudWatchLogsAction]int{DescribeLogGroups: 10},
+			},
+			rps:   belowQuota(20),
+			burst: 2,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			rps, burst := groupLimits(&testCase.cfg)
+
+			assert.Equal(t, testCase.rps, rps)
+			assert.Equal(t, testCase.burst, burst)
+		})
+	}
+}
+
+func TestLiteralPrefix(t *testing.T) {
+	assert.Equal(t, "/aws/lambda/orders-", literalPrefix("/aws/lambda/orders-*"))
+	assert.Equal(t, "/aws/", literalPrefix("/aws/[lq]ambda"))
+	assert.Equal(t, "", literalPrefix("?foo"))
+	assert.Equal(t, "exact", literalPrefix("exact"))
+}
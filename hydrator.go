@@ -0,0 +1,187 @@
+// Copyright 2022 The incite Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package incite
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+)
+
+type hydrator struct {
+	worker
+	cache recordCache
+}
+
+const maxTempHydratingErrs = 10
+
+// maxCachedRecords is the maximum number of log records the hydrator
+// keeps in its cache.
+const maxCachedRecords = 1000
+
+// hydrateBatchSize is the number of hydrated results the hydrator
+// accumulates before sending them to the stream, so that results from
+// a large chunk reach the reader before the whole chunk is hydrated.
+const hydrateBatchSize = 100
+
+func newHydrator(m *mgr) *hydrator {
+	h := &hydrator{
+		worker: worker{
+			m:           m,
+			regulator:   makeBurstRegulator(m.close, m.RPS[GetLogRecord], m.defaultRPS(GetLogRecord), m.Burst[GetLogRecord], rpsQuota(m.RPSQuota, GetLogRecord)),
+			in:          m.hydrate,
+			out:         m.update,
+			name:        "hydrator",
+			action:      GetLogRecord,
+			maxInFlight: m.MaxInFlight,
+		},
+		cache: makeRecordCache(maxCachedRecords),
+	}
+	h.manipulator = h
+	return h
+}
+
+func (h *hydrator) context(c *chunk) context.Context {
+	return c.ctx
+}
+
+func (h *hydrator) manipulate(c *chunk) outcome {
+	// Discard chunk if the owning stream is dead.
+	if !c.stream.alive() {
+		c.err = errStopChunk
+		return finished
+	}
+
+	// Hydrate results from the cache until we reach one which needs a
+	// call to CloudWatch Logs.
+	var ptr string
+	for ; c.next < len(c.results); c.next++ {
+		ptr = resultPtr(c.results[c.next])
+		if ptr == "" {
+			continue
+		}
+		if record, ok := h.cache.get(ptr); ok {
+			c.results[c.next] = hydrateResult(c.results[c.next], record)
+			continue
+		}
+		break
+	}
+
+	// Get the next log record, if there is one.
+	if c.next < len(c.results) {
+		getter := h.m.Actions.(LogRecordGetter)
+		input := cloudwatchlogs.GetLogRecordInput{
+			LogRecordPointer: &ptr,
+		}
+		output, err := getter.GetLogRecordWithContext(c.ctx, &input, request.WithAppendUserAgent(version()))
+		if err != nil {
+			c.err = &LogRecordError{ptr, c.queryID, c.stream.Text, err}
+			if h.retry(c, err) {
+				h.m.logChunk(c, "temporary failure to hydrate", err.Error())
+				return temporaryError
+			}
+			h.m.logChunk(c, "permanent failure to hydrate", "fatal error from CloudWatch Logs: "+err.Error())
+			return finished
+		}
+		h.cache.put(ptr, output.LogRecord)
+		c.results[c.next] = hydrateResult(c.results[c.next], output.LogRecord)
+		c.next++
+		c.tmp = 0
+		c.err = nil
+		if c.next >= hydrateBatchSize {
+			sendBlock(c.stream, c.results[:c.next])
+			c.results, c.next = c.results[c.next:], 0
+		}
+		if c.next < len(c.results) {
+			return inconclusive
+		}
+	}
+
+	// All results are hydrated, so the chunk is done.
+	sendBlock(c.stream, c.results)
+	c.results, c.next = nil, 0
+	c.Stats.RangeDone += c.duration()
+	c.state = hydrated
+	c.err = nil
+	return finished
+}
+
+func (h *hydrator) release(c *chunk) {
+	h.m.logChunk(c, "releasing hydratable", "")
+}
+
+// resultPtr returns the value of the @ptr field of r, or the empty
+// string if r has no @ptr field.
+func resultPtr(r Result) string {
+	for _, f := range r {
+		if f.Field == "@ptr" {
+			return f.Value
+		}
+	}
+	return ""
+}
+
+// hydrateResult returns a copy of r with each field of record that is
+// not already present in r appended, in ascending order of field name.
+func hydrateResult(r Result, record map[string]*string) Result {
+	present := make(map[string]bool, len(r))
+	for _, f := range r {
+		present[f.Field] = true
+	}
+	keys := make([]string, 0, len(record))
+	for k, v := range record {
+		if !present[k] && v != nil {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	rr := make(Result, len(r), len(r)+len(keys))
+	copy(rr, r)
+	for _, k := range keys {
+		rr = append(rr, ResultField{Field: k, Value: *record[k]})
+	}
+	return rr
+}
+
+// A recordCache is a bounded first-in, first-out cache of log records
+// keyed by @ptr. It is shared by the hydrator's concurrent
+// manipulations and is safe for concurrent use.
+type recordCache struct {
+	lock    sync.Mutex
+	records map[string]map[string]*string // Log records keyed by @ptr
+	order   []string                      // Ring of cached @ptr in insertion order
+	i       int                           // Position in order of next insertion
+}
+
+func makeRecordCache(n int) recordCache {
+	return recordCache{
+		records: make(map[string]map[string]*string, n),
+		order:   make([]string, n),
+	}
+}
+
+func (rc *recordCache) get(ptr string) (map[string]*string, bool) {
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+	record, ok := rc.records[ptr]
+	return record, ok
+}
+
+func (rc *recordCache) put(ptr string, record map[string]*string) {
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+	if _, ok := rc.records[ptr]; ok {
+		return
+	}
+	if evict := rc.order[rc.i]; evict != "" {
+		delete(rc.records, evict)
+	}
+	rc.records[ptr] = record
+	rc.order[rc.i] = ptr
+	rc.i = (rc.i + 1) % len(rc.order)
+}
@@ -0,0 +1,459 @@
+// Copyright 2022 The incite Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package incite
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewHydrator(t *testing.T) {
+	h, a, l := newTestableHydrator(t, 25)
+
+	require.NotNil(t, h)
+	assert.Equal(t, time.Second/25, h.minDelay)
+	var closer <-chan struct{}
+	closer = h.m.close
+	assert.Equal(t, closer, h.close)
+	var in <-chan *chunk = h.m.hydrate
+	assert.Equal(t, in, h.in)
+	var out chan<- *chunk = h.m.update
+	assert.Equal(t, out, h.out)
+	assert.Equal(t, "hydrator", h.name)
+	assert.Equal(t, GetLogRecord, h.action)
+	a.AssertExpectations(t)
+	l.AssertExpectations(t)
+}
+
+func TestHydrator_manipulate(t *testing.T) {
+	t.Run("Dead Stream", func(t *testing.T) {
+		h, actions, logger := newTestableHydrator(t, 1_000_000)
+		c := &chunk{
+			stream: &stream{
+				err: errors.New("gone"),
+			},
+		}
+
+		o := h.manipulate(c)
+
+		assert.Equal(t, finished, o)
+		assert.Same(t, errStopChunk, c.err)
+		actions.AssertExpectations(t)
+		logger.AssertExpectations(t)
+	})
+
+	t.Run("Live Stream", func(t *testing.T) {
+		text := "fields @ptr, x"
+		start := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+		end := start.Add(time.Minute)
+
+		testCases := []struct {
+			name     string
+			results  []Result
+			setup    func(t *testing.T, actions *mockActions, logger *mockLogger)
+			expected []outcome
+			blocks   [][]Result
+			err      error
+		}{
+			{
+				name: "No Pointers",
+				results: []Result{
+					{{"x", "1"}},
+				},
+				setup:    func(_ *testing.T, _ *mockActions, _ *mockLogger) {},
+				expected: []outcome{finished},
+				blocks: [][]Result{
+					{{{"x", "1"}}},
+				},
+			},
+			{
+				name: "Two Pointers",
+				results: []Result{
+					{{"@ptr", "p1"}, {"x", "1"}},
+					{{"x", "2"}},
+					{{"@ptr", "p2"}, {"x", "3"}},
+				},
+				setup: func(_ *testing.T, actions *mockActions, _ *mockLogger) {
+					actions.
+						On("GetLogRecordWithContext", anyContext, &cloudwatchlogs.GetLogRecordInput{LogRecordPointer: sp("p1")}).
+						Return(&cloudwatchlogs.GetLogRecordOutput{
+							LogRecord: map[string]*string{"x": sp("ignored"), "@message": sp("m1"), "@logStream": sp("s1")},
+						}, nil).
+						Once()
+					actions.
+						On("GetLogRecordWithContext", anyContext, &cloudwatchlogs.GetLogRecordInput{LogRecordPointer: sp("p2")}).
+						Return(&cloudwatchlogs.GetLogRecordOutput{
+							LogRecord: map[string]*string{"@message": sp("m2")},
+						}, nil).
+						Once()
+				},
+				expected: []outcome{inconclusive, finished},
+				blocks: [][]Result{
+					{
+						{{"@ptr", "p1"}, {"x", "1"}, {"@logStream", "s1"}, {"@message", "m1"}},
+						{{"x", "2"}},
+						{{"@ptr", "p2"}, {"x", "3"}, {"@message", "m2"}},
+					},
+				},
+			},
+			{
+				name:    "Batched",
+				results: append(plainResults(hydrateBatchSize-1), Result{{"@ptr", "p1"}}, Result{{"x", "y"}}, Result{{"@ptr", "p2"}}),
+				setup: func(_ *testing.T, actions *mockActions, _ *mockLogger) {
+					actions.
+						On("GetLogRecordWithContext", anyContext, &cloudwatchlogs.GetLogRecordInput{LogRecordPointer: sp("p1")}).
+						Return(&cloudwatchlogs.GetLogRecordOutput{
+							LogRecord: map[string]*string{"@message": sp("m1")},
+						}, nil).
+						Once()
+					actions.
+						On("GetLogRecordWithContext", anyContext, &cloudwatchlogs.GetLogRecordInput{LogRecordPointer: sp("p2")}).
+						Return(&cloudwatchlogs.GetLogRecordOutput{
+							LogRecord: map[string]*string{"@message": sp("m2")},
+						}, nil).
+						Once()
+				},
+				expected: []outcome{inconclusive, finished},
+				blocks: [][]Result{
+					append(plainResults(hydrateBatchSize-1), Result{{"@ptr", "p1"}, {"@message", "m1"}}),
+					{{{"x", "y"}}, {{"@ptr", "p2"}, {"@message", "m2"}}},
+				},
+			},
+			{
+				name: "Temporary Error",
+				results: []Result{
+					{{"@ptr", "p1"}},
+				},
+				setup: func(t *testing.T, actions *mockActions, logger *mockLogger) {
+					actions.
+						On("GetLogRecordWithContext", anyContext, &cloudwatchlogs.GetLogRecordInput{LogRecordPointer: sp("p1")}).
+						Return(nil, syscall.ECONNRESET).
+						Once()
+					logger.ExpectPrintf("incite: QueryManager(%s) %s chunk %s %q [%s..%s): %s", t.Name(),
+						"temporary failure to hydrate", "c(q)", text, start, end, "connection reset by peer")
+				},
+				expected: []outcome{temporaryError},
+				err:      &LogRecordError{"p1", "q", text, syscall.ECONNRESET},
+			},
+			{
+				name: "Permanent Error",
+				results: []Result{
+					{{"@ptr", "p1"}},
+				},
+				setup: func(t *testing.T, actions *mockActions, logger *mockLogger) {
+					actions.
+						On("GetLogRecordWithContext", anyContext, &cloudwatchlogs.GetLogRecordInput{LogRecordPointer: sp("p1")}).
+						Return(nil, errors.New("no record for you")).
+						Once()
+					logger.ExpectPrintf("incite: QueryManager(%s) %s chunk %s %q [%s..%s): %s", t.Name(),
+						"permanent failure to hydrate", "c(q)", text, start, end, "fatal error from CloudWatch Logs: no record for you")
+				},
+				expected: []outcome{finished},
+				err:      &LogRecordError{"p1", "q", text, errors.New("no record for you")},
+			},
+		}
+
+		for _, testCase := range testCases {
+			t.Run(testCase.name, func(t *testing.T) {
+				h, actions, logger := newTestableHydrator(t, 1_000_000)
+				testCase.setup(t, actions, logger)
+				s := &stream{
+					QuerySpec: QuerySpec{
+						Text:    text,
+						Hydrate: true,
+					},
+				}
+				s.more = sync.NewCond(&s.lock)
+				c := &chunk{
+					stream:  s,
+					ctx:     context.Background(),
+					chunkID: "c",
+					queryID: "q",
+					start:   start,
+					end:     end,
+					results: testCase.results,
+				}
+
+				for _, expected := range testCase.expected {
+					assert.Equal(t, expected, h.manipulate(c))
+				}
+
+				assert.Equal(t, testCase.err, c.err)
+				assert.Equal(t, testCase.blocks, s.blocks)
+				if testCase.err == nil {
+					assert.Equal(t, hydrated, c.state)
+					assert.Equal(t, end.Sub(start), c.RangeDone)
+				}
+				actions.AssertExpectations(t)
+				logger.AssertExpectations(t)
+			})
+		}
+	})
+}
+
+func TestHydrator_release(t *testing.T) {
+	h, actions, logger := newTestableHydrator(t, 1_000_000)
+	logger.ExpectPrintf("incite: QueryManager(%s) %s chunk %s %q [%s..%s)", t.Name(),
+		"releasing hydratable", "hello(world)", "bonjour monde!", time.Time{}, time.Time{})
+
+	h.release(&chunk{
+		stream: &stream{
+			QuerySpec: QuerySpec{
+				Text: "bonjour monde!",
+			},
+		},
+		chunkID: "hello",
+		queryID: "world",
+	})
+
+	actions.AssertExpectations(t)
+	logger.AssertExpectations(t)
+}
+
+func TestRecordCache(t *testing.T) {
+	rc := makeRecordCache(2)
+	a := map[string]*string{"a": sp("1")}
+	b := map[string]*string{"b": sp("2")}
+	c := map[string]*string{"c": sp("3")}
+
+	rc.put("a", a)
+	rc.put("b", b)
+	rc.put("a", c)
+	actual, ok := rc.get("a")
+	assert.True(t, ok)
+	assert.Equal(t, a, actual)
+
+	rc.put("c", c)
+	_, ok = rc.get("a")
+	assert.False(t, ok)
+	actual, ok = rc.get("b")
+	assert.True(t, ok)
+	assert.Equal(t, b, actual)
+	actual, ok = rc.get("c")
+	assert.True(t, ok)
+	assert.Equal(t, c, actual)
+}
+
+func TestQueryManager_Query_Hydrate(t *testing.T) {
+	t.Run("Invalid Input", func(t *testing.T) {
+		testCases := []struct {
+			name    string
+			actions CloudWatchLogsActions
+			preview bool
+			err     string
+		}{
+			{
+				name: "Actions.NotGetter",
+				actions: struct {
+					CloudWatchLogsActions
+				}{newMockActions(t)},
+				err: noLogRecordGetterMsg,
+			},
+			{
+				name:    "With.Preview",
+				actions: newMockActions(t),
+				preview: true,
+				err:     hydrateWithPreviewMsg,
+			},
+		}
+
+		for _, testCase := range testCases {
+			t.Run(testCase.name, func(t *testing.T) {
+				m := NewQueryManager(Config{
+					Actions: testCase.actions,
+				})
+				t.Cleanup(func() {
+					_ = m.Close()
+				})
+
+				s, err := m.Query(QuerySpec{
+					Text:    "fields @ptr",
+					Groups:  []string{"g"},
+					Start:   defaultStart,
+					End:     defaultEnd,
+					Preview: testCase.preview,
+					Hydrate: true,
+				})
+
+				assert.Nil(t, s)
+				assert.EqualError(t, err, testCase.err)
+			})
+		}
+	})
+
+	t.Run("Results Are Hydrated", func(t *testing.T) {
+		text := "fields @ptr | limit 2"
+		queryID := "hydrate-me"
+		actions := newMockActions(t)
+		actions.
+			On("StartQueryWithContext", anyContext, startQueryInput(text, defaultStart, defaultEnd, DefaultLimit, "g")).
+			Return(&cloudwatchlogs.StartQueryOutput{QueryId: &queryID}, nil).
+			Once()
+		actions.
+			On("GetQueryResultsWithContext", anyContext, &cloudwatchlogs.GetQueryResultsInput{QueryId: &queryID}).
+			Return(&cloudwatchlogs.GetQueryResultsOutput{
+				Status: sp(cloudwatchlogs.QueryStatusComplete),
+				Results: backOut([]Result{
+					{{"@ptr", "1"}},
+					{{"@ptr", "2"}},
+				}),
+			}, nil).
+			Once()
+		actions.
+			On("GetLogRecordWithContext", anyContext, &cloudwatchlogs.GetLogRecordInput{LogRecordPointer: sp("1")}).
+			Return(&cloudwatchlogs.GetLogRecordOutput{LogRecord: map[string]*string{"@message": sp("one")}}, nil).
+			Once()
+		actions.
+			On("GetLogRecordWithContext", anyContext, &cloudwatchlogs.GetLogRecordInput{LogRecordPointer: sp("2")}).
+			Return(&cloudwatchlogs.GetLogRecordOutput{LogRecord: map[string]*string{"@message": sp("two")}}, nil).
+			Once()
+		m := NewQueryManager(Config{
+			Actions: actions,
+			RPS:     lotsOfRPS,
+		})
+		t.Cleanup(func() {
+			_ = m.Close()
+		})
+
+		s, err := m.Query(QuerySpec{
+			Text:    text,
+			Groups:  []string{"g"},
+			Start:   defaultStart,
+			End:     defaultEnd,
+			Hydrate: true,
+		})
+		require.NoError(t, err)
+		require.NotNil(t, s)
+		r, err := ReadAll(s)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []Result{
+			{{"@ptr", "1"}, {"@message", "one"}},
+			{{"@ptr", "2"}, {"@message", "two"}},
+		}, r)
+		assert.Equal(t, defaultEnd.Sub(defaultStart), s.GetStats().RangeDone)
+		actions.AssertExpectations(t)
+	})
+
+	t.Run("Hydration Does Not Occupy Parallel Slot", func(t *testing.T) {
+		text := "fields @ptr | limit 1"
+		started := make(chan struct{})
+		actions := newMockActions(t)
+		actions.
+			On("StartQueryWithContext", anyContext, startQueryInput(text, defaultStart, defaultEnd, DefaultLimit, "hydrated")).
+			Return(&cloudwatchlogs.StartQueryOutput{QueryId: sp("hydrated")}, nil).
+			Once()
+		actions.
+			On("GetQueryResultsWithContext", anyContext, &cloudwatchlogs.GetQueryResultsInput{QueryId: sp("hydrated")}).
+			Return(&cloudwatchlogs.GetQueryResultsOutput{
+				Status:  sp(cloudwatchlogs.QueryStatusComplete),
+				Results: backOut([]Result{{{"@ptr", "1"}}}),
+			}, nil).
+			Once()
+		actions.
+			On("GetLogRecordWithContext", anyContext, &cloudwatchlogs.GetLogRecordInput{LogRecordPointer: sp("1")}).
+			Run(func(_ mock.Arguments) {
+				select {
+				case <-started:
+				case <-time.After(5 * time.Second):
+					t.Error("other query did not start while hydrating")
+				}
+			}).
+			Return(&cloudwatchlogs.GetLogRecordOutput{LogRecord: map[string]*string{"@message": sp("one")}}, nil).
+			Once()
+		actions.
+			On("StartQueryWithContext", anyContext, startQueryInput(text, defaultStart, defaultEnd, DefaultLimit, "other")).
+			Run(func(_ mock.Arguments) { close(started) }).
+			Return(&cloudwatchlogs.StartQueryOutput{QueryId: sp("other")}, nil).
+			Once()
+		actions.
+			On("GetQueryResultsWithContext", anyContext, &cloudwatchlogs.GetQueryResultsInput{QueryId: sp("other")}).
+			Return(&cloudwatchlogs.GetQueryResultsOutput{Status: sp(cloudwatchlogs.QueryStatusComplete)}, nil).
+			Once()
+		m := NewQueryManager(Config{
+			Actions:  actions,
+			Parallel: 1,
+			RPS:      lotsOfRPS,
+		})
+		t.Cleanup(func() {
+			_ = m.Close()
+		})
+
+		hydrated, err := m.Query(QuerySpec{
+			Text:    text,
+			Groups:  []string{"hydrated"},
+			Start:   defaultStart,
+			End:     defaultEnd,
+			Hydrate: true,
+		})
+		require.NoError(t, err)
+		other, err := m.Query(QuerySpec{
+			Text:   text,
+			Groups: []string{"other"},
+			Start:  defaultStart,
+			End:    defaultEnd,
+		})
+		require.NoError(t, err)
+		r, err := ReadAll(hydrated)
+		require.NoError(t, err)
+		_, err = ReadAll(other)
+		require.NoError(t, err)
+
+		assert.Equal(t, []Result{{{"@ptr", "1"}, {"@message", "one"}}}, r)
+		actions.AssertExpectations(t)
+	})
+}
+
+func TestMgr_feedHydrator(t *testing.T) {
+	m := &mgr{
+		hydrate: make(chan *chunk, 2),
+	}
+	chunks := []*chunk{{chunkID: "0"}, {chunkID: "1"}, {chunkID: "2"}}
+	m.toHydrate = append(m.toHydrate, chunks...)
+
+	m.feedHydrator()
+
+	assert.Equal(t, 2, m.numHydrating)
+	assert.Equal(t, chunks[2:], m.toHydrate)
+	assert.Same(t, chunks[0], <-m.hydrate)
+	assert.Same(t, chunks[1], <-m.hydrate)
+}
+
+func plainResults(n int) []Result {
+	r := make([]Result, n)
+	for i := range r {
+		r[i] = Result{{"x", strconv.Itoa(i)}}
+	}
+	return r
+}
+
+func newTestableHydrator(t *testing.T, rps int) (h *hydrator, a *mockActions, l *mockLogger) {
+	a = newMockActions(t)
+	l = newMockLogger(t)
+	h = newHydrator(&mgr{
+		Config: Config{
+			Actions: a,
+			RPS: map[CloudWatchLogsAction]int{
+				GetLogRecord: rps,
+			},
+			Logger: l,
+			Name:   t.Name(),
+		},
+		close:   make(chan struct{}),
+		hydrate: make(chan *chunk),
+		update:  make(chan *chunk),
+	})
+	return
+}
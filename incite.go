@@ -5,6 +5,7 @@
 package incite
 
 import (
+	"context"
 	"io"
 	"time"
 )
@@ -31,10 +32,70 @@ type QuerySpec struct {
 	// https://docs.aws.amazon.com/AmazonCloudWatch/latest/logs/CWL_QuerySyntax.html.
 	Text string
 
+	// QueryDefinition optionally gives the name or ID of a saved
+	// CloudWatch Logs Insights query definition from which the query
+	// text and log groups are taken.
+	//
+	// If QueryDefinition is not empty, the QueryManager fetches the
+	// query definition before starting the query. If Text is blank, the
+	// query definition's text is used instead. If Groups, GroupPrefixes,
+	// and GroupPatterns are all empty, the query definition's log groups
+	// are used instead. All other QuerySpec fields apply as usual, and
+	// are validated before the query definition is fetched.
+	//
+	// Query definitions are cached for the duration given in the
+	// QueryDefinitionCacheTTL field of Config. Because the CloudWatch
+	// Logs DescribeQueryDefinitions act cannot look up a query
+	// definition by ID, finding one by ID may require listing every
+	// query definition, so the QueryManager limits the rate of its
+	// DescribeQueryDefinitions requests.
+	//
+	// QueryDefinition may only be used if the Actions field of the
+	// QueryManager's Config implements QueryDefinitionActions. If no
+	// query definition matches, the query operation fails with an error
+	// wrapping ErrQueryDefinitionNotFound.
+	QueryDefinition string
+
 	// Groups lists the names of the CloudWatch Logs log groups to be
-	// queried. It may not be empty.
+	// queried. It may only be empty if at least one of GroupPrefixes or
+	// GroupPatterns is given.
 	Groups []string
 
+	// GroupPrefixes optionally lists log group name prefixes. Every log
+	// group whose name begins with one of the prefixes is added to the
+	// log groups queried.
+	//
+	// GroupPrefixes may only be used if the Actions field of the
+	// QueryManager's Config implements LogGroupDescriber. Prefixes are
+	// resolved into log group names, using the CloudWatch Logs
+	// DescribeLogGroups act, before any chunks of the query are
+	// started. If GroupPrefixes and GroupPatterns, taken together, do
+	// not match any log group, and Groups is empty, the query operation
+	// fails with an error. If Groups and the resolved log group names
+	// together number more than MaxGroups, the query operation also
+	// fails with an error.
+	//
+	// DescribeLogGroups requests are rate limited in the same way as the
+	// other CloudWatch Logs actions, using the DescribeLogGroups entries
+	// in the RPS, Burst, and RPSQuota fields of Config and, if set, the
+	// RateLimiter. Resolved log group names are cached by the
+	// QueryManager for the duration given in the GroupCacheTTL field of
+	// Config.
+	GroupPrefixes []string
+
+	// GroupPatterns optionally lists glob patterns, in the syntax of
+	// path.Match, for log group names. Every log group whose name
+	// matches one of the patterns is added to the log groups queried.
+	// For example, the pattern "/aws/lambda/orders-*" matches the log
+	// groups of all Lambda functions whose names begin with "orders-".
+	//
+	// GroupPatterns is subject to the same requirements and caching
+	// behavior as GroupPrefixes. The portion of each pattern up to its
+	// first special character is used as a prefix when describing log
+	// groups, so patterns that begin with a literal prefix resolve
+	// more efficiently.
+	GroupPatterns []string
+
 	// Start specifies the beginning of the time range to query,
 	// inclusive of Start itself.
 	//
@@ -154,9 +215,31 @@ type QuerySpec struct {
 	// The Priority field may be set to any valid int value. A query
 	// whose Priority number is lower is allocated CloudWatch Logs
 	// query capacity in preference to a query whose Priority number is
-	// higher, but only within the same QueryManager.
+	// higher, but only within the same QueryManager. The Scheduling and
+	// PriorityAging fields of Config control exactly how Priority is
+	// used.
 	Priority int
 
+	// Tenant optionally labels the query operation as belonging to a
+	// tenant, or group, sharing the QueryManager. The QueryManager
+	// shares its Parallel slots between tenants which have chunks
+	// waiting to start, so that each tenant receives a share
+	// proportional to its weight in the TenantWeights field of Config.
+	// Priority only orders query operations within the same tenant.
+	//
+	// The default empty Tenant is a tenant like any other. Use the
+	// GetTenantStats method of the TenantStatsGetter interface, which
+	// the QueryManager returned by NewQueryManager implements, to get
+	// the statistics of all query operations belonging to a tenant.
+	Tenant string
+
+	// MaxParallel optionally limits the number of chunks of the query
+	// operation which the QueryManager runs at the same time, leaving
+	// the rest of its Parallel slots free for other query operations.
+	// If MaxParallel is zero or negative, the query operation may use
+	// every Parallel slot.
+	MaxParallel int
+
 	// SplitUntil specifies if, and how, the query time range, or the
 	// query chunks, will be dynamically split into sub-chunks when
 	// they produce the maximum number of results that CloudWatch Logs
@@ -168,7 +251,8 @@ type QuerySpec struct {
 	// sub-millisecond granularity).
 	//
 	// To use splitting, you must also set Limit to MaxLimit and
-	// Preview must be false.
+	// Preview must be false. If SplitOnTimeout or SplitOnFailed is
+	// set, Limit may be less than MaxLimit.
 	//
 	// When splitting is enabled and, when a time range produces
 	// MaxLimit results, the range is split into sub-chunks no smaller
@@ -179,6 +263,114 @@ type QuerySpec struct {
 	// smaller than SplitUntil or the time range produces fewer than
 	// MaxLimit results.
 	SplitUntil time.Duration
+
+	// SplitOnTimeout optionally requests that a chunk whose Insights
+	// query times out be split into sub-chunks and retried, instead of
+	// failing the stream with a TerminalQueryStatusError.
+	//
+	// Insights queries which run past the CloudWatch Logs execution
+	// limit end in the "Timeout" status. Since a query over a shorter
+	// time range scans less data, splitting the chunk allows a heavy
+	// query to complete. The chunk is split in the same way, and with
+	// the same lower limit, as a chunk which produces MaxLimit results,
+	// so SplitUntil must be set to enable SplitOnTimeout. A chunk which
+	// times out and is no longer than SplitUntil fails as usual.
+	SplitOnTimeout bool
+
+	// SplitOnFailed optionally requests that a chunk whose Insights
+	// query ends in the "Failed" status, after it has been restarted as
+	// many times as the RetryPolicy allows, be split into sub-chunks in
+	// the same way as for SplitOnTimeout. SplitUntil must be set to
+	// enable SplitOnFailed.
+	SplitOnFailed bool
+
+	// Hydrate optionally requests that each query result be hydrated
+	// with the full log record it was drawn from.
+	//
+	// Insights results contain only the fields projected by the query
+	// text, but every result based on a log event also carries an @ptr
+	// field. If Hydrate is true, the QueryManager uses the CloudWatch
+	// Logs GetLogRecord act to fetch the full log record for each @ptr
+	// and appends every field of the log record that is not already
+	// present in the result to the end of the result, in ascending
+	// order of field name. Results without an @ptr field are delivered
+	// unchanged.
+	//
+	// Hydration of a chunk begins once the chunk's Insights query is
+	// complete. Because GetLogRecord uses no Insights query concurrency,
+	// a hydrating chunk does not occupy one of the Parallel slots of the
+	// QueryManager. Hydrated results are delivered to the stream in
+	// batches as they become available, so if hydration fails partway
+	// through a chunk, some of the chunk's results may already have been
+	// delivered. GetLogRecord requests are rate limited using the
+	// GetLogRecord entry of the RPS field of Config, so hydrating large
+	// result sets can be slow.
+	//
+	// To use hydration, the Actions field of the QueryManager's Config
+	// must implement LogRecordGetter, and Preview must be false.
+	Hydrate bool
+
+	// AllowPartial optionally requests that the stream tolerate chunks
+	// which fail permanently.
+	//
+	// Normally, when one chunk of the query fails, for example because
+	// CloudWatch Logs Insights reports it in the "Failed" or "Timeout"
+	// status, the whole stream fails and its remaining chunks are
+	// abandoned. If AllowPartial is true, the stream instead records
+	// the failed chunk's time range and carries on delivering results
+	// from its other chunks. When every chunk is done, Stream.Read
+	// returns a *PartialResultsError listing the failed time ranges in
+	// place of io.EOF. If no chunk failed, Stream.Read returns io.EOF
+	// as usual.
+	AllowPartial bool
+
+	// Timeout optionally bounds how long the whole query may take. If
+	// Timeout is positive and the stream has not ended Timeout after
+	// the query was started with QueryManager.Query, the stream fails
+	// with a *DeadlineError and its running chunks are stopped. Time
+	// the stream spends paused, using the Pauser interface, does not
+	// count towards Timeout.
+	Timeout time.Duration
+
+	// ChunkTimeout optionally bounds how long the CloudWatch Logs
+	// Insights query for each chunk may run, measured from the time the
+	// chunk was started.
+	//
+	// If ChunkTimeout is positive, a chunk still in the "Scheduled" or
+	// "Running" status ChunkTimeout after it was started is stopped.
+	// The chunk is then split into sub-chunks if SplitOnTimeout allows
+	// it, or restarted if the RetryPolicy allows it. Otherwise, the
+	// chunk fails with a *DeadlineError.
+	ChunkTimeout time.Duration
+}
+
+// AttachSpec specifies the parameters for attaching to an existing
+// CloudWatch Logs Insights query using the Attach method of Attacher.
+type AttachSpec struct {
+	// Text optionally contains the text of the existing Insights query.
+	// It is used only in log messages and errors and has no effect on
+	// the query, which is already running.
+	Text string
+
+	// Preview optionally requests preview results from the running
+	// query. It has the same meaning as the Preview field of QuerySpec.
+	Preview bool
+
+	// Priority optionally gives the attached query a higher or lower
+	// priority with regard to other query operations managed by the
+	// same QueryManager. It has the same meaning as the Priority field
+	// of QuerySpec.
+	Priority int
+
+	// Tenant optionally labels the attached query as belonging to a
+	// tenant. It has the same meaning as the Tenant field of QuerySpec.
+	Tenant string
+
+	// StopOnClose optionally requests that the existing Insights query
+	// be stopped, using the CloudWatch Logs StopQuery act, if the Stream
+	// is closed, or fails, before the query completes. If StopOnClose
+	// is false, the query is left running for its owner.
+	StopOnClose bool
 }
 
 // Stats records metadata about query execution. When returned from a
@@ -260,6 +452,25 @@ type Stats struct {
 	// produces MaxLimit results is too small to split further, its
 	// duration will be added to RangeMaxed.
 	RangeMaxed time.Duration
+	// RangePreempted is a metric collected by Incite which tallies the
+	// aggregate amount of query time whose Insights queries were
+	// stopped before finishing, to give their Parallel slots to more
+	// urgent query operations, and were later restarted. Preemption only
+	// happens if the Preemption field of Config is positive.
+	RangePreempted time.Duration
+	// TimePaused is a metric collected by Incite which tallies the
+	// wall-clock time the query operation spent paused by the Pause
+	// method of Pauser. For a QueryManager, this field only includes
+	// pauses which have ended.
+	TimePaused time.Duration
+
+	// Parallel is a gauge, rather than a metric, which is only set in
+	// the Stats returned by a QueryManager whose Config has
+	// AdaptiveParallel set. It gives the effective number of parallel
+	// Insights queries the QueryManager currently allows itself to run,
+	// which is never more than the Parallel field of the Config. In all
+	// other cases, including Stream stats, Parallel is zero.
+	Parallel int
 }
 
 func (s *Stats) add(t *Stats) {
@@ -272,6 +483,8 @@ func (s *Stats) add(t *Stats) {
 	s.RangeDone += t.RangeDone
 	s.RangeFailed += t.RangeFailed
 	s.RangeMaxed += t.RangeMaxed
+	s.RangePreempted += t.RangePreempted
+	s.TimePaused += t.TimePaused
 }
 
 // StatsGetter provides access to the Insights query statistics
@@ -285,6 +498,42 @@ type StatsGetter interface {
 	GetStats() Stats
 }
 
+// Reconfigurer changes the Parallel, RPS, and Logger fields of a
+// QueryManager's Config while the QueryManager is running, for example
+// in response to a feature flag or a SIGHUP.
+//
+// The QueryManager returned by NewQueryManager implements Reconfigurer,
+// so its SetParallel, SetRPS, and SetLogger methods may be reached with
+// a type assertion. The new values are interpreted in the same way as
+// the corresponding Config fields: SetParallel caps its value at the
+// query concurrency quota, and SetRPS uses the default RPS for the
+// action if its value is zero or negative. Chunks already in flight are
+// never dropped. If Parallel decreases, no new chunks are started until
+// fewer than the new value are in flight. A new RPS takes effect from
+// the next request for the action. SetParallel and SetRPS return
+// ErrClosed if the QueryManager is closed, and SetRPS returns an error
+// if the action is not a known CloudWatchLogsAction.
+type Reconfigurer interface {
+	SetParallel(n int) error
+	SetRPS(action CloudWatchLogsAction, rps int) error
+	SetLogger(logger Logger)
+}
+
+// TenantStatsGetter provides access to the Insights query statistics
+// of the query operations belonging to one tenant, as named by the
+// Tenant field of QuerySpec.
+//
+// The QueryManager returned by NewQueryManager implements
+// TenantStatsGetter, so its GetTenantStats method may be reached with
+// a type assertion. GetTenantStats returns the running sum of all
+// statistics for the tenant's queries since the QueryManager was
+// created, or zero statistics for an unknown tenant. To bound its
+// memory use, the QueryManager only remembers the statistics of the
+// MaxTenantStats tenants whose statistics were most recently updated.
+type TenantStatsGetter interface {
+	GetTenantStats(tenant string) Stats
+}
+
 // QueryManager executes one or more CloudWatch Logs Insights queries,
 // optionally executing simultaneous queries in parallel.
 //
@@ -318,6 +567,37 @@ type QueryManager interface {
 	Query(QuerySpec) (Stream, error)
 }
 
+// Attacher attaches a result Stream to an Insights query which was
+// started outside of Incite, for example by another system or in the
+// CloudWatch Logs console.
+//
+// The QueryManager returned by NewQueryManager implements Attacher, so
+// its Attach method may be reached with a type assertion. The
+// QueryManager polls the attached query, and translates its results,
+// exactly as it would for a query it started itself, but does not
+// restart, split, or chunk it.
+type Attacher interface {
+	Attach(queryID string, spec AttachSpec) (Stream, error)
+}
+
+// Shutdowner closes a QueryManager gracefully.
+//
+// The QueryManager returned by NewQueryManager implements Shutdowner,
+// so its Shutdown method may be reached with a type assertion.
+// Shutdown stops accepting new queries, waits for running queries to
+// finish, or for its context to be done, whichever happens first, and
+// then closes the QueryManager, stopping any Insights queries still
+// running. Shutdown only returns when all StopQuery requests are done,
+// so no orphaned Insights queries are left behind. If the context was
+// done before the running queries finished, Shutdown returns the
+// context error. Streams still open when Shutdown returns, for example
+// because they are paused, are closed and their Read methods return
+// ErrClosed. Shutdown returns ErrClosed if the QueryManager was already
+// closed or shut down.
+type Shutdowner interface {
+	Shutdown(ctx context.Context) error
+}
+
 // Result represents a single result row from a CloudWatch Logs Insights
 // query.
 type Result []ResultField
@@ -389,26 +669,57 @@ type Stream interface {
 	// • StartQueryError
 	// • TerminalQueryStatusError
 	// • UnexpectedQueryError
+	// • LogRecordError
 	Read(p []Result) (n int, err error)
 }
 
+// Pauser pauses and resumes a query operation.
+//
+// The Streams returned by the Query and Attach methods of the
+// QueryManager returned by NewQueryManager implement Pauser, so their
+// Pause and Resume methods may be reached with a type assertion.
+type Pauser interface {
+	// Pause stops the QueryManager from starting any more chunks of the
+	// query operation until Resume is called. If stopRunning is true,
+	// the Insights queries of chunks already running are also stopped,
+	// and the chunks are restarted when the Stream is resumed. Chunks of
+	// previewable queries are never stopped, since some of their
+	// results may already have been read. The time spent paused is
+	// tallied in the TimePaused field of Stats, and does not count
+	// towards the Timeout in the Stream's QuerySpec.
+	//
+	// Pausing a Stream which is already paused, or which has no more
+	// chunks to run, has no effect. A paused Stream does not hold up
+	// the QueryManager's Shutdown method. Pause returns ErrClosed if
+	// the Stream is closed.
+	Pause(stopRunning bool) error
+
+	// Resume continues a query operation paused by Pause from where it
+	// left off. Resuming a Stream which is not paused has no effect.
+	// Resume returns ErrClosed if the Stream is closed.
+	Resume() error
+}
+
 const (
-	// QueryConcurrencyQuotaLimit contains the CloudWatch Logs Query
-	// Concurrency service quota limit as documented at
+	// QueryConcurrencyQuotaLimit contains the default CloudWatch Logs
+	// Query Concurrency service quota limit as documented at
 	// https://docs.aws.amazon.com/AmazonCloudWatch/latest/logs/cloudwatch_limits_cwl.html.
 	//
 	// The documented service quota may increase over time, in which case
-	// this value should be updated to match the documentation.
+	// this value should be updated to match the documentation. If your
+	// AWS account has a raised quota, set the QueryConcurrencyQuota
+	// field of Config instead.
 	QueryConcurrencyQuotaLimit = 10
 
 	// DefaultParallel is the default maximum number of parallel
 	// CloudWatch Logs Insights queries a QueryManager will attempt to
-	// run at any one time.
+	// run at any one time, if the QueryConcurrencyQuota field of its
+	// Config is not set.
 	//
 	// The default value is set to slightly less than the service quota
 	// limit to leave some concurrency available for other users even if
 	// the QueryManager is at maximum capacity.
-	DefaultParallel = QueryConcurrencyQuotaLimit - 2
+	DefaultParallel = QueryConcurrencyQuotaLimit - quotaHeadroom
 
 	// DefaultLimit is the default result count limit used if the Limit
 	// field of a QuerySpec is zero or negative.
@@ -417,8 +728,107 @@ const (
 	// MaxLimit is the maximum value the result count limit field in a
 	// QuerySpec may be set to.
 	MaxLimit = 10000
+
+	// MaxGroups is the maximum number of log groups a single query may
+	// cover, after the GroupPrefixes and GroupPatterns of its QuerySpec
+	// are resolved into log group names. It matches the number of log
+	// groups the CloudWatch Logs StartQuery act accepts.
+	MaxGroups = 50
+
+	// DefaultMaxBackoff is the default maximum delay between retries
+	// used if the Max field of Backoff is zero or negative.
+	DefaultMaxBackoff = 10 * time.Second
+
+	// DefaultCircuitMinRequests is the default minimum number of
+	// requests used if the MinRequests field of CircuitBreaker is zero
+	// or negative.
+	DefaultCircuitMinRequests = 20
+
+	// DefaultCircuitWindow is the default failure counting window used
+	// if the Window field of CircuitBreaker is zero or negative.
+	DefaultCircuitWindow = time.Minute
+
+	// DefaultCircuitCooldown is the default cooldown used if the
+	// Cooldown field of CircuitBreaker is zero or negative.
+	DefaultCircuitCooldown = 30 * time.Second
+
+	// DefaultGroupCacheTTL is the default length of time a QueryManager
+	// caches the log group names resolved from the GroupPrefixes and
+	// GroupPatterns fields of a QuerySpec.
+	DefaultGroupCacheTTL = 5 * time.Minute
+
+	// DefaultQueryDefinitionCacheTTL is the default length of time a
+	// QueryManager caches the query definitions looked up using the
+	// QueryDefinition field of a QuerySpec.
+	DefaultQueryDefinitionCacheTTL = 5 * time.Minute
+
+	// DefaultMaxTenantStats is the default number of tenants whose
+	// statistics a QueryManager remembers, used if the MaxTenantStats
+	// field of Config is zero or negative.
+	DefaultMaxTenantStats = 1000
 )
 
+// Backoff configures exponential backoff with jitter for retrying
+// CloudWatch Logs requests which fail with a temporary error.
+//
+// After the nth consecutive temporary error for the same query chunk,
+// the chunk waits for a random delay between d/2 and d before it is
+// retried, where d is Base doubled n-1 times, but never more than Max.
+// Meanwhile, the QueryManager keeps working on other chunks.
+//
+// In addition, when CloudWatch Logs throttles a request, the whole
+// worker making requests of that type slows down: an extra delay,
+// starting at Base and doubling with each further throttling error up
+// to Max, is added between all of its requests. The extra delay halves
+// with each request that is not throttled, so the worker returns to
+// full speed once throttling stops.
+type Backoff struct {
+	// Base is the delay before the first retry. If Base is zero or
+	// negative, backoff is disabled.
+	Base time.Duration
+	// Max is the longest delay between retries. If Max is zero or
+	// negative, DefaultMaxBackoff is used.
+	Max time.Duration
+}
+
+// CircuitBreaker configures a QueryManager's circuit breaker, which
+// stops the QueryManager from piling up failing requests while
+// CloudWatch Logs is degraded.
+//
+// The circuit breaker counts StartQuery and GetQueryResults requests
+// over a window of time. Requests which fail with an error suggesting
+// the service is degraded, such as service unavailability or network
+// timeouts, are failures. Throttled requests are not counted. When the
+// fraction of failed requests in the window reaches FailureRate, and
+// at least MinRequests requests were made, the circuit opens.
+//
+// While the circuit is open, QueryManager.Query returns a
+// *CircuitOpenError, and every query chunk waiting to start fails with
+// a StartQueryError wrapping a *CircuitOpenError. After Cooldown, the
+// circuit goes half-open and lets a single StartQuery request through
+// as a probe, while other chunks wait to start until the probe's result
+// is known. If the probe succeeds, the circuit closes and the
+// QueryManager resumes normal work. If it fails, the circuit opens
+// again for another Cooldown.
+type CircuitBreaker struct {
+	// FailureRate is the fraction of failed requests, greater than
+	// zero and at most one, at which the circuit opens. If FailureRate
+	// is zero or negative, the circuit breaker is disabled.
+	FailureRate float64
+	// MinRequests is the minimum number of requests in the window
+	// before the circuit may open. If MinRequests is zero or negative,
+	// DefaultCircuitMinRequests is used.
+	MinRequests int
+	// Window is the length of the window over which requests are
+	// counted. If Window is zero or negative, DefaultCircuitWindow is
+	// used.
+	Window time.Duration
+	// Cooldown is how long the circuit stays open before the probe
+	// request. If Cooldown is zero or negative, DefaultCircuitCooldown
+	// is used.
+	Cooldown time.Duration
+}
+
 // Config provides the NewQueryManager function with the information it
 // needs to construct a new QueryManager.
 type Config struct {
@@ -441,8 +851,11 @@ type Config struct {
 	// account and region.
 	//
 	// If set to a positive number then that exact number is used as the
-	// parallelism factor. If set to zero or a negative number then
-	// DefaultParallel is used instead.
+	// parallelism factor, unless it exceeds the query concurrency quota
+	// given in QueryConcurrencyQuota, in which case the quota is used. If
+	// set to zero or a negative number then a default value slightly
+	// less than the quota is used instead: when QueryConcurrencyQuota is
+	// not set, this default is DefaultParallel.
 	//
 	// Parallel gives the upper limit on the number of Insights queries
 	// the QueryManager may have open at any one time. The actual number
@@ -458,6 +871,35 @@ type Config struct {
 	// operation.
 	Parallel int
 
+	// QueryConcurrencyQuota optionally specifies the CloudWatch Logs
+	// Query Concurrency service quota of the AWS account and region in
+	// which the QueryManager runs queries. Set this field if your
+	// account has a quota higher than the default service quota limit.
+	//
+	// If QueryConcurrencyQuota is zero, QueryConcurrencyQuotaLimit is
+	// used. If it is negative, NewQueryManager panics.
+	QueryConcurrencyQuota int
+
+	// AdaptiveParallel optionally enables adaptive concurrency control.
+	// If AdaptiveParallel is true, the QueryManager treats Parallel as
+	// an upper limit rather than a fixed target, and adjusts the number
+	// of Insights queries it runs using an additive increase,
+	// multiplicative decrease (AIMD) strategy: each time CloudWatch Logs
+	// rejects a query with a LimitExceededException, the effective
+	// parallelism is halved (but never goes below one), and after each
+	// run of successfully started queries as long as the effective
+	// parallelism, it is increased by one, up to Parallel. A query
+	// rejected with a LimitExceededException is retried after a delay
+	// which grows with each rejection, using the Backoff field of Config
+	// if it is enabled, and is not failed for being rejected too often.
+	//
+	// AdaptiveParallel is useful when other humans or systems run
+	// Insights queries in the same AWS account and region, since their
+	// queries count against the same service quota. The current
+	// effective parallelism is reported in the Parallel field of the
+	// QueryManager's Stats.
+	AdaptiveParallel bool
+
 	// RPS optionally specifies the maximum number of requests to the
 	// CloudWatch Logs web service which the QueryManager may make in
 	// each one-second period for each CloudWatch Logs act. The
@@ -466,17 +908,138 @@ type Config struct {
 	// from being throttled by the web service.
 	//
 	// If RPS has a missing, zero, or negative number for any required
-	// CloudWatch Logs act, the value specified in RPSDefaults is
-	// used instead. The default behavior should be adequate for many
-	// use cases, so you typically will not need to set this field
-	// explicitly.
+	// CloudWatch Logs act, a default value slightly less than the RPS
+	// quota for the act given in RPSQuota is used instead: when RPSQuota
+	// is not set, this default is the value specified in RPSDefaults.
+	// The default behavior should be adequate for many use cases, so
+	// you typically will not need to set this field explicitly.
 	//
 	// The values in RPS should ideally not exceed the corresponding
-	// values in RPSQuotaLimits as this will almost certainly result in
-	// throttling, worse performance, and your application being a "bad
-	// citizen" affecting other users of the same AWS account.
+	// RPS quotas given in RPSQuota, as this will almost certainly
+	// result in throttling, worse performance, and your application
+	// being a "bad citizen" affecting other users of the same AWS
+	// account.
 	RPS map[CloudWatchLogsAction]int
 
+	// Burst optionally specifies, for each CloudWatch Logs act, the
+	// maximum number of requests the QueryManager may make in a quick
+	// burst after a period of not using the act. Bursting lowers the
+	// latency of short interactive queries, which otherwise wait for
+	// the full 1/RPS delay between every request.
+	//
+	// If Burst has a missing, zero, negative, or one value for an act,
+	// the QueryManager does not burst and always leaves at least 1/RPS
+	// seconds between requests for the act. Otherwise, requests for the
+	// act are regulated by a token bucket which holds up to Burst
+	// tokens and refills at the RPS rate, so the long-run request rate
+	// still does not exceed RPS. So that no one-second window can exceed
+	// the RPS quota for the act given in RPSQuota, Burst values larger
+	// than the quota minus the act's RPS are reduced to that difference,
+	// and if the difference is one or less, the act does not burst.
+	Burst map[CloudWatchLogsAction]int
+
+	// MaxInFlight optionally specifies the maximum number of
+	// CloudWatch Logs requests which each of the QueryManager's
+	// internal workers (one each for starting, polling, and stopping
+	// queries, and for hydrating results) may have in flight at the
+	// same time.
+	//
+	// If MaxInFlight is zero, negative, or one, each worker waits for a
+	// request to finish before making the next one, so the achievable
+	// request rate is bounded by request latency as well as by RPS: for
+	// example, with a request latency of 500ms, a worker cannot make
+	// more than 2 requests per second. Setting MaxInFlight higher lets
+	// the request rate reach the RPS limit even when latency is high.
+	//
+	// Because requests for different query chunks may then finish in a
+	// different order than they were made, setting MaxInFlight above
+	// one can change the order in which a chunked query's results are
+	// delivered to its Stream.
+	MaxInFlight int
+
+	// Backoff optionally specifies how long the QueryManager waits
+	// before retrying a CloudWatch Logs request which failed with a
+	// temporary error, such as throttling. If Backoff is the zero
+	// value, failed requests are retried as soon as the RPS limit
+	// allows.
+	Backoff Backoff
+
+	// RetryPolicy optionally decides whether, and after how long, to
+	// retry CloudWatch Logs requests which fail, and whether to restart
+	// queries which end in the "Failed" status. If RetryPolicy is nil,
+	// DefaultRetryPolicy is used.
+	RetryPolicy RetryPolicy
+
+	// Scheduling optionally selects how the QueryManager shares its
+	// query capacity between streams with chunks waiting to start. The
+	// default is StrictPriority.
+	Scheduling SchedulingPolicy
+
+	// PriorityAging optionally prevents low-priority streams from
+	// starving under the StrictPriority scheduling policy. If
+	// PriorityAging is positive, the effective Priority number of a
+	// stream waiting to start its next chunk decreases by one for
+	// every PriorityAging the stream waits. For example, if
+	// PriorityAging is one second, a stream with Priority 5 which has
+	// waited five seconds is on an equal footing with a stream of
+	// Priority 0 which has only just started waiting.
+	PriorityAging time.Duration
+
+	// TenantWeights optionally weights the share of the Parallel slots
+	// given to each tenant, as named by the Tenant field of QuerySpec.
+	// While several tenants have chunks waiting to start, each receives
+	// Parallel slots in proportion to its weight: a tenant of weight 2
+	// gets twice as many slots as a tenant of weight 1. A tenant which
+	// is not in TenantWeights, or whose weight is zero, has weight 1.
+	// Weights may not be negative.
+	TenantWeights map[string]int
+
+	// MaxTenantStats optionally limits the number of tenants whose
+	// statistics the QueryManager remembers for its GetTenantStats
+	// method. When statistics for a new tenant would exceed the limit,
+	// the statistics of the tenant least recently updated are
+	// forgotten. If MaxTenantStats is zero or negative,
+	// DefaultMaxTenantStats is used.
+	MaxTenantStats int
+
+	// Preemption optionally allows urgent query operations to take a
+	// Parallel slot from a running chunk of a less urgent query
+	// operation, instead of waiting for the chunk to finish.
+	//
+	// If Preemption is positive and every Parallel slot is in use, then
+	// when a chunk waits to start whose Priority number is at least
+	// Preemption lower than the Priority number of a running chunk of
+	// the same tenant, the running chunk's Insights query is stopped and
+	// the chunk is queued to restart later. Chunks of previewable and
+	// attached queries are never preempted. The RangePreempted field of
+	// Stats tallies the time range of preempted chunks.
+	Preemption int
+
+	// CircuitBreaker optionally configures a circuit breaker which
+	// fails queries fast while CloudWatch Logs is degraded. If
+	// CircuitBreaker is the zero value, there is no circuit breaker.
+	CircuitBreaker CircuitBreaker
+
+	// RateLimiter optionally specifies a RateLimiter which the
+	// QueryManager shares with other QueryManagers using the same AWS
+	// account and region. If RateLimiter is not nil, the QueryManager
+	// waits for permission from it before every CloudWatch Logs
+	// request, in addition to respecting its own RPS and Burst limits.
+	// Use NewRateLimiter to create a RateLimiter shared within one
+	// process.
+	RateLimiter RateLimiter
+
+	// RPSQuota optionally specifies the CloudWatch Logs service quota
+	// on requests per second for each CloudWatch Logs act in the AWS
+	// account and region in which the QueryManager runs queries. Set
+	// this field if your account has raised RPS quotas.
+	//
+	// If RPSQuota has a missing or zero value for any CloudWatch Logs
+	// act, the value specified in RPSQuotaLimits is used instead. If
+	// any value is negative, or any key is not a known
+	// CloudWatchLogsAction, NewQueryManager panics.
+	RPSQuota map[CloudWatchLogsAction]int
+
 	// Logger optionally specifies a logging object to which the
 	// QueryManager can send log messages about queries it is managing.
 	// This value may be left nil to skip logging altogether.
@@ -489,4 +1052,50 @@ type Config struct {
 	// Other than being used in logging, this field has no effect on the
 	// QueryManager's behavior.
 	Name string
+
+	// GroupCacheTTL optionally specifies how long the QueryManager may
+	// cache log group names resolved from the GroupPrefixes and
+	// GroupPatterns fields of a QuerySpec before describing the log
+	// groups again.
+	//
+	// If GroupCacheTTL is zero, DefaultGroupCacheTTL is used. If it is
+	// negative, resolved log group names are not cached.
+	GroupCacheTTL time.Duration
+
+	// QueryDefinitionCacheTTL optionally specifies how long the
+	// QueryManager may cache a query definition looked up using the
+	// QueryDefinition field of a QuerySpec before looking it up again.
+	// Changes to a saved query definition may therefore take up to
+	// QueryDefinitionCacheTTL to affect new queries.
+	//
+	// If QueryDefinitionCacheTTL is zero, DefaultQueryDefinitionCacheTTL
+	// is used. If it is negative, query definitions are not cached.
+	QueryDefinitionCacheTTL time.Duration
+
+	// QueryMarker optionally specifies a marker which identifies the
+	// Insights queries started by the QueryManager. If QueryMarker is
+	// not empty, the QueryManager appends an Insights comment line
+	// containing the marker to the text of every query it starts. The
+	// marker may not contain line breaks.
+	//
+	// Marked queries can later be recognized, using the CloudWatch Logs
+	// DescribeQueries act, and stopped if they were orphaned by a
+	// process which crashed or exited without closing its QueryManager.
+	// To make this possible, the marker should identify the application
+	// instance and be stable across restarts: for example a host name or
+	// a task slot number. Two QueryManagers which are alive at the same
+	// time should never share a marker.
+	QueryMarker string
+
+	// StopOrphans optionally requests that the new QueryManager stop
+	// all running Insights queries started, before it was created, by
+	// an earlier QueryManager having the same QueryMarker.
+	//
+	// When StopOrphans is true, the new QueryManager finds orphaned
+	// queries in the background using the CloudWatch Logs
+	// DescribeQueries act and stops them, giving them priority over new
+	// chunks within the Parallel limit. If StopOrphans is true, then
+	// QueryMarker must not be empty and Actions must implement
+	// QueryDescriber, or NewQueryManager panics.
+	StopOrphans bool
 }
@@ -410,7 +410,7 @@ var scenarios = []queryScenario{
 								{"@MyField", "goodbye"},
 							},
 						},
-						stats: &Stats{1, 2, 3, 0, 0, 0, 0, 0},
+						stats: &Stats{BytesScanned: 1, RecordsMatched: 2, RecordsScanned: 3},
 					},
 				},
 			},
@@ -462,7 +462,7 @@ var scenarios = []queryScenario{
 					},
 					{
 						status: cloudwatchlogs.QueryStatusRunning,
-						stats:  &Stats{3, 0, 1, 0, 0, 0, 0, 0},
+						stats:  &Stats{BytesScanned: 3, RecordsScanned: 1},
 					},
 					{
 						err: cwlErr(cloudwatchlogs.ErrCodeServiceUnavailableException, "a blip in service"),
@@ -475,7 +475,7 @@ var scenarios = []queryScenario{
 								{"MyField", "world"},
 							},
 						},
-						stats: &Stats{99, 98, 97, 0, 0, 0, 0, 0},
+						stats: &Stats{BytesScanned: 99, RecordsMatched: 98, RecordsScanned: 97},
 					},
 					{
 						err: cwlErr("throttling has occurred", "and you were the recipient of the throttling"),
@@ -492,7 +492,7 @@ var scenarios = []queryScenario{
 								{"MyField", "world"},
 							},
 						},
-						stats: &Stats{100, 99, 98, 0, 0, 0, 0, 0},
+						stats: &Stats{BytesScanned: 100, RecordsMatched: 99, RecordsScanned: 98},
 					},
 				},
 			},
@@ -649,7 +649,7 @@ var scenarios = []queryScenario{
 						results: []Result{
 							{{"Foo", "Foo.0.0"}, {"Bar", "Bar.0.0"}, {"@ptr", "0"}},
 						},
-						stats: &Stats{1, 2, 3, 0, 0, 0, 0, 0},
+						stats: &Stats{BytesScanned: 1, RecordsMatched: 2, RecordsScanned: 3},
 					},
 					{
 						status: cloudwatchlogs.QueryStatusRunning,
@@ -657,14 +657,14 @@ var scenarios = []queryScenario{
 							{{"Foo", "Foo.0.0"}, {"Bar", "Bar.0.0"}, {"@ptr", "0"}},
 							{{"Foo", "Foo.1.0"}, {"Bar", "Bar.1.0"}, {"@ptr", "1"}},
 						},
-						stats: &Stats{2, 4, 6, 0, 0, 0, 0, 0},
+						stats: &Stats{BytesScanned: 2, RecordsMatched: 4, RecordsScanned: 6},
 					},
 					{
 						status: cloudwatchlogs.QueryStatusRunning,
 						results: []Result{
 							{{"Foo", "Foo.1.0"}, {"Bar", "Bar.1.0"}, {"@ptr", "1"}},
 						},
-						stats: &Stats{3, 6, 9, 0, 0, 0, 0, 0},
+						stats: &Stats{BytesScanned: 3, RecordsMatched: 6, RecordsScanned: 9},
 					},
 					{
 						status: cloudwatchlogs.QueryStatusRunning,
@@ -672,7 +672,7 @@ var scenarios = []queryScenario{
 							{{"Foo", "Foo.1.0"}, {"Bar", "Bar.1.0"}, {"@ptr", "1"}},
 							{{"Foo", "Foo.2.0"}, {"Bar", "Bar.2.0"}, {"@ptr", "2"}},
 						},
-						stats: &Stats{4, 8, 12, 0, 0, 0, 0, 0},
+						stats: &Stats{BytesScanned: 4, RecordsMatched: 8, RecordsScanned: 12},
 					},
 					{
 						status: cloudwatchlogs.QueryStatusRunning,
@@ -681,7 +681,7 @@ var scenarios = []queryScenario{
 							{{"Foo", "Foo.2.0"}, {"Bar", "Bar.2.0"}, {"@ptr", "2"}},
 							{{"Foo", "Foo.3.0"}, {"Bar", "Bar.3.0"}, {"@ptr", "3"}},
 						},
-						stats: &Stats{5, 10, 15, 0, 0, 0, 0, 0},
+						stats: &Stats{BytesScanned: 5, RecordsMatched: 10, RecordsScanned: 15},
 					},
 					{
 						status: cloudwatchlogs.QueryStatusComplete,
@@ -691,7 +691,7 @@ var scenarios = []queryScenario{
 							{{"Foo", "Foo.3.0"}, {"Bar", "Bar.3.0"}, {"@ptr", "3"}},
 							{{"Foo", "Foo.4.0"}, {"Bar", "Bar.4.0"}, {"@ptr", "4"}},
 						},
-						stats: &Stats{6, 12, 18, 0, 0, 0, 0, 0},
+						stats: &Stats{BytesScanned: 6, RecordsMatched: 12, RecordsScanned: 18},
 					},
 				},
 			},
@@ -736,7 +736,7 @@ var scenarios = []queryScenario{
 						results: []Result{
 							{{"count_distinct(Foo)", "100"}, {"bar", "ham"}},
 						},
-						stats: &Stats{1, 2, 3, 0, 0, 0, 0, 0},
+						stats: &Stats{BytesScanned: 1, RecordsMatched: 2, RecordsScanned: 3},
 					},
 					{
 						status: cloudwatchlogs.QueryStatusRunning,
@@ -744,7 +744,7 @@ var scenarios = []queryScenario{
 							{{"count_distinct(Foo)", "37"}, {"bar", "eggs"}},
 							{{"count_distinct(Foo)", "100"}, {"bar", "ham"}},
 						},
-						stats: &Stats{2, 4, 6, 0, 0, 0, 0, 0},
+						stats: &Stats{BytesScanned: 2, RecordsMatched: 4, RecordsScanned: 6},
 					},
 					{
 						status: cloudwatchlogs.QueryStatusComplete,
@@ -753,7 +753,7 @@ var scenarios = []queryScenario{
 							{{"count_distinct(Foo)", "41"}, {"bar", "eggs"}},
 							{{"count_distinct(Foo)", "10"}, {"bar", "spam"}},
 						},
-						stats: &Stats{4, 5, 8, 0, 0, 0, 0, 0},
+						stats: &Stats{BytesScanned: 4, RecordsMatched: 5, RecordsScanned: 8},
 					},
 				},
 			},
@@ -796,7 +796,7 @@ var scenarios = []queryScenario{
 					{
 						status:  cloudwatchlogs.QueryStatusComplete,
 						results: maxLimitResults,
-						stats:   &Stats{1, 1, 1, 0, 0, 0, 0, 0},
+						stats:   &Stats{BytesScanned: 1, RecordsMatched: 1, RecordsScanned: 1},
 					},
 				},
 			},
@@ -808,7 +808,7 @@ var scenarios = []queryScenario{
 					{
 						status:  cloudwatchlogs.QueryStatusComplete,
 						results: maxLimitResults[0 : MaxLimit/4],
-						stats:   &Stats{2, 2, 2, 0, 0, 0, 0, 0},
+						stats:   &Stats{BytesScanned: 2, RecordsMatched: 2, RecordsScanned: 2},
 					},
 				},
 			},
@@ -820,7 +820,7 @@ var scenarios = []queryScenario{
 					{
 						status:  cloudwatchlogs.QueryStatusComplete,
 						results: maxLimitResults[MaxLimit/4 : MaxLimit/2],
-						stats:   &Stats{3, 3, 3, 0, 0, 0, 0, 0},
+						stats:   &Stats{BytesScanned: 3, RecordsMatched: 3, RecordsScanned: 3},
 					},
 				},
 			},
@@ -832,7 +832,7 @@ var scenarios = []queryScenario{
 					{
 						status:  cloudwatchlogs.QueryStatusComplete,
 						results: maxLimitResults[MaxLimit/2 : 3*MaxLimit/4],
-						stats:   &Stats{4, 4, 4, 0, 0, 0, 0, 0},
+						stats:   &Stats{BytesScanned: 4, RecordsMatched: 4, RecordsScanned: 4},
 					},
 				},
 			},
@@ -844,7 +844,7 @@ var scenarios = []queryScenario{
 					{
 						status:  cloudwatchlogs.QueryStatusComplete,
 						results: maxLimitResults[3*MaxLimit/4 : MaxLimit],
-						stats:   &Stats{5, 5, 5, 0, 0, 0, 0, 0},
+						stats:   &Stats{BytesScanned: 5, RecordsMatched: 5, RecordsScanned: 5},
 					},
 				},
 			},
@@ -879,7 +879,7 @@ var scenarios = []queryScenario{
 					{
 						status:  cloudwatchlogs.QueryStatusComplete,
 						results: maxLimitResults,
-						stats:   &Stats{1, 1, 1, 0, 0, 0, 0, 0},
+						stats:   &Stats{BytesScanned: 1, RecordsMatched: 1, RecordsScanned: 1},
 					},
 				},
 			},
@@ -891,7 +891,7 @@ var scenarios = []queryScenario{
 					{
 						status:  cloudwatchlogs.QueryStatusComplete,
 						results: maxLimitResults,
-						stats:   &Stats{2, 2, 2, 0, 0, 0, 0, 0},
+						stats:   &Stats{BytesScanned: 2, RecordsMatched: 2, RecordsScanned: 2},
 					},
 				},
 			},
@@ -902,7 +902,7 @@ var scenarios = []queryScenario{
 				pollOutputs: []chunkPollOutput{
 					{
 						status: cloudwatchlogs.QueryStatusComplete,
-						stats:  &Stats{3, 3, 3, 0, 0, 0, 0, 0},
+						stats:  &Stats{BytesScanned: 3, RecordsMatched: 3, RecordsScanned: 3},
 					},
 				},
 			},
@@ -973,7 +973,7 @@ var scenarios = []queryScenario{
 							{{"EggCount", "1"}, {"Spam", "true"}},
 							{{"EggCount", "2"}, {"Span", "false"}},
 						},
-						stats: &Stats{77, 777, 7, 0, 0, 0, 0, 0},
+						stats: &Stats{BytesScanned: 77, RecordsMatched: 777, RecordsScanned: 7},
 					},
 				},
 			},
@@ -1017,7 +1017,7 @@ var scenarios = []queryScenario{
 						results: []Result{
 							{{"ignore", "me"}},
 						},
-						stats: &Stats{-1, -2, -12, 0, 0, 0, 0, 0},
+						stats: &Stats{BytesScanned: -1, RecordsMatched: -2, RecordsScanned: -12},
 					},
 					{
 						status: cloudwatchlogs.QueryStatusComplete,
@@ -1025,7 +1025,7 @@ var scenarios = []queryScenario{
 							{{"@ptr", "1111"}, {"Something", "wicked this way comes"}},
 							{{"@ptr", "2222"}, {"Something", "else"}},
 						},
-						stats: &Stats{13, 8, 3, 0, 0, 0, 0, 0},
+						stats: &Stats{BytesScanned: 13, RecordsMatched: 8, RecordsScanned: 3},
 					},
 				},
 			},
@@ -1064,7 +1064,7 @@ var scenarios = []queryScenario{
 							{{"@ptr", "aaaa"}, {"@timestamp", "2021-08-05 15:26:000.123"}},
 							{{"@ptr", "bbbb"}, {"@timestamp", "2021-08-05 15:26:000.125"}},
 						},
-						stats: &Stats{1, 1, 1, 0, 0, 0, 0, 0},
+						stats: &Stats{BytesScanned: 1, RecordsMatched: 1, RecordsScanned: 1},
 					},
 				},
 			},
@@ -1078,7 +1078,7 @@ var scenarios = []queryScenario{
 							{{"@ptr", "dddd"}, {"@timestamp", "2021-08-05 15:26:000.126"}},
 							{{"@ptr", "cccc"}, {"@timestamp", "2021-08-05 15:26:000.124"}},
 						},
-						stats: &Stats{2, 2, 1, 0, 0, 0, 0, 0},
+						stats: &Stats{BytesScanned: 2, RecordsMatched: 2, RecordsScanned: 1},
 					},
 				},
 			},
@@ -1133,7 +1133,7 @@ var scenarios = []queryScenario{
 							{{Field: "@ptr", Value: "1"}},
 							{{Field: "@ptr", Value: "2"}},
 						},
-						stats: &Stats{49, 23, 1, 0, 0, 0, 0, 0},
+						stats: &Stats{BytesScanned: 49, RecordsMatched: 23, RecordsScanned: 1},
 					},
 				},
 			},
@@ -1146,7 +1146,7 @@ var scenarios = []queryScenario{
 				pollOutputs: []chunkPollOutput{
 					{
 						status: cloudwatchlogs.QueryStatusRunning,
-						stats:  &Stats{3, 2, 3, 0, 0, 0, 0, 0},
+						stats:  &Stats{BytesScanned: 3, RecordsMatched: 2, RecordsScanned: 3},
 					},
 					{
 						status: cloudwatchlogs.QueryStatusComplete,
@@ -1154,7 +1154,7 @@ var scenarios = []queryScenario{
 							{{Field: "@ptr", Value: "3"}},
 							{{Field: "@ptr", Value: "4"}},
 						},
-						stats: &Stats{51, 77, 99, 0, 0, 0, 0, 0},
+						stats: &Stats{BytesScanned: 51, RecordsMatched: 77, RecordsScanned: 99},
 					},
 				},
 			},
@@ -1202,7 +1202,7 @@ var scenarios = []queryScenario{
 						results: []Result{
 							{{Field: "@ptr", Value: "1"}},
 						},
-						stats: &Stats{11, 22, 33, 0, 0, 0, 0, 0},
+						stats: &Stats{BytesScanned: 11, RecordsMatched: 22, RecordsScanned: 33},
 					},
 				},
 			},
@@ -1219,7 +1219,7 @@ var scenarios = []queryScenario{
 							{{Field: "@ptr", Value: "2"}},
 							{{Field: "@ptr", Value: "3"}},
 						},
-						stats: &Stats{44, 55, 66, 0, 0, 0, 0, 0},
+						stats: &Stats{BytesScanned: 44, RecordsMatched: 55, RecordsScanned: 66},
 					},
 				},
 			},
@@ -1235,7 +1235,7 @@ var scenarios = []queryScenario{
 						results: []Result{
 							{{Field: "@ptr", Value: "4"}},
 						},
-						stats: &Stats{77, 88, 99, 0, 0, 0, 0, 0},
+						stats: &Stats{BytesScanned: 77, RecordsMatched: 88, RecordsScanned: 99},
 					},
 				},
 			},
@@ -1294,7 +1294,7 @@ var scenarios = []queryScenario{
 						results: []Result{
 							{{"@ptr", "1"}, {"instance", "1"}},
 						},
-						stats: &Stats{1, 1, 1, 0, 0, 0, 0, 0},
+						stats: &Stats{BytesScanned: 1, RecordsMatched: 1, RecordsScanned: 1},
 					},
 				},
 			},
@@ -1345,7 +1345,7 @@ var scenarios = []queryScenario{
 							{{"@ptr", "7"}, {"instance", "1"}},
 							{{"@ptr", "8"}, {"instance", "1"}},
 						},
-						stats: &Stats{1, 1, 1, 0, 0, 0, 0, 0},
+						stats: &Stats{BytesScanned: 1, RecordsMatched: 1, RecordsScanned: 1},
 					},
 				},
 			},
@@ -1400,7 +1400,7 @@ var scenarios = []queryScenario{
 				pollOutputs: []chunkPollOutput{
 					{
 						status: cloudwatchlogs.QueryStatusComplete,
-						stats:  &Stats{1, 0, 1, 0, 0, 0, 0, 0},
+						stats:  &Stats{BytesScanned: 1, RecordsScanned: 1},
 					},
 				},
 			},
@@ -1416,7 +1416,7 @@ var scenarios = []queryScenario{
 						results: []Result{
 							{{"@ptr", "cccc"}, {"@timestamp", "2021-08-05 15:26:000.124"}},
 						},
-						stats: &Stats{2, 1, 1, 0, 0, 0, 0, 0},
+						stats: &Stats{BytesScanned: 2, RecordsMatched: 1, RecordsScanned: 1},
 					},
 				},
 			},
@@ -1458,7 +1458,7 @@ var scenarios = []queryScenario{
 						results: []Result{
 							{{"@ptr", "AAAAAA"}, {"@timestamp", "2021-08-05 15:26:000.123"}},
 						},
-						stats: &Stats{1, 1, 1, 0, 0, 0, 0, 0},
+						stats: &Stats{BytesScanned: 1, RecordsMatched: 1, RecordsScanned: 1},
 					},
 				},
 			},
@@ -1474,7 +1474,7 @@ var scenarios = []queryScenario{
 						results: []Result{
 							{{"@ptr", "BBBBBB"}, {"@timestamp", "2021-08-05 15:26:000.124"}},
 						},
-						stats: &Stats{2, 1, 1, 0, 0, 0, 0, 0},
+						stats: &Stats{BytesScanned: 2, RecordsMatched: 1, RecordsScanned: 1},
 					},
 				},
 			},
@@ -1535,7 +1535,7 @@ var scenarios = []queryScenario{
 					},
 					{
 						status: cloudwatchlogs.QueryStatusComplete,
-						stats:  &Stats{0, 0, 0, 0, 0, 0, 0, 0},
+						stats:  &Stats{},
 					},
 				},
 			},
@@ -1547,7 +1547,7 @@ var scenarios = []queryScenario{
 					{
 						results: resultSeries(MaxLimit+1, 1),
 						status:  cloudwatchlogs.QueryStatusComplete,
-						stats:   &Stats{1, 1, 1, 0, 0, 0, 0, 0},
+						stats:   &Stats{BytesScanned: 1, RecordsMatched: 1, RecordsScanned: 1},
 					},
 				},
 			},
@@ -1558,7 +1558,7 @@ var scenarios = []queryScenario{
 				pollOutputs: []chunkPollOutput{
 					{
 						status: cloudwatchlogs.QueryStatusComplete,
-						stats:  &Stats{0, 0, 0, 0, 0, 0, 0, 0},
+						stats:  &Stats{},
 					},
 				},
 			},
@@ -1570,12 +1570,12 @@ var scenarios = []queryScenario{
 					{
 						results: resultSeries(MaxLimit+3, 1),
 						status:  cloudwatchlogs.QueryStatusRunning,
-						stats:   &Stats{1, 1, 1, 0, 0, 0, 0, 0},
+						stats:   &Stats{BytesScanned: 1, RecordsMatched: 1, RecordsScanned: 1},
 					},
 					{
 						results: resultSeries(MaxLimit+3, 1),
 						status:  cloudwatchlogs.QueryStatusComplete,
-						stats:   &Stats{1, 1, 1, 0, 0, 0, 0, 0},
+						stats:   &Stats{BytesScanned: 1, RecordsMatched: 1, RecordsScanned: 1},
 					},
 				},
 			},
@@ -1598,7 +1598,7 @@ var scenarios = []queryScenario{
 					{
 						results: resultSeries(MaxLimit+5, 1),
 						status:  cloudwatchlogs.QueryStatusComplete,
-						stats:   &Stats{1, 1, 1, 0, 0, 0, 0, 0},
+						stats:   &Stats{BytesScanned: 1, RecordsMatched: 1, RecordsScanned: 1},
 					},
 				},
 			},
@@ -1609,22 +1609,22 @@ var scenarios = []queryScenario{
 				pollOutputs: []chunkPollOutput{
 					{
 						status: cloudwatchlogs.QueryStatusScheduled,
-						stats:  &Stats{0, 0, 0, 0, 0, 0, 0, 0},
+						stats:  &Stats{},
 					},
 					{
 						status: cloudwatchlogs.QueryStatusScheduled,
-						stats:  &Stats{0, 0, 0, 0, 0, 0, 0, 0},
+						stats:  &Stats{},
 					},
 					{
 						status: cloudwatchlogs.QueryStatusRunning,
-						stats:  &Stats{0, 0, 0, 0, 0, 0, 0, 0},
+						stats:  &Stats{},
 					},
 					{
 						status: cloudwatchlogs.QueryStatusRunning,
 					},
 					{
 						status: cloudwatchlogs.QueryStatusComplete,
-						stats:  &Stats{0, 0, 0, 0, 0, 0, 0, 0},
+						stats:  &Stats{},
 					},
 				},
 			},
@@ -1636,7 +1636,7 @@ var scenarios = []queryScenario{
 					{
 						results: resultSeries(MaxLimit+7, 1),
 						status:  cloudwatchlogs.QueryStatusComplete,
-						stats:   &Stats{1, 1, 1, 0, 0, 0, 0, 0},
+						stats:   &Stats{BytesScanned: 1, RecordsMatched: 1, RecordsScanned: 1},
 					},
 				},
 			},
@@ -1661,7 +1661,7 @@ var scenarios = []queryScenario{
 					{
 						results: resultSeries(MaxLimit+9, 1),
 						status:  cloudwatchlogs.QueryStatusComplete,
-						stats:   &Stats{1, 1, 1, 0, 0, 0, 0, 0},
+						stats:   &Stats{BytesScanned: 1, RecordsMatched: 1, RecordsScanned: 1},
 					},
 				},
 			},
@@ -1673,7 +1673,7 @@ var scenarios = []queryScenario{
 					{
 						results: maxLimitResults,
 						status:  cloudwatchlogs.QueryStatusComplete,
-						stats:   &Stats{MaxLimit, MaxLimit, MaxLimit, 0, 0, 0, 0, 0},
+						stats:   &Stats{BytesScanned: MaxLimit, RecordsMatched: MaxLimit, RecordsScanned: MaxLimit},
 					},
 				},
 			},
@@ -1685,7 +1685,7 @@ var scenarios = []queryScenario{
 					{
 						results: maxLimitResults[0 : MaxLimit/4],
 						status:  cloudwatchlogs.QueryStatusComplete,
-						stats:   &Stats{1, 1, 1, 0, 0, 0, 0, 0},
+						stats:   &Stats{BytesScanned: 1, RecordsMatched: 1, RecordsScanned: 1},
 					},
 				},
 			},
@@ -1697,7 +1697,7 @@ var scenarios = []queryScenario{
 					{
 						results: maxLimitResults[MaxLimit/4 : MaxLimit/2],
 						status:  cloudwatchlogs.QueryStatusComplete,
-						stats:   &Stats{1, 1, 1, 0, 0, 0, 0, 0},
+						stats:   &Stats{BytesScanned: 1, RecordsMatched: 1, RecordsScanned: 1},
 					},
 				},
 			},
@@ -1709,7 +1709,7 @@ var scenarios = []queryScenario{
 					{
 						results: maxLimitResults[MaxLimit/2 : 3*MaxLimit/4],
 						status:  cloudwatchlogs.QueryStatusComplete,
-						stats:   &Stats{1, 1, 1, 0, 0, 0, 0, 0},
+						stats:   &Stats{BytesScanned: 1, RecordsMatched: 1, RecordsScanned: 1},
 					},
 				},
 			},
@@ -1721,7 +1721,7 @@ var scenarios = []queryScenario{
 					{
 						results: maxLimitResults[3*MaxLimit/4 : MaxLimit],
 						status:  cloudwatchlogs.QueryStatusComplete,
-						stats:   &Stats{1, 1, 1, 0, 0, 0, 0, 0},
+						stats:   &Stats{BytesScanned: 1, RecordsMatched: 1, RecordsScanned: 1},
 					},
 				},
 			},
@@ -2014,6 +2014,8 @@ var (
 		StartQuery:      100_000,
 		GetQueryResults: 100_000,
 		StopQuery:       100_000,
+
+		DescribeLogGroups: 100_000,
 	}
 	anyContext = mock.MatchedBy(func(ctx context.Context) bool {
 		return ctx != nil
@@ -5,15 +5,15 @@
 package incite
 
 import (
-	"container/heap"
+	"container/list"
 	"container/ring"
 	"context"
 	"errors"
 	"fmt"
-	"io"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -21,33 +21,74 @@ type mgr struct {
 	Config
 
 	// Fields owned exclusively by the mgr loop goroutine.
-	close       chan struct{} // Receives notification on Close()
-	pq          streamHeap    // Written by Query, written by mgr loop goroutine
-	ready       ring.Ring     // Chunks ready to start
-	numReady    int           // Number of chunks ready to start
-	numStarting int           // Number of chunks handed off to starter
-	numPolling  int           // Number of chunks handed off to poller
-	numStopping int           // Number of chunks handed off to stopper
+	close        chan struct{}      // Receives notification on Close()
+	tenants      map[string]*tenant // Scheduling state of each tenant with work
+	turn         int64              // Number of Parallel slots given to tenants
+	numReady     int                // Number of chunks ready to start, across all tenants
+	numStarting  int                // Number of chunks handed off to starter
+	numPolling   int                // Number of chunks handed off to poller
+	numStopping  int                // Number of chunks handed off to stopper
+	numHydrating int                // Number of chunks handed off to hydrator
+	toHydrate    []*chunk           // Completed chunks waiting to be handed off to hydrator
+	preemptible  map[*chunk]bool    // Chunks handed off to poller which may be preempted
+	preempting   int                // Number of chunks asked to give up their Parallel slot
+	orphans      []*chunk           // Orphaned query chunks waiting to be stopped
+	drained      chan struct{}      // Closed when no work is left, if Shutdown is draining
+	parallel     int                // Effective parallelism, at most Parallel
+	window       int                // Incremented each time parallel is decreased
+	growth       int                // Number of chunks started since parallel last changed
+	epoch        time.Time          // Time the mgr was created, used for priority aging
+	seq          int64              // Number of times a stream was pushed onto a tenant's pq
+	live         map[*stream]bool   // Streams which may still be alive, closed on shutdown
+	pruneAt      int                // Number of live streams at which dead ones are next pruned
 
 	// Fields written by arbitrary goroutines.
-	query     chan *stream // Receives notification of new Query()
+	query     chan *stream       // Receives notification of new Query()
+	resize    chan int           // Receives new Parallel values from SetParallel()
+	expire    chan *stream       // Receives streams whose deadline has passed
+	orphan    chan []*chunk      // Receives orphaned query chunks to stop
+	drain     chan chan struct{} // Receives notification of Shutdown()
+	control   chan streamControl // Receives requests to pause and resume streams
+	stopped   chan struct{}      // Closed when the mgr loop has finished shutting down
+	draining  bool               // Set by Shutdown(), guarded by queryLock
 	queryLock sync.Mutex
 
 	// Fields for communicating with workers.
-	start  chan *chunk // Sends chunks to starter
-	poll   chan *chunk // Sends chunks to poller
-	stop   chan *chunk // Sends chunks to stopper
-	update chan *chunk // Receives chunks from starter, poller, and stopper
+	start   chan *chunk // Sends chunks to starter
+	poll    chan *chunk // Sends chunks to poller
+	stop    chan *chunk // Sends chunks to stopper
+	hydrate chan *chunk // Sends chunks to hydrator
+	update  chan *chunk // Receives chunks from starter, poller, stopper, and hydrator
+
+	// Cache and rate limit for log group names resolved by Query.
+	groups     groupCache
+	groupLimit bucket
+
+	// Cache and rate limit for query definitions looked up by Query.
+	queryDefs     queryDefCache
+	queryDefLimit bucket
+
+	// Immutable concurrency quota, the upper bound on Parallel.
+	quota int
+
+	// Logger set by SetLogger, overriding the Logger in Config.
+	log atomic.Value
+
+	// Circuit breaker, nil if disabled.
+	breaker *breaker
 
 	// Fields written by mgr loop and potentially read by any goroutine.
-	stats     Stats        // Read by GetStats, written by mgr loop goroutine
-	statsLock sync.RWMutex // Controls access to stats
+	stats       Stats                    // Read by GetStats, written by mgr loop goroutine
+	tenantStats map[string]*list.Element // Read by GetTenantStats, written by mgr loop goroutine
+	tenantLRU   list.List                // Values in tenantStats, most recently updated first
+	statsLock   sync.RWMutex             // Controls access to stats, tenantStats, and tenantLRU
 
 	// Worker references. Not strictly necessary, and primarily here to
 	// make it easier to observe state while debugging tests.
-	starter *starter
-	poller  *poller
-	stopper *stopper
+	starter  *starter
+	poller   *poller
+	stopper  *stopper
+	hydrator *hydrator
 }
 
 // NewQueryManager returns a new query manager with the given
@@ -56,43 +97,105 @@ func NewQueryManager(cfg Config) QueryManager {
 	if cfg.Actions == nil {
 		panic(nilActionsMsg)
 	}
+	if cfg.QueryConcurrencyQuota < 0 {
+		panic(badQueryConcurrencyQuotaMsg)
+	}
+	quota := cfg.QueryConcurrencyQuota
+	if quota == 0 {
+		quota = QueryConcurrencyQuotaLimit
+	}
 	if cfg.Parallel <= 0 {
-		cfg.Parallel = DefaultParallel
-	} else if cfg.Parallel > QueryConcurrencyQuotaLimit {
-		cfg.Parallel = QueryConcurrencyQuotaLimit
+		cfg.Parallel = belowQuota(quota)
+	} else if cfg.Parallel > quota {
+		cfg.Parallel = quota
 	}
-	minDelay := make(map[CloudWatchLogsAction]time.Duration, numActions)
-	for action, defaultRPS := range RPSDefaults {
-		rps := cfg.RPS[action]
-		if rps <= 0 {
-			rps = defaultRPS
+	for action, quota := range cfg.RPSQuota {
+		if !validAction(action) {
+			panic(badActionMsg)
+		}
+		if quota < 0 {
+			panic(badRPSQuotaMsg)
+		}
+	}
+	if cfg.CircuitBreaker.FailureRate > 1 {
+		panic(badFailureRateMsg)
+	}
+	if cfg.Scheduling < 0 || cfg.Scheduling >= numSchedulingPolicies {
+		panic(badSchedulingPolicyMsg)
+	}
+	for _, weight := range cfg.TenantWeights {
+		if weight < 0 {
+			panic(badTenantWeightMsg)
 		}
-		minDelay[action] = time.Second / time.Duration(rps)
 	}
 	if cfg.Logger == nil {
 		cfg.Logger = NopLogger
 	}
+	if !validQueryMarker(cfg.QueryMarker) {
+		panic(badQueryMarkerMsg)
+	}
+	if cfg.StopOrphans {
+		if cfg.QueryMarker == "" {
+			panic(stopOrphansWithoutMarkerMsg)
+		}
+		if _, ok := cfg.Actions.(QueryDescriber); !ok {
+			panic(noQueryDescriberMsg)
+		}
+	}
+	groupRPS, groupBurst := groupLimits(&cfg)
 
 	m := &mgr{
 		Config: cfg,
 
-		close: make(chan struct{}),
-		query: make(chan *stream),
+		close:  make(chan struct{}),
+		query:  make(chan *stream),
+		expire: make(chan *stream),
+		orphan: make(chan []*chunk),
 
-		start: make(chan *chunk, cfg.Parallel),
-		poll:  make(chan *chunk, cfg.Parallel),
-		stop:  make(chan *chunk, cfg.Parallel),
+		// The worker channels are sized for the largest Parallel
+		// value SetParallel can set, which is the quota.
+		start:   make(chan *chunk, quota),
+		poll:    make(chan *chunk, quota),
+		stop:    make(chan *chunk, quota),
+		hydrate: make(chan *chunk, quota),
 
-		// All three workers send back their updates on the update
+		// All four workers send back their updates on the update
 		// channel. To prevent deadlock, the channel buffer needs
 		// to be big enough to receive all possible chunks that all
-		// three workers could have in flight at the same time.
-		update: make(chan *chunk, 3*cfg.Parallel),
+		// four workers could have in flight at the same time.
+		update: make(chan *chunk, 4*quota),
+
+		resize:  make(chan int),
+		drain:   make(chan chan struct{}),
+		control: make(chan streamControl),
+		stopped: make(chan struct{}),
+
+		queryDefLimit: bucket{
+			minDelay: time.Second / queryDefinitionRPS,
+			burst:    1,
+			tokens:   1,
+			refill:   time.Now(),
+		},
+		groupLimit: bucket{
+			minDelay: time.Second / time.Duration(groupRPS),
+			burst:    groupBurst,
+			tokens:   float64(groupBurst),
+			refill:   time.Now(),
+		},
+
+		quota:    quota,
+		parallel: cfg.Parallel,
+		epoch:    time.Now(),
+	}
+	if cfg.AdaptiveParallel {
+		m.stats.Parallel = cfg.Parallel
 	}
 
 	if m.Name == "" {
 		m.Name = fmt.Sprintf("%p", m)
 	}
+	m.log.Store(loggerBox{cfg.Logger})
+	m.breaker = newBreaker(m)
 
 	go m.loop()
 
@@ -102,10 +205,22 @@ func NewQueryManager(cfg Config) QueryManager {
 	go m.poller.loop()
 	m.stopper = newStopper(m)
 	go m.stopper.loop()
+	m.hydrator = newHydrator(m)
+	go m.hydrator.loop()
+
+	if cfg.StopOrphans {
+		go m.findOrphans(time.Now())
+	}
 
 	return m
 }
 
+// defaultRPS returns the RPS value to use for a CloudWatch Logs action
+// whose rate is not given in the RPS field of the Config.
+func (m *mgr) defaultRPS(action CloudWatchLogsAction) int {
+	return belowQuota(rpsQuota(m.RPSQuota, action))
+}
+
 func (m *mgr) Close() (err error) {
 	defer func() {
 		if r := recover(); r != nil {
@@ -120,6 +235,39 @@ func (m *mgr) Close() (err error) {
 	return
 }
 
+func (m *mgr) Shutdown(ctx context.Context) error {
+	if ctx == nil {
+		panic(nilContextMsg)
+	}
+
+	m.queryLock.Lock()
+	closed := m.draining
+	m.draining = true
+	m.queryLock.Unlock()
+	if closed {
+		return ErrClosed
+	}
+
+	drained := make(chan struct{})
+	select {
+	case m.drain <- drained:
+	case <-m.close:
+		return ErrClosed
+	}
+
+	var err error
+	select {
+	case <-drained:
+	case <-m.stopped:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	_ = m.Close()
+	<-m.stopped
+	return err
+}
+
 func (m *mgr) GetStats() Stats {
 	m.statsLock.RLock()
 	defer m.statsLock.RUnlock()
@@ -127,11 +275,18 @@ func (m *mgr) GetStats() Stats {
 }
 
 func (m *mgr) Query(q QuerySpec) (s Stream, err error) {
+	// Text and log groups may come from a query definition, which is
+	// only looked up once the rest of the QuerySpec is known to be
+	// valid.
 	q.Text = strings.TrimSpace(q.Text)
-	if q.Text == "" {
+	if q.Text == "" && q.QueryDefinition == "" {
 		return nil, errors.New(textBlankMsg)
 	}
 
+	if err = m.breaker.check(); err != nil {
+		return nil, err
+	}
+
 	q.Start = q.Start.UTC()
 	if hasSubMillisecond(q.Start) {
 		return nil, errors.New(startSubMillisecondMsg)
@@ -144,12 +299,11 @@ func (m *mgr) Query(q QuerySpec) (s Stream, err error) {
 		return nil, errors.New(endNotBeforeStartMsg)
 	}
 
-	if len(q.Groups) == 0 {
+	if !hasGroups(&q) && q.QueryDefinition == "" {
 		return nil, errors.New(noGroupsMsg)
 	}
-	groups := make([]*string, len(q.Groups))
-	for i := range q.Groups {
-		groups[i] = &q.Groups[i]
+	if !validGroupPatterns(q.GroupPatterns) {
+		return nil, errors.New(badGroupPatternMsg)
 	}
 
 	d := q.End.Sub(q.Start)
@@ -180,9 +334,44 @@ func (m *mgr) Query(q QuerySpec) (s Stream, err error) {
 		return nil, errors.New(splitUntilSubMillisecondMsg)
 	} else if q.Preview {
 		return nil, errors.New(splitUntilWithPreviewMsg)
-	} else if q.Limit < maxLimit {
+	} else if q.Limit < maxLimit && !q.SplitOnTimeout && !q.SplitOnFailed {
 		return nil, errors.New(splitUntilWithoutMaxLimitMsg)
 	}
+	if (q.SplitOnTimeout || q.SplitOnFailed) && q.SplitUntil >= q.Chunk {
+		return nil, errors.New(splitOnStatusWithoutSplitUntilMsg)
+	}
+
+	if q.Hydrate {
+		if _, ok := m.Actions.(LogRecordGetter); !ok {
+			return nil, errors.New(noLogRecordGetterMsg)
+		} else if q.Preview {
+			return nil, errors.New(hydrateWithPreviewMsg)
+		}
+	}
+
+	lookupCtx, lookupCancel := m.lookupContext()
+	defer lookupCancel()
+	if err = m.applyQueryDefinition(lookupCtx, &q); err != nil {
+		return nil, err
+	}
+	q.Text = strings.TrimSpace(q.Text)
+	if q.Text == "" {
+		return nil, errors.New(textBlankMsg)
+	}
+	if !hasGroups(&q) {
+		return nil, errors.New(noGroupsMsg)
+	}
+	q.Groups, err = m.resolveGroups(lookupCtx, &q)
+	if err != nil {
+		return nil, err
+	}
+	if len(q.Groups) > MaxGroups {
+		return nil, errors.New(exceededMaxGroupsMsg)
+	}
+	groups := make([]*string, len(q.Groups))
+	for i := range q.Groups {
+		groups[i] = &q.Groups[i]
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 
@@ -193,11 +382,91 @@ func (m *mgr) Query(q QuerySpec) (s Stream, err error) {
 		cancel: cancel,
 		n:      n,
 		groups: groups,
+		mgr:    m,
 		stats: Stats{
 			RangeRequested: d,
 		},
 	}
 	ss.more = sync.NewCond(&ss.lock)
+	if q.Timeout > 0 {
+		ss.deadline = time.Now().Add(q.Timeout)
+		ss.expiry = time.AfterFunc(q.Timeout, func() {
+			select {
+			case m.expire <- ss:
+			case <-m.close:
+			}
+		})
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			if ss.expiry != nil {
+				ss.expiry.Stop()
+			}
+			err = ErrClosed
+		}
+	}()
+
+	m.queryLock.Lock()
+	defer m.queryLock.Unlock()
+	if m.draining {
+		if ss.expiry != nil {
+			ss.expiry.Stop()
+		}
+		cancel()
+		return nil, ErrClosed
+	}
+	m.query <- ss
+
+	return ss, nil
+}
+
+// lookupContext returns a context for the CloudWatch Logs requests Query
+// makes to resolve query definitions and log groups. The context is
+// cancelled if the QueryManager is closed.
+func (m *mgr) lookupContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-m.close:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// hasGroups returns true if q names at least one log group, log group
+// prefix, or log group pattern.
+func hasGroups(q *QuerySpec) bool {
+	return len(q.Groups) > 0 || len(q.GroupPrefixes) > 0 || len(q.GroupPatterns) > 0
+}
+
+func (m *mgr) Attach(queryID string, spec AttachSpec) (s Stream, err error) {
+	queryID = strings.TrimSpace(queryID)
+	if queryID == "" {
+		return nil, errors.New(queryIDBlankMsg)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	ss := &stream{
+		QuerySpec: QuerySpec{
+			Text:     spec.Text,
+			Limit:    maxLimit,
+			Preview:  spec.Preview,
+			Priority: spec.Priority,
+			Tenant:   spec.Tenant,
+		},
+
+		ctx:         ctx,
+		cancel:      cancel,
+		n:           1,
+		queryID:     queryID,
+		mgr:         m,
+		stopOnClose: spec.StopOnClose,
+	}
+	ss.more = sync.NewCond(&ss.lock)
 
 	defer func() {
 		if r := recover(); r != nil {
@@ -207,6 +476,10 @@ func (m *mgr) Query(q QuerySpec) (s Stream, err error) {
 
 	m.queryLock.Lock()
 	defer m.queryLock.Unlock()
+	if m.draining {
+		cancel()
+		return nil, ErrClosed
+	}
 	m.query <- ss
 
 	return ss, nil
@@ -226,18 +499,64 @@ func (m *mgr) loop() {
 			m.addQuery(s)
 		case c := <-m.update:
 			m.handleChunk(c)
+		case s := <-m.expire:
+			m.expireStream(s)
+		case n := <-m.resize:
+			m.resizeParallel(n)
+		case ctl := <-m.control:
+			m.controlStream(ctl)
+		case drained := <-m.drain:
+			m.logEvent("", "draining...")
+			m.drained = drained
+		case orphans := <-m.orphan:
+			m.logEvent("", fmt.Sprintf("found %d orphaned queries", len(orphans)))
+			m.orphans = append(m.orphans, orphans...)
 		case <-m.close:
 			return
 		}
 
-		for m.numStarting+m.numPolling+m.numStopping < m.Parallel {
+		for m.numStarting+m.numPolling+m.numStopping < m.parallel {
+			if len(m.orphans) > 0 {
+				m.stopChunk(m.orphans[0])
+				m.orphans = m.orphans[1:]
+				continue
+			}
+			if m.breaker.awaitingProbe() {
+				break
+			}
 			c := m.getReadyChunk()
 			if c == nil {
 				break
 			}
+			if c.stream.queryID != "" {
+				m.logChunk(c, "attached", "")
+				c.since = time.Now()
+				c.expiry = time.Time{}
+				c.state = polling
+				m.numPolling++
+				m.dispatch(m.poll, c)
+				continue
+			}
+			if err := m.breaker.check(); err != nil {
+				m.logChunk(c, "circuit breaker open, failing", "")
+				c.err = &StartQueryError{c.stream.Text, c.start, c.end, err}
+				c.started()
+				m.failChunk(c)
+				continue
+			}
 			c.state = starting
+			c.window = m.window
 			m.numStarting++
-			m.start <- c
+			m.dispatch(m.start, c)
+		}
+
+		m.feedHydrator()
+		m.preempt()
+
+		if m.drained != nil && m.idle() {
+			m.logEvent("", "drained")
+			close(m.drained)
+			m.drained = nil
 		}
 	}
 }
@@ -246,13 +565,14 @@ func (m *mgr) shutdown() {
 	// Log start of shutdown process.
 	m.logEvent("", "stopping...")
 
-	// Close the start and poll channels, causing starter and poller to
-	// shut down.
+	// Close the start, poll, and hydrate channels, causing starter,
+	// poller, and hydrator to shut down.
 	close(m.start)
 	close(m.poll)
+	close(m.hydrate)
 
-	// Drain all chunks from starter and poller.
-	for m.numStarting+m.numPolling > 0 {
+	// Drain all chunks from starter, poller, and hydrator.
+	for m.numStarting+m.numPolling+m.numHydrating > 0 {
 		c := <-m.update
 		switch c.state {
 		case started:
@@ -262,19 +582,30 @@ func (m *mgr) shutdown() {
 			m.numStarting--
 			c.stream.setErr(ErrClosed, true, Stats{})
 		case polling:
-			m.stopChunk(c)
+			if c.stream.stoppable() {
+				m.stopChunk(c)
+			}
 			fallthrough
 		case complete:
 			m.numPolling--
 			c.stream.setErr(ErrClosed, true, Stats{})
+		case hydrating, hydrated:
+			m.numHydrating--
+			c.stream.setErr(ErrClosed, true, Stats{})
 		case stopping, stopped:
 			m.numStopping--
 		}
 	}
 
-	// Close all open streams.
-	for _, s := range m.pq {
-		s.setErr(ErrClosed, true, Stats{})
+	// Close all open streams, including those waiting to start a chunk,
+	// paused, or holding back chunks because of MaxParallel.
+	for _, c := range m.toHydrate {
+		c.stream.setErr(ErrClosed, true, Stats{})
+	}
+	for s := range m.live {
+		if s.alive() {
+			s.setErr(ErrClosed, true, Stats{})
+		}
 	}
 
 	// Close the stop channel, causing the stopper to shut down.
@@ -288,34 +619,110 @@ func (m *mgr) shutdown() {
 
 	// Log a final stop event.
 	m.logEvent("", "stopped")
+	if m.stopped != nil {
+		close(m.stopped)
+	}
+}
+
+// idle returns true if the mgr has no chunks in flight or waiting to
+// start, and no orphaned queries waiting to be stopped.
+func (m *mgr) idle() bool {
+	if m.numStarting+m.numPolling+m.numStopping+m.numHydrating > 0 || len(m.toHydrate) > 0 || len(m.orphans) > 0 {
+		return false
+	}
+	for _, t := range m.tenants {
+		if t.waiting() {
+			return false
+		}
+	}
+	return true
 }
 
 func (m *mgr) addQuery(s *stream) {
-	heap.Push(&m.pq, s)
+	m.track(s)
+	m.pushStream(s)
 	s.lock.RLock()
 	defer s.lock.RUnlock()
-	m.addStats(&Stats{
+	m.statsLock.Lock()
+	defer m.statsLock.Unlock()
+	t := Stats{
 		RangeRequested: s.stats.RangeRequested,
-	})
+	}
+	m.stats.add(&t)
+	m.addTenantStats(s.Tenant, &t)
+}
+
+// track remembers a new stream until it dies, so that shutdown can
+// close it wherever it is waiting. Streams which die without the mgr
+// loop noticing, for example because they are closed while paused, are
+// pruned whenever the number of remembered streams has doubled.
+func (m *mgr) track(s *stream) {
+	if m.live == nil {
+		m.live = make(map[*stream]bool)
+	}
+	m.live[s] = true
+	if len(m.live) < m.pruneAt {
+		return
+	}
+	for s := range m.live {
+		if !s.alive() {
+			delete(m.live, s)
+		}
+	}
+	m.pruneAt = 2 * len(m.live)
 }
 
 func (m *mgr) handleChunk(c *chunk) {
+	if c.state != hydrating && c.state != hydrated {
+		m.release(c)
+	}
+
 	switch c.state {
 	case starting:
 		m.numStarting--
+		if c.err == errProbeInFlight {
+			c.err = nil
+			m.makeReady(c)
+			return
+		}
+		if m.AdaptiveParallel && isLimitExceeded(c.err) {
+			m.handleLimitExceeded(c)
+			return
+		}
 		c.started()
-		m.killStream(c)
+		m.failChunk(c)
 	case started:
 		m.numStarting--
+		m.increaseParallel()
 		m.numPolling++
 		c.started()
+		c.expiry = time.Time{}
+		if c.stream.ChunkTimeout > 0 {
+			c.expiry = c.since.Add(c.stream.ChunkTimeout)
+		}
 		c.state = polling
-		m.poll <- c
+		m.dispatch(m.poll, c)
+		m.addPreemptible(c)
 	case polling:
 		m.numPolling--
 		m.handlePollingError(c)
 	case complete:
 		m.numPolling--
+		if c.results != nil {
+			c.state = hydrating
+			m.toHydrate = append(m.toHydrate, c)
+			return
+		}
+		m.handleChunkCompletion(c)
+	case hydrating:
+		m.numHydrating--
+		if c.err == errStopChunk {
+			return
+		}
+		c.Stats.RangeFailed += c.duration()
+		m.failChunk(c)
+	case hydrated:
+		m.numHydrating--
 		m.handleChunkCompletion(c)
 	case stopping, stopped:
 		m.numStopping--
@@ -324,13 +731,90 @@ func (m *mgr) handleChunk(c *chunk) {
 	}
 }
 
+// feedHydrator hands completed chunks off to the hydrator. Because
+// GetLogRecord uses no Insights query concurrency, hydrating chunks do
+// not occupy Parallel slots. Instead, the number handed off is capped
+// at the capacity of the hydrate channel, so the mgr loop never blocks
+// sending to the hydrator.
+func (m *mgr) feedHydrator() {
+	for len(m.toHydrate) > 0 && m.numHydrating < cap(m.hydrate) {
+		c := m.toHydrate[0]
+		m.toHydrate[0] = nil
+		m.toHydrate = m.toHydrate[1:]
+		m.numHydrating++
+		m.hydrate <- c
+	}
+}
+
 func (m *mgr) makeReady(c *chunk) {
+	t := m.tenantOf(c.stream)
 	r := ring.New(1)
 	r.Value = c
-	m.ready.Prev().Link(r)
+	t.ready.Prev().Link(r)
+	t.numReady++
 	m.numReady++
 }
 
+// handleLimitExceeded requeues a chunk which could not be started
+// because the Insights query concurrency quota was exhausted, and
+// halves the effective parallelism. The effective parallelism is only
+// halved once per window, so that a burst of chunks which were all sent
+// to the starter at the previous parallelism only counts once.
+//
+// The chunk is held back from restarting by a delay which grows each
+// time it is rejected, giving other queries time to finish and free up
+// concurrency. Rejections don't count against the chunk's budget of
+// temporary errors, so the chunk keeps trying until it starts or its
+// stream ends.
+func (m *mgr) handleLimitExceeded(c *chunk) {
+	c.err = nil
+	c.limited++
+	c.hold = time.Now().Add(m.limitDelay(c.limited))
+	m.makeReady(c)
+
+	if c.window != m.window || m.parallel == 1 {
+		return
+	}
+	m.window++
+	m.setParallel(m.parallel / 2)
+}
+
+// limitDelay returns the delay before restarting a chunk after its nth
+// rejection with a LimitExceededException.
+func (m *mgr) limitDelay(n int) time.Duration {
+	b := m.Backoff
+	if !b.enabled() {
+		b = Backoff{Base: limitExceededBase, Max: limitExceededMax}
+	}
+	return b.delay(n)
+}
+
+// increaseParallel records a successfully started chunk and, if
+// adaptive concurrency control is enabled, increases the effective
+// parallelism by one after every run of successful starts as long as
+// the current effective parallelism.
+func (m *mgr) increaseParallel() {
+	if !m.AdaptiveParallel || m.parallel == m.Parallel {
+		return
+	}
+	m.growth++
+	if m.growth >= m.parallel {
+		m.setParallel(m.parallel + 1)
+	}
+}
+
+func (m *mgr) setParallel(n int) {
+	if n < 1 {
+		n = 1
+	}
+	m.logEvent("", fmt.Sprintf("adjusting parallel from %d to %d", m.parallel, n))
+	m.parallel = n
+	m.growth = 0
+	m.statsLock.Lock()
+	defer m.statsLock.Unlock()
+	m.stats.Parallel = n
+}
+
 func (m *mgr) handlePollingError(c *chunk) {
 	if c.err == errRestartChunk {
 		c.chunkID += "R"
@@ -346,7 +830,21 @@ func (m *mgr) handlePollingError(c *chunk) {
 		return
 	}
 
+	if c.err == errChunkDeadline {
+		m.handleChunkDeadline(c)
+		return
+	}
+
+	if c.err == errPreemptChunk {
+		m.requeuePreempted(c)
+		return
+	}
+
 	if c.err == errStopChunk {
+		if !c.stream.stoppable() {
+			m.logChunk(c, "owning stream died, will not stop attached", "")
+			return
+		}
 		m.logChunk(c, "owning stream died, will stop", "")
 		m.stopChunk(c)
 		return
@@ -354,6 +852,10 @@ func (m *mgr) handlePollingError(c *chunk) {
 
 	switch err := c.err.(type) {
 	case *UnexpectedQueryError:
+		if !c.stream.stoppable() {
+			m.logChunk(c, "unexpected error, will not stop attached", err.Cause.Error())
+			break
+		}
 		m.logChunk(c, "unexpected error, will stop", err.Cause.Error())
 		m.stopChunk(c)
 	case *TerminalQueryStatusError:
@@ -361,16 +863,80 @@ func (m *mgr) handlePollingError(c *chunk) {
 	}
 
 	c.Stats.RangeFailed += c.duration()
-	m.killStream(c)
+	m.failChunk(c)
+}
+
+// handleChunkDeadline stops a chunk whose Insights query ran past the
+// stream's ChunkTimeout, then splits or restarts the chunk if allowed,
+// or fails it otherwise.
+func (m *mgr) handleChunkDeadline(c *chunk) {
+	err := &DeadlineError{c.stream.Text, c.start, c.end, c.stream.ChunkTimeout}
+	m.logChunk(c, "exceeded chunk deadline, will stop", c.stream.ChunkTimeout.String())
+	stop := *c
+	m.stopChunk(&stop)
+
+	if c.ptr == nil && c.stream.queryID == "" {
+		if c.stream.SplitOnTimeout && c.duration() > c.stream.SplitUntil {
+			m.splitChunk(c)
+			c.err = nil
+			m.logChunk(c, "timed out, split", "")
+			m.finishChunk(c)
+			return
+		}
+		if ok, _ := m.retryPolicy().Retry(StartQuery, err, c.restart+1); ok {
+			c.restart++
+			c.chunkID += "R"
+			c.err = nil
+			m.makeReady(c)
+			return
+		}
+	}
+
+	c.err = err
+	c.Stats.RangeFailed += c.duration()
+	m.failChunk(c)
+}
+
+// expireStream fails a stream whose Timeout has passed. Chunks of the
+// stream which are still running are stopped when the poller notices
+// the stream has died.
+func (m *mgr) expireStream(s *stream) {
+	if !s.alive() {
+		return
+	}
+	m.logEvent("", fmt.Sprintf("query %q exceeded %s deadline", s.Text, s.Timeout))
+	s.setErr(&DeadlineError{s.Text, s.Start, s.End, s.Timeout}, true, Stats{})
 }
 
 func (m *mgr) handleChunkCompletion(c *chunk) {
+	m.logChunk(c, "completed", "")
+	m.finishChunk(c)
+}
+
+// failChunk handles a chunk which failed permanently. Normally the
+// failure kills the owning stream, but if the stream allows partial
+// results, the chunk's time range is recorded as failed and the stream
+// carries on with its other chunks.
+func (m *mgr) failChunk(c *chunk) {
+	if !c.stream.AllowPartial || c.err == nil || !c.stream.alive() {
+		m.killStream(c)
+		return
+	}
+
+	m.logChunk(c, "failed, continuing with partial results", c.err.Error())
+	c.stream.failed = append(c.stream.failed, FailedRange{c.start, c.end, c.err})
+	c.err = nil
+	m.finishChunk(c)
+}
+
+// finishChunk counts a chunk which is done towards the completion of
+// its stream, and ends the stream if it was the last chunk.
+func (m *mgr) finishChunk(c *chunk) {
 	c.stream.m++
 	if c.stream.m == c.stream.n {
-		c.err = io.EOF
+		c.err = c.stream.eof()
 	}
 
-	m.logChunk(c, "completed", "")
 	m.killStream(c)
 }
 
@@ -380,60 +946,121 @@ func (m *mgr) killStream(c *chunk) {
 	c.stream.lock.Lock()
 	defer c.stream.lock.Unlock()
 	m.stats.add(&c.Stats)
+	m.addTenantStats(c.stream.Tenant, &c.Stats)
 	c.stream.setErr(c.err, false, c.Stats)
+	if c.stream.err != nil {
+		delete(m.live, c.stream)
+	}
 }
 
-func (m *mgr) addStats(t *Stats) {
-	m.statsLock.Lock()
-	defer m.statsLock.Unlock()
-	m.stats.add(t)
+// getReadyChunk returns the next chunk to start, or nil if there is
+// none. The chunk comes from the tenant using the fewest Parallel slots
+// relative to its weight.
+func (m *mgr) getReadyChunk() *chunk {
+	for {
+		t := m.nextTenant()
+		if t == nil {
+			return nil
+		}
+		if c := m.getTenantChunk(t); c != nil {
+			m.turn++
+			t.served = m.turn
+			return c
+		}
+		m.forgetIdle(t)
+	}
 }
 
-func (m *mgr) getReadyChunk() *chunk {
-	for m.numReady == 0 && len(m.pq) > 0 {
-		s := heap.Pop(&m.pq).(*stream)
+// getTenantChunk returns the next chunk to start from a tenant, or nil
+// if all the tenant's waiting streams turned out to be dead or already
+// running as many chunks as their MaxParallel allows.
+func (m *mgr) getTenantChunk(t *tenant) *chunk {
+	for {
+		c := m.getTenantReadyChunk(t)
+		if c == nil || !c.stream.capped() && !c.stream.isPaused() {
+			return c
+		}
+		c.stream.held = append(c.stream.held, c)
+	}
+}
+
+// getTenantReadyChunk returns the next ready chunk of a tenant, making
+// a new chunk from the tenant's next waiting stream if necessary.
+func (m *mgr) getTenantReadyChunk(t *tenant) *chunk {
+	for t.numReady == 0 && len(t.pq) > 0 {
+		s := m.popStream(t)
 		if !s.alive() {
 			continue
 		}
-
-		start, end := s.nextChunkRange()
-		chunkID := strconv.Itoa(int(s.next))
-		s.next++
-		if s.next < s.n {
-			heap.Push(&m.pq, s)
+		if s.isPaused() {
+			s.sleeping = true
+			continue
+		}
+		if s.capped() {
+			s.parked = true
+			continue
 		}
 
-		c := &chunk{
-			stream:  s,
-			ctx:     context.WithValue(s.ctx, chunkIDKey, chunkID),
-			chunkID: chunkID,
-			start:   start,
-			end:     end,
+		var c *chunk
+		if len(s.preempted) > 0 {
+			c = s.preempted[0]
+			s.preempted = s.preempted[1:]
+		} else {
+			start, end := s.nextChunkRange()
+			chunkID := strconv.Itoa(int(s.next))
+			s.next++
+
+			c = &chunk{
+				stream:  s,
+				ctx:     context.WithValue(s.ctx, chunkIDKey, chunkID),
+				chunkID: chunkID,
+				queryID: s.queryID,
+				start:   start,
+				end:     end,
+			}
+			if s.Preview {
+				c.ptr = make(map[string]bool)
+			}
 		}
-		if s.Preview {
-			c.ptr = make(map[string]bool)
+		if s.next < s.n || len(s.preempted) > 0 {
+			m.pushStream(s)
 		}
 
-		r := ring.New(1)
-		r.Value = c
-		m.ready.Prev().Link(r)
-		m.numReady++
+		m.makeReady(c)
 	}
 
-	if m.numReady == 0 {
+	if t.numReady == 0 {
 		return nil
 	}
 
-	r := m.ready.Next()
-	m.ready.Unlink(1)
+	r := t.ready.Next()
+	t.ready.Unlink(1)
+	t.numReady--
 	m.numReady--
 	return r.Value.(*chunk)
 }
 
+// unpark returns a stream's held chunks to the ready ring, and the
+// stream itself to the stream heap, once the stream is running fewer
+// chunks than its MaxParallel allows.
+func (m *mgr) unpark(s *stream) {
+	if s.capped() || s.isPaused() {
+		return
+	}
+	for _, c := range s.held {
+		m.makeReady(c)
+	}
+	s.held = nil
+	if s.parked {
+		s.parked = false
+		m.pushStream(s)
+	}
+}
+
 func (m *mgr) stopChunk(c *chunk) {
 	c.state = stopping
 	m.numStopping++
-	m.stop <- c
+	m.dispatch(m.stop, c)
 }
 
 // splitBits is the number of child chunks into which a parent chunk
@@ -475,15 +1102,17 @@ func (m *mgr) splitChunk(c *chunk) {
 
 	m.logChunk(c, "split", b.String())
 	c.stream.n += int64(len(children))
+	t := m.tenantOf(c.stream)
+	t.numReady += len(children)
+	t.ready.Prev().Link(r)
 	m.numReady += len(children)
-	m.ready.Prev().Link(r)
 }
 
 func (m *mgr) logEvent(worker, event string) {
 	if worker == "" {
-		m.Logger.Printf("incite: QueryManager(%s) %s", m.Name, event)
+		m.logger().Printf("incite: QueryManager(%s) %s", m.Name, event)
 	} else {
-		m.Logger.Printf("incite: QueryManager(%s) %s %s", m.Name, worker, event)
+		m.logger().Printf("incite: QueryManager(%s) %s %s", m.Name, worker, event)
 	}
 }
 
@@ -493,9 +1122,9 @@ func (m *mgr) logChunk(c *chunk, msg, detail string) {
 		id += "(" + c.queryID + ")"
 	}
 	if detail == "" {
-		m.Logger.Printf("incite: QueryManager(%s) %s chunk %s %q [%s..%s)", m.Name, msg, id, c.stream.Text, c.start, c.end)
+		m.logger().Printf("incite: QueryManager(%s) %s chunk %s %q [%s..%s)", m.Name, msg, id, c.stream.Text, c.start, c.end)
 	} else {
-		m.Logger.Printf("incite: QueryManager(%s) %s chunk %s %q [%s..%s): %s", m.Name, msg, id, c.stream.Text, c.start, c.end, detail)
+		m.logger().Printf("incite: QueryManager(%s) %s chunk %s %q [%s..%s): %s", m.Name, msg, id, c.stream.Text, c.start, c.end, detail)
 	}
 }
 
@@ -511,14 +1140,14 @@ func (h streamHeap) Len() int {
 // the read lock on the manager that owns the stream heap, and may only
 // compare stream fields that are either (A) immutable within the stream,
 // as in Priority; or (B) are mutable only by the manager and not the
-// stream, as in next.
+// stream, as in rank, next, and seq.
 func (h streamHeap) Less(i, j int) bool {
-	if h[i].Priority < h[j].Priority {
-		return true
-	} else if h[j].Priority < h[i].Priority {
-		return false
-	} else {
+	if h[i].rank != h[j].rank {
+		return h[i].rank < h[j].rank
+	} else if h[i].next != h[j].next {
 		return h[i].next < h[j].next
+	} else {
+		return h[i].seq < h[j].seq
 	}
 }
 
@@ -8,12 +8,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -27,6 +30,34 @@ func TestNewQueryManager(t *testing.T) {
 				NewQueryManager(Config{})
 			})
 		})
+		t.Run("Negative QueryConcurrencyQuota", func(t *testing.T) {
+			assert.PanicsWithValue(t, badQueryConcurrencyQuotaMsg, func() {
+				NewQueryManager(Config{
+					Actions:               newMockActions(t),
+					QueryConcurrencyQuota: -1,
+				})
+			})
+		})
+		t.Run("Negative RPSQuota", func(t *testing.T) {
+			assert.PanicsWithValue(t, badRPSQuotaMsg, func() {
+				NewQueryManager(Config{
+					Actions: newMockActions(t),
+					RPSQuota: map[CloudWatchLogsAction]int{
+						StopQuery: -1,
+					},
+				})
+			})
+		})
+		t.Run("Unknown RPSQuota Action", func(t *testing.T) {
+			assert.PanicsWithValue(t, badActionMsg, func() {
+				NewQueryManager(Config{
+					Actions: newMockActions(t),
+					RPSQuota: map[CloudWatchLogsAction]int{
+						numActions: 1,
+					},
+				})
+			})
+		})
 	})
 
 	t.Run("Valid Input", func(t *testing.T) {
@@ -96,6 +127,60 @@ func TestNewQueryManager(t *testing.T) {
 						Logger:   NopLogger,
 					},
 				},
+				{
+					name: "QueryConcurrencyQuota.Raised",
+					before: Config{
+						Actions:               actions,
+						QueryConcurrencyQuota: 30,
+					},
+					after: Config{
+						Actions:               actions,
+						Parallel:              28,
+						QueryConcurrencyQuota: 30,
+						Logger:                NopLogger,
+					},
+				},
+				{
+					name: "QueryConcurrencyQuota.Raised.Parallel.AboveDefaultLimit",
+					before: Config{
+						Actions:               actions,
+						Parallel:              QueryConcurrencyQuotaLimit + 1,
+						QueryConcurrencyQuota: 30,
+					},
+					after: Config{
+						Actions:               actions,
+						Parallel:              QueryConcurrencyQuotaLimit + 1,
+						QueryConcurrencyQuota: 30,
+						Logger:                NopLogger,
+					},
+				},
+				{
+					name: "QueryConcurrencyQuota.Raised.Parallel.AboveLimit",
+					before: Config{
+						Actions:               actions,
+						Parallel:              31,
+						QueryConcurrencyQuota: 30,
+					},
+					after: Config{
+						Actions:               actions,
+						Parallel:              30,
+						QueryConcurrencyQuota: 30,
+						Logger:                NopLogger,
+					},
+				},
+				{
+					name: "QueryConcurrencyQuota.Tiny",
+					before: Config{
+						Actions:               actions,
+						QueryConcurrencyQuota: 1,
+					},
+					after: Config{
+						Actions:               actions,
+						Parallel:              1,
+						QueryConcurrencyQuota: 1,
+						Logger:                NopLogger,
+					},
+				},
 				{
 					name: "RPS.SameAsDefault",
 					before: Config{
@@ -364,6 +449,29 @@ func TestNewQueryManager(t *testing.T) {
 						Logger: NopLogger,
 					},
 				},
+				{
+					name: "RPS.StartQueryOverride.RaisedQuota",
+					before: Config{
+						Actions: actions,
+						RPS: map[CloudWatchLogsAction]int{
+							StartQuery: RPSQuotaLimits[StartQuery] + 5,
+						},
+						RPSQuota: map[CloudWatchLogsAction]int{
+							StartQuery: RPSQuotaLimits[StartQuery] + 5,
+						},
+					},
+					after: Config{
+						Actions:  actions,
+						Parallel: DefaultParallel,
+						RPS: map[CloudWatchLogsAction]int{
+							StartQuery: RPSQuotaLimits[StartQuery] + 5,
+						},
+						RPSQuota: map[CloudWatchLogsAction]int{
+							StartQuery: RPSQuotaLimits[StartQuery] + 5,
+						},
+						Logger: NopLogger,
+					},
+				},
 				{
 					name: "Logger.NopLogger",
 					before: Config{
@@ -413,6 +521,61 @@ func TestNewQueryManager(t *testing.T) {
 			}
 		})
 
+		t.Run("RPS Derived From RPSQuota", func(t *testing.T) {
+			m := NewQueryManager(Config{
+				Actions: actions,
+				RPS: map[CloudWatchLogsAction]int{
+					GetQueryResults: 4,
+				},
+				RPSQuota: map[CloudWatchLogsAction]int{
+					StartQuery:      12,
+					GetQueryResults: 50,
+				},
+			})
+			require.NotNil(t, m)
+			defer func() {
+				err := m.Close()
+				assert.NoError(t, err)
+			}()
+
+			require.IsType(t, &mgr{}, m)
+			m2 := m.(*mgr)
+			assert.Equal(t, time.Second/10, m2.starter.minDelay)
+			assert.Equal(t, time.Second/4, m2.poller.minDelay)
+			assert.Equal(t, time.Second/time.Duration(RPSDefaults[StopQuery]), m2.stopper.minDelay)
+			assert.Equal(t, time.Second/time.Duration(RPSDefaults[GetLogRecord]), m2.hydrator.minDelay)
+		})
+
+		t.Run("Burst Limited By RPSQuota Less RPS", func(t *testing.T) {
+			m := NewQueryManager(Config{
+				Actions: actions,
+				RPS: map[CloudWatchLogsAction]int{
+					GetQueryResults: 4,
+					GetLogRecord:    4,
+				},
+				Burst: map[CloudWatchLogsAction]int{
+					StartQuery:      100,
+					GetQueryResults: 3,
+					GetLogRecord:    15,
+				},
+				RPSQuota: map[CloudWatchLogsAction]int{
+					GetLogRecord: 10,
+				},
+			})
+			require.NotNil(t, m)
+			defer func() {
+				err := m.Close()
+				assert.NoError(t, err)
+			}()
+
+			require.IsType(t, &mgr{}, m)
+			m2 := m.(*mgr)
+			assert.Equal(t, RPSQuotaLimits[StartQuery]-RPSDefaults[StartQuery], m2.starter.burst)
+			assert.Equal(t, 0, m2.poller.burst)
+			assert.Equal(t, 0, m2.stopper.burst)
+			assert.Equal(t, 6, m2.hydrator.burst)
+		})
+
 		t.Run("Custom Logger", func(t *testing.T) {
 			logger := newMockLogger(t)
 			logger.ExpectPrintf("incite: QueryManager(%s) %s", t.Name(), "started").Maybe()
@@ -427,6 +590,9 @@ func TestNewQueryManager(t *testing.T) {
 			logger.ExpectPrintf("incite: QueryManager(%s) %s %s", t.Name(), "stopper", "started").Maybe()
 			logger.ExpectPrintf("incite: QueryManager(%s) %s %s", t.Name(), "stopper", "stopping...").Maybe()
 			logger.ExpectPrintf("incite: QueryManager(%s) %s %s", t.Name(), "stopper", "stopped").Maybe()
+			logger.ExpectPrintf("incite: QueryManager(%s) %s %s", t.Name(), "hydrator", "started").Maybe()
+			logger.ExpectPrintf("incite: QueryManager(%s) %s %s", t.Name(), "hydrator", "stopping...").Maybe()
+			logger.ExpectPrintf("incite: QueryManager(%s) %s %s", t.Name(), "hydrator", "stopped").Maybe()
 			m := NewQueryManager(Config{
 				Actions: actions,
 				Logger:  logger,
@@ -768,6 +934,28 @@ func TestQueryManager_Query(t *testing.T) {
 				},
 				err: splitUntilWithoutMaxLimitMsg,
 			},
+			{
+				name: "SplitOnTimeout.Without.SplitUntil",
+				QuerySpec: QuerySpec{
+					Text:           "He gives his harness bells a shake",
+					Start:          defaultStart,
+					End:            defaultEnd,
+					Groups:         []string{"To ask if there is some mistake"},
+					SplitOnTimeout: true,
+				},
+				err: splitOnStatusWithoutSplitUntilMsg,
+			},
+			{
+				name: "SplitOnFailed.Without.SplitUntil",
+				QuerySpec: QuerySpec{
+					Text:          "The only other sound's the sweep",
+					Start:         defaultStart,
+					End:           defaultEnd,
+					Groups:        []string{"Of easy wind and downy flake"},
+					SplitOnFailed: true,
+				},
+				err: splitOnStatusWithoutSplitUntilMsg,
+			},
 		}
 
 		for _, testCase := range testCases {
@@ -1511,6 +1699,9 @@ func TestQueryManager_Query(t *testing.T) {
 			logger.ExpectPrintf("incite: QueryManager(%s) %s %s", t.Name(), "stopper", "started").Maybe()
 			logger.ExpectPrintf("incite: QueryManager(%s) %s %s", t.Name(), "stopper", "stopping...").Maybe()
 			logger.ExpectPrintf("incite: QueryManager(%s) %s %s", t.Name(), "stopper", "stopped").Maybe()
+			logger.ExpectPrintf("incite: QueryManager(%s) %s %s", t.Name(), "hydrator", "started").Maybe()
+			logger.ExpectPrintf("incite: QueryManager(%s) %s %s", t.Name(), "hydrator", "stopping...").Maybe()
+			logger.ExpectPrintf("incite: QueryManager(%s) %s %s", t.Name(), "hydrator", "stopped").Maybe()
 			actions := newMockActions(t)
 			text := "a query in two chunks which generates logs"
 			// CHUNK 1.
@@ -1783,6 +1974,9 @@ func TestQueryManager_Query(t *testing.T) {
 				logger.ExpectPrintf("incite: QueryManager(%s) %s %s", t.Name(), "stopper", "started").Maybe()
 				logger.ExpectPrintf("incite: QueryManager(%s) %s %s", t.Name(), "stopper", "stopping...").Maybe()
 				logger.ExpectPrintf("incite: QueryManager(%s) %s %s", t.Name(), "stopper", "stopped").Maybe()
+				logger.ExpectPrintf("incite: QueryManager(%s) %s %s", t.Name(), "hydrator", "started").Maybe()
+				logger.ExpectPrintf("incite: QueryManager(%s) %s %s", t.Name(), "hydrator", "stopping...").Maybe()
+				logger.ExpectPrintf("incite: QueryManager(%s) %s %s", t.Name(), "hydrator", "stopped").Maybe()
 				m := NewQueryManager(Config{
 					Actions: actions,
 					RPS:     lotsOfRPS,
@@ -1954,3 +2148,1017 @@ func TestQueryManager_Query(t *testing.T) {
 		}
 	})
 }
+
+func TestQueryManager_Attach(t *testing.T) {
+	t.Run("Invalid Input", func(t *testing.T) {
+		m := NewQueryManager(Config{
+			Actions: newMockActions(t),
+		})
+		t.Cleanup(func() {
+			_ = m.Close()
+		})
+
+		s, err := m.(Attacher).Attach(" \t", AttachSpec{})
+
+		assert.Nil(t, s)
+		assert.EqualError(t, err, queryIDBlankMsg)
+	})
+
+	t.Run("QueryManager Already Closed", func(t *testing.T) {
+		m := NewQueryManager(Config{
+			Actions: newMockActions(t),
+		})
+		err := m.Close()
+		require.NoError(t, err)
+
+		s, err := m.(Attacher).Attach("foo", AttachSpec{})
+
+		assert.Nil(t, s)
+		assert.Same(t, ErrClosed, err)
+	})
+
+	t.Run("Attached Query Is Polled to Completion", func(t *testing.T) {
+		queryID := "started-elsewhere"
+		actions := newMockActions(t)
+		actions.
+			On("GetQueryResultsWithContext", anyContext, &cloudwatchlogs.GetQueryResultsInput{QueryId: &queryID}).
+			Return(&cloudwatchlogs.GetQueryResultsOutput{
+				Status:  sp(cloudwatchlogs.QueryStatusRunning),
+				Results: backOut([]Result{{{"@ptr", "1"}}}),
+			}, nil).
+			Once()
+		actions.
+			On("GetQueryResultsWithContext", anyContext, &cloudwatchlogs.GetQueryResultsInput{QueryId: &queryID}).
+			Return(&cloudwatchlogs.GetQueryResultsOutput{
+				Status:     sp(cloudwatchlogs.QueryStatusComplete),
+				Results:    backOut([]Result{{{"@ptr", "1"}}, {{"@ptr", "2"}}}),
+				Statistics: &cloudwatchlogs.QueryStatistics{RecordsMatched: float64p(2)},
+			}, nil).
+			Once()
+		m := NewQueryManager(Config{
+			Actions: actions,
+			RPS:     lotsOfRPS,
+		})
+		t.Cleanup(func() {
+			_ = m.Close()
+		})
+
+		s, err := m.(Attacher).Attach(queryID, AttachSpec{
+			Text:    "fields @ptr",
+			Preview: true,
+		})
+		require.NoError(t, err)
+		require.NotNil(t, s)
+		r, err := ReadAll(s)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []Result{{{"@ptr", "1"}}, {{"@ptr", "2"}}}, r)
+		assert.Equal(t, Stats{RecordsMatched: 2}, s.GetStats())
+		actions.AssertExpectations(t)
+	})
+
+	t.Run("Failed Attached Query Is Not Restarted", func(t *testing.T) {
+		queryID := "doomed-elsewhere"
+		actions := newMockActions(t)
+		actions.
+			On("GetQueryResultsWithContext", anyContext, &cloudwatchlogs.GetQueryResultsInput{QueryId: &queryID}).
+			Return(&cloudwatchlogs.GetQueryResultsOutput{
+				Status: sp(cloudwatchlogs.QueryStatusFailed),
+			}, nil).
+			Once()
+		m := NewQueryManager(Config{
+			Actions: actions,
+			RPS:     lotsOfRPS,
+		})
+		t.Cleanup(func() {
+			_ = m.Close()
+		})
+
+		s, err := m.(Attacher).Attach(queryID, AttachSpec{})
+		require.NoError(t, err)
+		_, err = ReadAll(s)
+
+		assert.Equal(t, &TerminalQueryStatusError{queryID, cloudwatchlogs.QueryStatusFailed, ""}, err)
+		actions.AssertExpectations(t)
+	})
+
+	t.Run("Closing Stream", func(t *testing.T) {
+		testCases := []struct {
+			name        string
+			stopOnClose bool
+			expected    string
+		}{
+			{
+				name:        "StopOnClose",
+				stopOnClose: true,
+				expected:    "stopped",
+			},
+			{
+				name:     "No StopOnClose",
+				expected: "owning stream died, will not stop attached",
+			},
+		}
+
+		for _, testCase := range testCases {
+			t.Run(testCase.name, func(t *testing.T) {
+				queryID := "long-running"
+				polled := make(chan struct{})
+				var once sync.Once
+				actions := newMockActions(t)
+				actions.
+					On("GetQueryResultsWithContext", anyContext, &cloudwatchlogs.GetQueryResultsInput{QueryId: &queryID}).
+					Run(func(_ mock.Arguments) {
+						once.Do(func() { close(polled) })
+					}).
+					Return(&cloudwatchlogs.GetQueryResultsOutput{
+						Status: sp(cloudwatchlogs.QueryStatusRunning),
+					}, nil)
+				if testCase.stopOnClose {
+					actions.
+						On("StopQueryWithContext", anyContext, &cloudwatchlogs.StopQueryInput{QueryId: &queryID}).
+						Return(&cloudwatchlogs.StopQueryOutput{Success: aws.Bool(true)}, nil).
+						Once()
+				}
+				logger := make(chanLogger, 100)
+				m := NewQueryManager(Config{
+					Actions: actions,
+					RPS:     lotsOfRPS,
+					Logger:  logger,
+				})
+				t.Cleanup(func() {
+					_ = m.Close()
+				})
+
+				s, err := m.(Attacher).Attach(queryID, AttachSpec{StopOnClose: testCase.stopOnClose})
+				require.NoError(t, err)
+				<-polled
+				err = s.Close()
+				require.NoError(t, err)
+
+				for msg := range logger {
+					if strings.Contains(msg, testCase.expected+" chunk 0("+queryID+")") {
+						break
+					}
+				}
+				actions.AssertExpectations(t)
+			})
+		}
+	})
+}
+
+func TestQueryManager_MaxInFlight(t *testing.T) {
+	const n = 8
+	const latency = 50 * time.Millisecond
+	text := "a query with slow polls"
+	actions := newMockActions(t)
+	for i := 0; i < n; i++ {
+		queryID := strconv.Itoa(i)
+		start := defaultStart.Add(time.Duration(i) * time.Minute)
+		actions.
+			On("StartQueryWithContext", anyContext, startQueryInput(text, start, start.Add(time.Minute), DefaultLimit, "grp")).
+			Return(&cloudwatchlogs.StartQueryOutput{QueryId: &queryID}, nil).
+			Once()
+		actions.
+			On("GetQueryResultsWithContext", anyContext, &cloudwatchlogs.GetQueryResultsInput{QueryId: &queryID}).
+			After(latency).
+			Return(&cloudwatchlogs.GetQueryResultsOutput{
+				Status:  sp(cloudwatchlogs.QueryStatusComplete),
+				Results: backOut([]Result{{{"q", queryID}}}),
+			}, nil).
+			Once()
+	}
+	m := NewQueryManager(Config{
+		Actions:     actions,
+		Parallel:    n,
+		MaxInFlight: n,
+		RPS:         lotsOfRPS,
+	})
+	t.Cleanup(func() {
+		_ = m.Close()
+	})
+
+	before := time.Now()
+	s, err := m.Query(QuerySpec{
+		Text:   text,
+		Groups: []string{"grp"},
+		Start:  defaultStart,
+		End:    defaultStart.Add(n * time.Minute),
+		Chunk:  time.Minute,
+	})
+	require.NoError(t, err)
+	require.NotNil(t, s)
+	r, err := ReadAll(s)
+	elapsed := time.Since(before)
+
+	assert.NoError(t, err)
+	assert.Len(t, r, n)
+	assert.Less(t, elapsed, n*latency/2, "polls must overlap")
+	actions.AssertExpectations(t)
+}
+
+func TestQueryManager_AdaptiveParallel(t *testing.T) {
+	t.Run("AIMD", func(t *testing.T) {
+		m := &mgr{
+			Config: Config{
+				Parallel:         8,
+				AdaptiveParallel: true,
+				Logger:           NopLogger,
+			},
+			parallel: 8,
+		}
+		s := &stream{}
+
+		m.handleLimitExceeded(&chunk{stream: s})
+		assert.Equal(t, 4, m.parallel)
+		assert.Equal(t, 1, m.numReady)
+		m.handleLimitExceeded(&chunk{stream: s})
+		assert.Equal(t, 4, m.parallel, "second chunk from same window must not decrease parallel")
+		m.handleLimitExceeded(&chunk{stream: s, window: 1})
+		assert.Equal(t, 2, m.parallel)
+		m.handleLimitExceeded(&chunk{stream: s, window: 2})
+		assert.Equal(t, 1, m.parallel)
+		m.handleLimitExceeded(&chunk{stream: s, window: 3})
+		assert.Equal(t, 1, m.parallel, "parallel must not go below one")
+		assert.Equal(t, 1, m.GetStats().Parallel)
+
+		m.increaseParallel()
+		assert.Equal(t, 2, m.parallel)
+		m.increaseParallel()
+		assert.Equal(t, 2, m.parallel)
+		m.increaseParallel()
+		assert.Equal(t, 3, m.parallel)
+		for i := 0; i < 100; i++ {
+			m.increaseParallel()
+		}
+		assert.Equal(t, 8, m.parallel, "parallel must not exceed Config.Parallel")
+		assert.Equal(t, 8, m.GetStats().Parallel)
+	})
+
+	t.Run("Query Succeeds After Limit Exceeded", func(t *testing.T) {
+		text := "an adaptive query"
+		queryID := "adaptive"
+		actions := newMockActions(t)
+		actions.
+			On("StartQueryWithContext", anyContext, startQueryInput(text, defaultStart, defaultEnd, DefaultLimit, "grp")).
+			Return(nil, cwlErr(cloudwatchlogs.ErrCodeLimitExceededException, "too many queries")).
+			Once()
+		actions.
+			On("StartQueryWithContext", anyContext, startQueryInput(text, defaultStart, defaultEnd, DefaultLimit, "grp")).
+			Return(&cloudwatchlogs.StartQueryOutput{QueryId: &queryID}, nil).
+			Once()
+		actions.
+			On("GetQueryResultsWithContext", anyContext, &cloudwatchlogs.GetQueryResultsInput{QueryId: &queryID}).
+			Return(&cloudwatchlogs.GetQueryResultsOutput{
+				Status:  sp(cloudwatchlogs.QueryStatusComplete),
+				Results: backOut([]Result{{{"@ptr", "1"}}}),
+			}, nil).
+			Once()
+		m := NewQueryManager(Config{
+			Actions:          actions,
+			Parallel:         2,
+			AdaptiveParallel: true,
+			RPS:              lotsOfRPS,
+		})
+		t.Cleanup(func() {
+			_ = m.Close()
+		})
+		assert.Equal(t, 2, m.GetStats().Parallel)
+
+		s, err := m.Query(QuerySpec{
+			Text:   text,
+			Groups: []string{"grp"},
+			Start:  defaultStart,
+			End:    defaultEnd,
+		})
+		require.NoError(t, err)
+		require.NotNil(t, s)
+		r, err := ReadAll(s)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []Result{{{"@ptr", "1"}}}, r)
+		assert.Equal(t, 2, m.GetStats().Parallel)
+		actions.AssertExpectations(t)
+	})
+
+	t.Run("Limit Exceeded Does Not Use Up Retries", func(t *testing.T) {
+		text := "a persistent adaptive query"
+		queryID := "persistent"
+		actions := newMockActions(t)
+		actions.
+			On("StartQueryWithContext", anyContext, startQueryInput(text, defaultStart, defaultEnd, DefaultLimit, "grp")).
+			Return(nil, cwlErr(cloudwatchlogs.ErrCodeLimitExceededException, "still too many queries")).
+			Times(maxTempStartingErrs + 2)
+		actions.
+			On("StartQueryWithContext", anyContext, startQueryInput(text, defaultStart, defaultEnd, DefaultLimit, "grp")).
+			Return(&cloudwatchlogs.StartQueryOutput{QueryId: &queryID}, nil).
+			Once()
+		actions.
+			On("GetQueryResultsWithContext", anyContext, &cloudwatchlogs.GetQueryResultsInput{QueryId: &queryID}).
+			Return(&cloudwatchlogs.GetQueryResultsOutput{Status: sp(cloudwatchlogs.QueryStatusComplete)}, nil).
+			Once()
+		m := NewQueryManager(Config{
+			Actions:          actions,
+			AdaptiveParallel: true,
+			RPS:              lotsOfRPS,
+			Backoff: Backoff{
+				Base: time.Millisecond,
+				Max:  2 * time.Millisecond,
+			},
+		})
+		t.Cleanup(func() {
+			_ = m.Close()
+		})
+
+		s, err := m.Query(QuerySpec{
+			Text:   text,
+			Groups: []string{"grp"},
+			Start:  defaultStart,
+			End:    defaultEnd,
+		})
+		require.NoError(t, err)
+		require.NotNil(t, s)
+		_, err = ReadAll(s)
+
+		assert.NoError(t, err)
+		actions.AssertExpectations(t)
+	})
+
+	t.Run("Restart Delay Grows", func(t *testing.T) {
+		m := &mgr{
+			Config: Config{
+				Parallel:         2,
+				AdaptiveParallel: true,
+				Logger:           NopLogger,
+			},
+			parallel: 2,
+		}
+		c := &chunk{stream: &stream{}}
+
+		var prev time.Duration
+		for i := 1; i <= 4; i++ {
+			before := time.Now()
+			m.handleLimitExceeded(c)
+			d := c.hold.Sub(before)
+
+			assert.Equal(t, i, c.limited)
+			assert.GreaterOrEqual(t, d, limitExceededBase<<(i-1)/2)
+			assert.Greater(t, d, prev/2)
+			prev = d
+		}
+		assert.LessOrEqual(t, m.limitDelay(100), limitExceededMax)
+
+		w := &worker{}
+		w.receive(c)
+		assert.True(t, c.hold.IsZero())
+		assert.True(t, c.due.After(time.Now()), "starter must not restart chunk before hold time")
+	})
+}
+
+// chanLogger is a Logger which sends each formatted message to itself.
+type chanLogger chan string
+
+func (l chanLogger) Printf(format string, v ...interface{}) {
+	l <- fmt.Sprintf(format, v...)
+}
+
+func TestQueryManager_AllowPartial(t *testing.T) {
+	text := "query with some failing chunks"
+	chunkStart := func(c int) time.Time {
+		return defaultStart.Add(time.Duration(c) * time.Minute)
+	}
+	startInput := func(c int) *cloudwatchlogs.StartQueryInput {
+		return startQueryInput(text, chunkStart(c), chunkStart(c+1), DefaultLimit, "grp")
+	}
+	malformed := cwlErr(cloudwatchlogs.ErrCodeMalformedQueryException, "unexpected symbol")
+
+	t.Run("Failed Chunks Are Reported at End", func(t *testing.T) {
+		actions := newMockActions(t)
+		actions.
+			On("StartQueryWithContext", anyContext, startInput(0)).
+			Return(nil, malformed).
+			Once()
+		actions.
+			On("StartQueryWithContext", anyContext, startInput(1)).
+			Return(&cloudwatchlogs.StartQueryOutput{QueryId: sp("timeout")}, nil).
+			Once()
+		actions.
+			On("GetQueryResultsWithContext", anyContext, &cloudwatchlogs.GetQueryResultsInput{QueryId: sp("timeout")}).
+			Return(&cloudwatchlogs.GetQueryResultsOutput{Status: sp("Timeout")}, nil).
+			Once()
+		actions.
+			On("StartQueryWithContext", anyContext, startInput(2)).
+			Return(&cloudwatchlogs.StartQueryOutput{QueryId: sp("ok")}, nil).
+			Once()
+		actions.
+			On("GetQueryResultsWithContext", anyContext, &cloudwatchlogs.GetQueryResultsInput{QueryId: sp("ok")}).
+			Return(&cloudwatchlogs.GetQueryResultsOutput{
+				Results: [][]*cloudwatchlogs.ResultField{
+					{{Field: sp("@message"), Value: sp("survivor")}},
+				},
+				Status: sp(cloudwatchlogs.QueryStatusComplete),
+			}, nil).
+			Once()
+		m := NewQueryManager(Config{
+			Actions: actions,
+			RPS:     lotsOfRPS,
+		})
+		t.Cleanup(func() {
+			_ = m.Close()
+		})
+		s, err := m.Query(QuerySpec{
+			Text:         text,
+			Groups:       []string{"grp"},
+			Start:        chunkStart(0),
+			End:          chunkStart(3),
+			Chunk:        time.Minute,
+			AllowPartial: true,
+		})
+		require.NoError(t, err)
+
+		r, err := ReadAll(s)
+
+		assert.Equal(t, []Result{{{"@message", "survivor"}}}, r)
+		var pre *PartialResultsError
+		require.ErrorAs(t, err, &pre)
+		assert.Equal(t, text, pre.Text)
+		require.Len(t, pre.Failed, 2)
+		sort.Slice(pre.Failed, func(i, j int) bool {
+			return pre.Failed[i].Start.Before(pre.Failed[j].Start)
+		})
+		assert.Equal(t, chunkStart(0), pre.Failed[0].Start)
+		assert.Equal(t, chunkStart(1), pre.Failed[0].End)
+		assert.True(t, errors.Is(pre.Failed[0].Cause, ErrMalformedQuery))
+		assert.Equal(t, chunkStart(1), pre.Failed[1].Start)
+		assert.Equal(t, chunkStart(2), pre.Failed[1].End)
+		assert.True(t, errors.Is(pre.Failed[1].Cause, ErrQueryTimeout))
+		assert.Equal(t, 2*time.Minute, s.GetStats().RangeFailed)
+		actions.AssertExpectations(t)
+	})
+
+	t.Run("No Failed Chunks", func(t *testing.T) {
+		actions := newMockActions(t)
+		actions.
+			On("StartQueryWithContext", anyContext, startInput(0)).
+			Return(&cloudwatchlogs.StartQueryOutput{QueryId: sp("ok")}, nil).
+			Once()
+		actions.
+			On("GetQueryResultsWithContext", anyContext, &cloudwatchlogs.GetQueryResultsInput{QueryId: sp("ok")}).
+			Return(&cloudwatchlogs.GetQueryResultsOutput{Status: sp(cloudwatchlogs.QueryStatusComplete)}, nil).
+			Once()
+		m := NewQueryManager(Config{
+			Actions: actions,
+			RPS:     lotsOfRPS,
+		})
+		t.Cleanup(func() {
+			_ = m.Close()
+		})
+		s, err := m.Query(QuerySpec{
+			Text:         text,
+			Groups:       []string{"grp"},
+			Start:        chunkStart(0),
+			End:          chunkStart(1),
+			AllowPartial: true,
+		})
+		require.NoError(t, err)
+
+		r, err := s.Read(make([]Result, 1))
+
+		assert.Equal(t, 0, r)
+		assert.Equal(t, io.EOF, err)
+		actions.AssertExpectations(t)
+	})
+}
+
+func TestQueryManager_SplitOnTimeout(t *testing.T) {
+	text := "heavy query"
+	end := defaultStart.Add(4 * time.Minute)
+	actions := newMockActions(t)
+	actions.
+		On("StartQueryWithContext", anyContext, startQueryInput(text, defaultStart, end, DefaultLimit, "grp")).
+		Return(&cloudwatchlogs.StartQueryOutput{QueryId: sp("parent")}, nil).
+		Once()
+	actions.
+		On("GetQueryResultsWithContext", anyContext, &cloudwatchlogs.GetQueryResultsInput{QueryId: sp("parent")}).
+		Return(&cloudwatchlogs.GetQueryResultsOutput{Status: sp("Timeout")}, nil).
+		Once()
+	for i := 0; i < splitBits; i++ {
+		queryID := fmt.Sprintf("child%d", i)
+		childStart := defaultStart.Add(time.Duration(i) * time.Minute)
+		actions.
+			On("StartQueryWithContext", anyContext, startQueryInput(text, childStart, childStart.Add(time.Minute), DefaultLimit, "grp")).
+			Return(&cloudwatchlogs.StartQueryOutput{QueryId: &queryID}, nil).
+			Once()
+		actions.
+			On("GetQueryResultsWithContext", anyContext, &cloudwatchlogs.GetQueryResultsInput{QueryId: &queryID}).
+			Return(&cloudwatchlogs.GetQueryResultsOutput{
+				Results: [][]*cloudwatchlogs.ResultField{
+					{{Field: sp("child"), Value: sp(strconv.Itoa(i))}},
+				},
+				Status: sp(cloudwatchlogs.QueryStatusComplete),
+			}, nil).
+			Once()
+	}
+	m := NewQueryManager(Config{
+		Actions: actions,
+		RPS:     lotsOfRPS,
+	})
+	t.Cleanup(func() {
+		_ = m.Close()
+	})
+	s, err := m.Query(QuerySpec{
+		Text:           text,
+		Groups:         []string{"grp"},
+		Start:          defaultStart,
+		End:            end,
+		SplitUntil:     time.Minute,
+		SplitOnTimeout: true,
+	})
+	require.NoError(t, err)
+
+	r, err := ReadAll(s)
+
+	require.NoError(t, err)
+	sort.Slice(r, func(i, j int) bool {
+		return r[i][0].Value < r[j][0].Value
+	})
+	assert.Equal(t, []Result{
+		{{"child", "0"}},
+		{{"child", "1"}},
+		{{"child", "2"}},
+		{{"child", "3"}},
+	}, r)
+	assert.Equal(t, 4*time.Minute, s.GetStats().RangeDone)
+	actions.AssertExpectations(t)
+}
+
+func TestQueryManager_Deadlines(t *testing.T) {
+	text := "slow query"
+	stopped := true
+
+	t.Run("Stream Timeout", func(t *testing.T) {
+		actions := newMockActions(t)
+		actions.
+			On("StartQueryWithContext", anyContext, startQueryInput(text, defaultStart, defaultEnd, DefaultLimit, "grp")).
+			Return(&cloudwatchlogs.StartQueryOutput{QueryId: sp("stuck")}, nil).
+			Once()
+		actions.
+			On("GetQueryResultsWithContext", anyContext, &cloudwatchlogs.GetQueryResultsInput{QueryId: sp("stuck")}).
+			Return(&cloudwatchlogs.GetQueryResultsOutput{Status: sp(cloudwatchlogs.QueryStatusRunning)}, nil)
+		stopCh := make(chan struct{})
+		actions.
+			On("StopQueryWithContext", anyContext, &cloudwatchlogs.StopQueryInput{QueryId: sp("stuck")}).
+			Run(func(_ mock.Arguments) { close(stopCh) }).
+			Return(&cloudwatchlogs.StopQueryOutput{Success: &stopped}, nil).
+			Once()
+		m := NewQueryManager(Config{
+			Actions: actions,
+			RPS:     lotsOfRPS,
+		})
+		t.Cleanup(func() {
+			_ = m.Close()
+		})
+		s, err := m.Query(QuerySpec{
+			Text:    text,
+			Groups:  []string{"grp"},
+			Start:   defaultStart,
+			End:     defaultEnd,
+			Timeout: 50 * time.Millisecond,
+		})
+		require.NoError(t, err)
+
+		_, err = ReadAll(s)
+
+		var de *DeadlineError
+		require.ErrorAs(t, err, &de)
+		assert.Equal(t, &DeadlineError{text, defaultStart, defaultEnd, 50 * time.Millisecond}, de)
+		assert.True(t, errors.Is(err, ErrQueryTimeout))
+		<-stopCh
+	})
+
+	t.Run("Chunk Timeout Restarts Chunk", func(t *testing.T) {
+		actions := newMockActions(t)
+		actions.
+			On("StartQueryWithContext", anyContext, startQueryInput(text, defaultStart, defaultEnd, DefaultLimit, "grp")).
+			Return(&cloudwatchlogs.StartQueryOutput{QueryId: sp("stuck")}, nil).
+			Once()
+		actions.
+			On("GetQueryResultsWithContext", anyContext, &cloudwatchlogs.GetQueryResultsInput{QueryId: sp("stuck")}).
+			Return(&cloudwatchlogs.GetQueryResultsOutput{Status: sp(cloudwatchlogs.QueryStatusRunning)}, nil)
+		actions.
+			On("StopQueryWithContext", anyContext, &cloudwatchlogs.StopQueryInput{QueryId: sp("stuck")}).
+			Return(&cloudwatchlogs.StopQueryOutput{Success: &stopped}, nil).
+			Once()
+		actions.
+			On("StartQueryWithContext", anyContext, startQueryInput(text, defaultStart, defaultEnd, DefaultLimit, "grp")).
+			Return(&cloudwatchlogs.StartQueryOutput{QueryId: sp("fast")}, nil).
+			Once()
+		actions.
+			On("GetQueryResultsWithContext", anyContext, &cloudwatchlogs.GetQueryResultsInput{QueryId: sp("fast")}).
+			Return(&cloudwatchlogs.GetQueryResultsOutput{
+				Results: [][]*cloudwatchlogs.ResultField{
+					{{Field: sp("@message"), Value: sp("finally")}},
+				},
+				Status: sp(cloudwatchlogs.QueryStatusComplete),
+			}, nil).
+			Once()
+		m := NewQueryManager(Config{
+			Actions: actions,
+			RPS:     lotsOfRPS,
+		})
+		t.Cleanup(func() {
+			_ = m.Close()
+		})
+		s, err := m.Query(QuerySpec{
+			Text:         text,
+			Groups:       []string{"grp"},
+			Start:        defaultStart,
+			End:          defaultEnd,
+			ChunkTimeout: 50 * time.Millisecond,
+		})
+		require.NoError(t, err)
+
+		r, err := ReadAll(s)
+
+		require.NoError(t, err)
+		assert.Equal(t, []Result{{{"@message", "finally"}}}, r)
+		stats := s.GetStats()
+		assert.Equal(t, defaultEnd.Sub(defaultStart), stats.RangeStarted)
+		assert.Equal(t, defaultEnd.Sub(defaultStart), stats.RangeDone)
+		assert.Equal(t, m.GetStats().RangeStarted, stats.RangeStarted)
+		actions.AssertExpectations(t)
+	})
+
+	t.Run("Chunk Timeout Fails Chunk", func(t *testing.T) {
+		actions := newMockActions(t)
+		actions.
+			On("StartQueryWithContext", anyContext, startQueryInput(text, defaultStart, defaultEnd, DefaultLimit, "grp")).
+			Return(&cloudwatchlogs.StartQueryOutput{QueryId: sp("stuck")}, nil).
+			Once()
+		actions.
+			On("GetQueryResultsWithContext", anyContext, &cloudwatchlogs.GetQueryResultsInput{QueryId: sp("stuck")}).
+			Return(&cloudwatchlogs.GetQueryResultsOutput{Status: sp(cloudwatchlogs.QueryStatusScheduled)}, nil)
+		actions.
+			On("StopQueryWithContext", anyContext, &cloudwatchlogs.StopQueryInput{QueryId: sp("stuck")}).
+			Return(&cloudwatchlogs.StopQueryOutput{Success: &stopped}, nil).
+			Maybe()
+		m := NewQueryManager(Config{
+			Actions: actions,
+			RPS:     lotsOfRPS,
+			RetryPolicy: RetryPolicyFunc(func(CloudWatchLogsAction, error, int) (bool, time.Duration) {
+				return false, 0
+			}),
+		})
+		t.Cleanup(func() {
+			_ = m.Close()
+		})
+		s, err := m.Query(QuerySpec{
+			Text:         text,
+			Groups:       []string{"grp"},
+			Start:        defaultStart,
+			End:          defaultEnd,
+			ChunkTimeout: 20 * time.Millisecond,
+		})
+		require.NoError(t, err)
+
+		_, err = ReadAll(s)
+
+		assert.Equal(t, &DeadlineError{text, defaultStart, defaultEnd, 20 * time.Millisecond}, err)
+		assert.Equal(t, defaultEnd.Sub(defaultStart), s.GetStats().RangeFailed)
+		assert.Equal(t, defaultEnd.Sub(defaultStart), s.GetStats().RangeStarted)
+	})
+}
+
+func TestMgr_handleChunkDeadline(t *testing.T) {
+	t.Run("Split", func(t *testing.T) {
+		logger := newMockLogger(t)
+		m := &mgr{
+			Config: Config{Logger: logger, Name: t.Name()},
+			stop:   make(chan *chunk, 1),
+		}
+		s := &stream{
+			QuerySpec: QuerySpec{
+				Start:          defaultStart,
+				End:            defaultStart.Add(4 * time.Minute),
+				Chunk:          4 * time.Minute,
+				ChunkTimeout:   time.Second,
+				SplitOnTimeout: true,
+				SplitUntil:     time.Minute,
+			},
+			ctx: context.Background(),
+			n:   1,
+		}
+		s.more = sync.NewCond(&s.lock)
+		m.pushStream(s)
+		c := m.getReadyChunk()
+		require.NotNil(t, c)
+		logger.ExpectPrintf("incite: QueryManager(%s) %s chunk %s %q [%s..%s): %s", t.Name(), "exceeded chunk deadline, will stop").Once()
+		logger.ExpectPrintf("incite: QueryManager(%s) %s chunk %s %q [%s..%s): %s", t.Name(), "split").Once()
+		logger.ExpectPrintf("incite: QueryManager(%s) %s chunk %s %q [%s..%s)", t.Name(), "timed out, split").Once()
+
+		m.handleChunkDeadline(c)
+
+		logger.AssertExpectations(t)
+		stopped := <-m.stop
+		assert.NotSame(t, c, stopped)
+		assert.Equal(t, stopping, stopped.state)
+		assert.Equal(t, int64(5), s.n)
+		assert.Equal(t, int64(1), s.m)
+		assert.NoError(t, s.err)
+	})
+}
+
+func TestMgr_track(t *testing.T) {
+	m := &mgr{}
+	streams := make([]*stream, 5)
+	for i := range streams {
+		streams[i] = newTenantStream("", 1)
+		streams[i].more = sync.NewCond(&streams[i].lock)
+	}
+	m.track(streams[0])
+	m.track(streams[1])
+	streams[0].setErr(ErrClosed, true, Stats{})
+	m.track(streams[2])
+	assert.Len(t, m.live, 3)
+
+	m.track(streams[3])
+
+	assert.Equal(t, map[*stream]bool{streams[1]: true, streams[2]: true, streams[3]: true}, m.live)
+
+	t.Run("Dead Stream Is Forgotten", func(t *testing.T) {
+		c := &chunk{stream: streams[1], err: io.EOF}
+
+		m.killStream(c)
+
+		assert.NotContains(t, m.live, streams[1])
+	})
+}
+
+func TestMgr_getReadyChunk_MaxParallel(t *testing.T) {
+	t.Run("Stream Is Capped", func(t *testing.T) {
+		m := &mgr{}
+		capped := newTenantStream("", 10)
+		capped.MaxParallel = 2
+		m.pushStream(capped)
+
+		running := takeChunks(m, 5)
+
+		assert.Equal(t, map[string]int{"": 2}, running)
+		assert.True(t, capped.parked)
+		assert.Nil(t, m.getReadyChunk())
+	})
+
+	t.Run("Other Streams Use Remaining Slots", func(t *testing.T) {
+		m := &mgr{}
+		capped := newTenantStream("", 10)
+		capped.MaxParallel = 1
+		other := newTenantStream("", 10)
+		m.pushStream(capped)
+		m.pushStream(other)
+		ch := make(chan *chunk, 5)
+
+		var fromCapped []*chunk
+		for i := 0; i < 5; i++ {
+			c := m.getReadyChunk()
+			require.NotNil(t, c)
+			m.dispatch(ch, c)
+			if c.stream == capped {
+				fromCapped = append(fromCapped, c)
+			}
+		}
+
+		require.Len(t, fromCapped, 1)
+		assert.Equal(t, 1, capped.active)
+		assert.Equal(t, 4, other.active)
+
+		t.Run("Release Unparks Stream", func(t *testing.T) {
+			m.release(fromCapped[0])
+
+			assert.False(t, capped.parked)
+			c := m.getReadyChunk()
+			require.NotNil(t, c)
+			assert.Same(t, capped, c.stream, "capped stream has started fewer chunks")
+		})
+	})
+
+	t.Run("Ready Chunk Is Held", func(t *testing.T) {
+		m := &mgr{}
+		capped := newTenantStream("", 1)
+		capped.MaxParallel = 1
+		m.pushStream(capped)
+		first := m.getReadyChunk()
+		require.NotNil(t, first)
+		m.dispatch(make(chan *chunk, 1), first)
+		restarted := &chunk{stream: capped, chunkID: "0R"}
+		m.makeReady(restarted)
+
+		assert.Nil(t, m.getReadyChunk())
+		assert.Equal(t, []*chunk{restarted}, capped.held)
+		assert.Equal(t, 0, m.numReady)
+
+		m.release(first)
+
+		assert.Nil(t, capped.held)
+		assert.Same(t, restarted, m.getReadyChunk())
+	})
+}
+
+func TestQueryManager_Shutdown(t *testing.T) {
+	text := "query running during shutdown"
+	spec := QuerySpec{
+		Text:   text,
+		Groups: []string{"grp"},
+		Start:  defaultStart,
+		End:    defaultEnd,
+	}
+
+	t.Run("Idle", func(t *testing.T) {
+		m := NewQueryManager(Config{
+			Actions: newMockActions(t),
+		})
+
+		err := m.(Shutdowner).Shutdown(context.Background())
+
+		assert.NoError(t, err)
+		_, err = m.Query(spec)
+		assert.Same(t, ErrClosed, err)
+		_, err = m.(Attacher).Attach("foo", AttachSpec{})
+		assert.Same(t, ErrClosed, err)
+		assert.Same(t, ErrClosed, m.(Shutdowner).Shutdown(context.Background()))
+		assert.Same(t, ErrClosed, m.Close())
+	})
+
+	t.Run("Already Closed", func(t *testing.T) {
+		m := NewQueryManager(Config{
+			Actions: newMockActions(t),
+		})
+		require.NoError(t, m.Close())
+
+		err := m.(Shutdowner).Shutdown(context.Background())
+
+		assert.Same(t, ErrClosed, err)
+	})
+
+	t.Run("Running Query Finishes", func(t *testing.T) {
+		actions := newMockActions(t)
+		actions.
+			On("StartQueryWithContext", anyContext, startQueryInput(text, defaultStart, defaultEnd, DefaultLimit, "grp")).
+			Return(&cloudwatchlogs.StartQueryOutput{QueryId: sp("finishes")}, nil).
+			Once()
+		actions.
+			On("GetQueryResultsWithContext", anyContext, &cloudwatchlogs.GetQueryResultsInput{QueryId: sp("finishes")}).
+			Return(&cloudwatchlogs.GetQueryResultsOutput{Status: sp(cloudwatchlogs.QueryStatusRunning)}, nil).
+			Twice()
+		actions.
+			On("GetQueryResultsWithContext", anyContext, &cloudwatchlogs.GetQueryResultsInput{QueryId: sp("finishes")}).
+			Return(&cloudwatchlogs.GetQueryResultsOutput{
+				Results: [][]*cloudwatchlogs.ResultField{
+					{{Field: sp("@message"), Value: sp("done")}},
+				},
+				Status: sp(cloudwatchlogs.QueryStatusComplete),
+			}, nil).
+			Once()
+		m := NewQueryManager(Config{
+			Actions: actions,
+			RPS:     lotsOfRPS,
+		})
+		s, err := m.Query(spec)
+		require.NoError(t, err)
+
+		err = m.(Shutdowner).Shutdown(context.Background())
+
+		assert.NoError(t, err)
+		r, err := ReadAll(s)
+		assert.NoError(t, err)
+		assert.Equal(t, []Result{{{"@message", "done"}}}, r)
+		actions.AssertExpectations(t)
+	})
+
+	t.Run("Context Done Before Query Finishes", func(t *testing.T) {
+		actions := newMockActions(t)
+		actions.
+			On("StartQueryWithContext", anyContext, startQueryInput(text, defaultStart, defaultEnd, DefaultLimit, "grp")).
+			Return(&cloudwatchlogs.StartQueryOutput{QueryId: sp("forever")}, nil).
+			Once()
+		actions.
+			On("GetQueryResultsWithContext", anyContext, &cloudwatchlogs.GetQueryResultsInput{QueryId: sp("forever")}).
+			Return(&cloudwatchlogs.GetQueryResultsOutput{Status: sp(cloudwatchlogs.QueryStatusRunning)}, nil)
+		actions.
+			On("StopQueryWithContext", anyContext, &cloudwatchlogs.StopQueryInput{QueryId: sp("forever")}).
+			Return(&cloudwatchlogs.StopQueryOutput{}, nil).
+			Once()
+		m := NewQueryManager(Config{
+			Actions: actions,
+			RPS:     lotsOfRPS,
+		})
+		s, err := m.Query(spec)
+		require.NoError(t, err)
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		err = m.(Shutdowner).Shutdown(ctx)
+
+		assert.Equal(t, context.DeadlineExceeded, err)
+		_, err = ReadAll(s)
+		assert.Same(t, ErrClosed, err)
+		actions.AssertExpectations(t)
+	})
+	t.Run("Paused Query Is Closed", func(t *testing.T) {
+		first := startQueryInput(text, defaultStart, defaultStart.Add(time.Minute), DefaultLimit, "grp")
+		actions := newMockActions(t)
+		started, paused := make(chan struct{}), make(chan struct{})
+		actions.
+			On("StartQueryWithContext", anyContext, first).
+			Run(func(_ mock.Arguments) {
+				close(started)
+				<-paused
+			}).
+			Return(&cloudwatchlogs.StartQueryOutput{QueryId: sp("first")}, nil).
+			Once()
+		actions.
+			On("GetQueryResultsWithContext", anyContext, &cloudwatchlogs.GetQueryResultsInput{QueryId: sp("first")}).
+			Return(&cloudwatchlogs.GetQueryResultsOutput{
+				Results: [][]*cloudwatchlogs.ResultField{
+					{{Field: sp("@message"), Value: sp("first")}},
+				},
+				Status: sp(cloudwatchlogs.QueryStatusComplete),
+			}, nil).
+			Once()
+		m := NewQueryManager(Config{
+			Actions:  actions,
+			Parallel: 1,
+			RPS:      lotsOfRPS,
+		})
+		chunked := spec
+		chunked.Chunk = time.Minute
+		s, err := m.Query(chunked)
+		require.NoError(t, err)
+		<-started
+		require.NoError(t, s.(Pauser).Pause(false))
+		close(paused)
+
+		err = m.(Shutdowner).Shutdown(context.Background())
+
+		assert.NoError(t, err)
+		r, err := ReadAll(s)
+		assert.Same(t, ErrClosed, err)
+		assert.Equal(t, []Result{{{"@message", "first"}}}, r)
+		actions.AssertExpectations(t)
+	})
+
+	t.Run("Preempted Query Is Closed", func(t *testing.T) {
+		urgentText := "urgent query running during shutdown"
+		actions := newMockActions(t)
+		lowStarted, urgentStarted := make(chan struct{}), make(chan struct{})
+		actions.
+			On("StartQueryWithContext", anyContext, startQueryInput(text, defaultStart, defaultEnd, DefaultLimit, "grp")).
+			Run(func(_ mock.Arguments) { close(lowStarted) }).
+			Return(&cloudwatchlogs.StartQueryOutput{QueryId: sp("low")}, nil).
+			Once()
+		actions.
+			On("GetQueryResultsWithContext", anyContext, &cloudwatchlogs.GetQueryResultsInput{QueryId: sp("low")}).
+			Return(&cloudwatchlogs.GetQueryResultsOutput{Status: sp(cloudwatchlogs.QueryStatusRunning)}, nil)
+		actions.
+			On("StopQueryWithContext", anyContext, &cloudwatchlogs.StopQueryInput{QueryId: sp("low")}).
+			Return(&cloudwatchlogs.StopQueryOutput{}, nil).
+			Once()
+		actions.
+			On("StartQueryWithContext", anyContext, startQueryInput(urgentText, defaultStart, defaultEnd, DefaultLimit, "grp")).
+			Run(func(_ mock.Arguments) { close(urgentStarted) }).
+			Return(&cloudwatchlogs.StartQueryOutput{QueryId: sp("urgent")}, nil).
+			Once()
+		actions.
+			On("GetQueryResultsWithContext", anyContext, &cloudwatchlogs.GetQueryResultsInput{QueryId: sp("urgent")}).
+			Return(&cloudwatchlogs.GetQueryResultsOutput{Status: sp(cloudwatchlogs.QueryStatusRunning)}, nil)
+		actions.
+			On("StopQueryWithContext", anyContext, &cloudwatchlogs.StopQueryInput{QueryId: sp("urgent")}).
+			Return(&cloudwatchlogs.StopQueryOutput{}, nil).
+			Once()
+		m := NewQueryManager(Config{
+			Actions:    actions,
+			Parallel:   1,
+			Preemption: 1,
+			RPS:        lotsOfRPS,
+		})
+		low := spec
+		low.Priority = 5
+		s, err := m.Query(low)
+		require.NoError(t, err)
+		<-lowStarted
+		urgent := spec
+		urgent.Text = urgentText
+		u, err := m.Query(urgent)
+		require.NoError(t, err)
+		<-urgentStarted
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		err = m.(Shutdowner).Shutdown(ctx)
+
+		assert.Equal(t, context.DeadlineExceeded, err)
+		_, err = ReadAll(s)
+		assert.Same(t, ErrClosed, err)
+		_, err = ReadAll(u)
+		assert.Same(t, ErrClosed, err)
+		actions.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,180 @@
+// Copyright 2022 The incite Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package incite
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+)
+
+// queryMarkerPrefix begins the Insights comment line which a
+// QueryManager appends to the text of every query it starts when its
+// Config has a QueryMarker.
+const queryMarkerPrefix = "# incite:"
+
+// markQuery returns the query text to send to CloudWatch Logs for a
+// query with the given text, started by a QueryManager with the given
+// query marker.
+func markQuery(text, marker string) string {
+	if marker == "" {
+		return text
+	}
+	return text + "\n" + queryMarkerPrefix + marker
+}
+
+// isMarked returns true if the query text was produced by markQuery
+// with the given marker.
+func isMarked(text, marker string) bool {
+	return strings.HasSuffix(text, "\n"+queryMarkerPrefix+marker)
+}
+
+func validQueryMarker(marker string) bool {
+	return !strings.ContainsAny(marker, "\r\n")
+}
+
+// StopOrphanedQueries stops all running CloudWatch Logs Insights
+// queries whose text carries marker, the query marker given in the
+// QueryMarker field of the Config of the QueryManager which started
+// them. The query IDs of the stopped queries are returned.
+//
+// The purpose of StopOrphanedQueries is to release the Insights query
+// concurrency consumed by queries left running when a process using
+// Incite crashes or is terminated without closing its QueryManager.
+// Since every query having the marker is stopped, StopOrphanedQueries
+// must not be called while another live QueryManager is using the same
+// marker. Consider setting the StopOrphans field of Config instead,
+// which has the same effect but only stops queries started before the
+// new QueryManager was created.
+//
+// The Actions a must implement QueryDescriber, or an error is
+// returned. Queries are stopped serially, at the default StopQuery
+// RPS rate given in RPSDefaults.
+func StopOrphanedQueries(ctx context.Context, a CloudWatchLogsActions, marker string) ([]string, error) {
+	if ctx == nil {
+		panic(nilContextMsg)
+	}
+	if a == nil {
+		panic(nilActionsMsg)
+	}
+	if marker == "" || !validQueryMarker(marker) {
+		return nil, errors.New(badQueryMarkerMsg)
+	}
+	describer, ok := a.(QueryDescriber)
+	if !ok {
+		return nil, errors.New(noQueryDescriberMsg)
+	}
+
+	orphans, err := describeOrphans(ctx, describer, marker, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	r := makeRegulator(ctx.Done(), 0, RPSDefaults[StopQuery])
+	defer r.timer.Stop()
+	stopped := make([]string, 0, len(orphans))
+	for _, orphan := range orphans {
+		if err = r.wait(ctx); err == errClosing {
+			err = ctx.Err()
+		}
+		if err != nil {
+			return stopped, err
+		}
+		_, err = a.StopQueryWithContext(ctx, &cloudwatchlogs.StopQueryInput{
+			QueryId: &orphan.queryID,
+		}, request.WithAppendUserAgent(version()))
+		r.lastReq = time.Now()
+		if err != nil {
+			return stopped, errStopOrphan(orphan.queryID, err)
+		}
+		stopped = append(stopped, orphan.queryID)
+	}
+
+	return stopped, nil
+}
+
+// An orphan is a running CloudWatch Logs Insights query which carries a
+// query marker.
+type orphan struct {
+	queryID string
+	text    string
+}
+
+// describeOrphans returns all Scheduled or Running Insights queries
+// created before the given time whose text is marked with marker.
+func describeOrphans(ctx context.Context, describer QueryDescriber, marker string, before time.Time) ([]orphan, error) {
+	var orphans []orphan
+	for _, status := range []string{cloudwatchlogs.QueryStatusScheduled, cloudwatchlogs.QueryStatusRunning} {
+		input := cloudwatchlogs.DescribeQueriesInput{
+			Status: &status,
+		}
+		for {
+			output, err := describer.DescribeQueriesWithContext(ctx, &input, request.WithAppendUserAgent(version()))
+			if err != nil {
+				return nil, errDescribeQueries(err)
+			}
+			for _, q := range output.Queries {
+				if q == nil || q.QueryId == nil || q.QueryString == nil {
+					continue
+				}
+				if !isMarked(*q.QueryString, marker) {
+					continue
+				}
+				if q.CreateTime != nil && *q.CreateTime >= epochMillisecond(before) {
+					continue
+				}
+				orphans = append(orphans, orphan{*q.QueryId, *q.QueryString})
+			}
+			if output.NextToken == nil || *output.NextToken == "" {
+				break
+			}
+			input.NextToken = output.NextToken
+		}
+	}
+	return orphans, nil
+}
+
+// findOrphans describes the orphaned queries left running by a previous
+// incarnation of the QueryManager and hands them to the mgr loop so
+// that they can be stopped by the stopper.
+func (m *mgr) findOrphans(before time.Time) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-m.close:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	orphans, err := describeOrphans(ctx, m.Actions.(QueryDescriber), m.QueryMarker, before)
+	if err != nil {
+		m.logEvent("", "failed to find orphaned queries: "+err.Error())
+		return
+	}
+
+	chunks := make([]*chunk, len(orphans))
+	for i := range orphans {
+		chunks[i] = &chunk{
+			stream: &stream{
+				QuerySpec: QuerySpec{
+					Text: orphans[i].text,
+				},
+			},
+			chunkID: "orphan",
+			queryID: orphans[i].queryID,
+		}
+	}
+
+	select {
+	case m.orphan <- chunks:
+	case <-m.close:
+	}
+}
@@ -0,0 +1,249 @@
+// Copyright 2022 The incite Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package incite
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestMarkQuery(t *testing.T) {
+	assert.Equal(t, "fields @message", markQuery("fields @message", ""))
+	assert.Equal(t, "fields @message\n# incite:host-1", markQuery("fields @message", "host-1"))
+	assert.True(t, isMarked(markQuery("fields @message", "host-1"), "host-1"))
+	assert.False(t, isMarked(markQuery("fields @message", "host-1"), "host-2"))
+	assert.False(t, isMarked(markQuery("fields @message", "host-11"), "host-1"))
+	assert.False(t, isMarked("fields @message", "host-1"))
+}
+
+func TestStopOrphanedQueries(t *testing.T) {
+	t.Run("Invalid Input", func(t *testing.T) {
+		assert.PanicsWithValue(t, nilContextMsg, func() {
+			_, _ = StopOrphanedQueries(nil, newMockActions(t), "m")
+		})
+		assert.PanicsWithValue(t, nilActionsMsg, func() {
+			_, _ = StopOrphanedQueries(context.Background(), nil, "m")
+		})
+		_, err := StopOrphanedQueries(context.Background(), newMockActions(t), "")
+		assert.EqualError(t, err, badQueryMarkerMsg)
+		_, err = StopOrphanedQueries(context.Background(), newMockActions(t), "a\nb")
+		assert.EqualError(t, err, badQueryMarkerMsg)
+		_, err = StopOrphanedQueries(context.Background(), struct {
+			CloudWatchLogsActions
+		}{newMockActions(t)}, "m")
+		assert.EqualError(t, err, noQueryDescriberMsg)
+	})
+
+	t.Run("Describe Fails", func(t *testing.T) {
+		cause := errors.New("cannot describe")
+		actions := newMockActions(t)
+		actions.
+			On("DescribeQueriesWithContext", anyContext, &cloudwatchlogs.DescribeQueriesInput{
+				Status: sp(cloudwatchlogs.QueryStatusScheduled),
+			}).
+			Return(nil, cause).
+			Once()
+
+		stopped, err := StopOrphanedQueries(context.Background(), actions, "m")
+
+		assert.Nil(t, stopped)
+		assert.ErrorIs(t, err, cause)
+		actions.AssertExpectations(t)
+	})
+
+	t.Run("Marked Queries Stopped", func(t *testing.T) {
+		actions := newMockActions(t)
+		actions.
+			On("DescribeQueriesWithContext", anyContext, &cloudwatchlogs.DescribeQueriesInput{
+				Status: sp(cloudwatchlogs.QueryStatusScheduled),
+			}).
+			Return(&cloudwatchlogs.DescribeQueriesOutput{
+				Queries: []*cloudwatchlogs.QueryInfo{
+					{QueryId: sp("s1"), QueryString: sp(markQuery("a", "m"))},
+					{QueryId: sp("s2"), QueryString: sp(markQuery("b", "other"))},
+				},
+			}, nil).
+			Once()
+		actions.
+			On("DescribeQueriesWithContext", anyContext, &cloudwatchlogs.DescribeQueriesInput{
+				Status: sp(cloudwatchlogs.QueryStatusRunning),
+			}).
+			Return(&cloudwatchlogs.DescribeQueriesOutput{
+				Queries: []*cloudwatchlogs.QueryInfo{
+					{QueryId: sp("r1"), QueryString: sp("c")},
+				},
+				NextToken: sp("next"),
+			}, nil).
+			Once()
+		actions.
+			On("DescribeQueriesWithContext", anyContext, &cloudwatchlogs.DescribeQueriesInput{
+				Status:    sp(cloudwatchlogs.QueryStatusRunning),
+				NextToken: sp("next"),
+			}).
+			Return(&cloudwatchlogs.DescribeQueriesOutput{
+				Queries: []*cloudwatchlogs.QueryInfo{
+					{QueryId: sp("r2"), QueryString: sp(markQuery("d", "m")), CreateTime: int64p(epochMillisecond(time.Now().Add(-time.Hour)))},
+					{QueryId: sp("r3"), QueryString: sp(markQuery("e", "m")), CreateTime: int64p(epochMillisecond(time.Now().Add(time.Hour)))},
+				},
+			}, nil).
+			Once()
+		actions.
+			On("StopQueryWithContext", anyContext, &cloudwatchlogs.StopQueryInput{QueryId: sp("s1")}).
+			Return(&cloudwatchlogs.StopQueryOutput{}, nil).
+			Once()
+		actions.
+			On("StopQueryWithContext", anyContext, &cloudwatchlogs.StopQueryInput{QueryId: sp("r2")}).
+			Return(&cloudwatchlogs.StopQueryOutput{}, nil).
+			Once()
+
+		stopped, err := StopOrphanedQueries(context.Background(), actions, "m")
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"s1", "r2"}, stopped)
+		actions.AssertExpectations(t)
+	})
+
+	t.Run("Stop Fails", func(t *testing.T) {
+		cause := errors.New("cannot stop")
+		actions := newMockActions(t)
+		actions.
+			On("DescribeQueriesWithContext", anyContext, &cloudwatchlogs.DescribeQueriesInput{
+				Status: sp(cloudwatchlogs.QueryStatusScheduled),
+			}).
+			Return(&cloudwatchlogs.DescribeQueriesOutput{
+				Queries: []*cloudwatchlogs.QueryInfo{
+					{QueryId: sp("s1"), QueryString: sp(markQuery("a", "m"))},
+				},
+			}, nil).
+			Once()
+		actions.
+			On("DescribeQueriesWithContext", anyContext, &cloudwatchlogs.DescribeQueriesInput{
+				Status: sp(cloudwatchlogs.QueryStatusRunning),
+			}).
+			Return(&cloudwatchlogs.DescribeQueriesOutput{}, nil).
+			Once()
+		actions.
+			On("StopQueryWithContext", anyContext, &cloudwatchlogs.StopQueryInput{QueryId: sp("s1")}).
+			Return(nil, cause).
+			Once()
+
+		stopped, err := StopOrphanedQueries(context.Background(), actions, "m")
+
+		assert.Empty(t, stopped)
+		assert.ErrorIs(t, err, cause)
+		actions.AssertExpectations(t)
+	})
+}
+
+func TestQueryManager_StopOrphans(t *testing.T) {
+	t.Run("Invalid Config", func(t *testing.T) {
+		assert.PanicsWithValue(t, badQueryMarkerMsg, func() {
+			NewQueryManager(Config{
+				Actions:     newMockActions(t),
+				QueryMarker: "a\rb",
+			})
+		})
+		assert.PanicsWithValue(t, stopOrphansWithoutMarkerMsg, func() {
+			NewQueryManager(Config{
+				Actions:     newMockActions(t),
+				StopOrphans: true,
+			})
+		})
+		assert.PanicsWithValue(t, noQueryDescriberMsg, func() {
+			NewQueryManager(Config{
+				Actions: struct {
+					CloudWatchLogsActions
+				}{newMockActions(t)},
+				QueryMarker: "m",
+				StopOrphans: true,
+			})
+		})
+	})
+
+	t.Run("Orphans Stopped on Startup", func(t *testing.T) {
+		actions := newMockActions(t)
+		actions.
+			On("DescribeQueriesWithContext", anyContext, &cloudwatchlogs.DescribeQueriesInput{
+				Status: sp(cloudwatchlogs.QueryStatusScheduled),
+			}).
+			Return(&cloudwatchlogs.DescribeQueriesOutput{}, nil).
+			Once()
+		actions.
+			On("DescribeQueriesWithContext", anyContext, &cloudwatchlogs.DescribeQueriesInput{
+				Status: sp(cloudwatchlogs.QueryStatusRunning),
+			}).
+			Return(&cloudwatchlogs.DescribeQueriesOutput{
+				Queries: []*cloudwatchlogs.QueryInfo{
+					{QueryId: sp("orphan-1"), QueryString: sp(markQuery("x", "m"))},
+					{QueryId: sp("orphan-2"), QueryString: sp(markQuery("y", "m"))},
+				},
+			}, nil).
+			Once()
+		stopped := make(chan string, 2)
+		for _, queryID := range []string{"orphan-1", "orphan-2"} {
+			queryID := queryID
+			actions.
+				On("StopQueryWithContext", anyContext, &cloudwatchlogs.StopQueryInput{QueryId: &queryID}).
+				Run(func(_ mock.Arguments) {
+					stopped <- queryID
+				}).
+				Return(&cloudwatchlogs.StopQueryOutput{}, nil).
+				Once()
+		}
+		m := NewQueryManager(Config{
+			Actions:     actions,
+			Parallel:    1,
+			RPS:         lotsOfRPS,
+			QueryMarker: "m",
+			StopOrphans: true,
+		})
+		t.Cleanup(func() {
+			_ = m.Close()
+		})
+
+		assert.Equal(t, "orphan-1", <-stopped)
+		assert.Equal(t, "orphan-2", <-stopped)
+		actions.AssertExpectations(t)
+	})
+
+	t.Run("Started Queries Are Marked", func(t *testing.T) {
+		queryID := "marked"
+		actions := newMockActions(t)
+		actions.
+			On("StartQueryWithContext", anyContext, startQueryInput(markQuery("fields @message", "m"), defaultStart, defaultEnd, DefaultLimit, "g")).
+			Return(&cloudwatchlogs.StartQueryOutput{QueryId: &queryID}, nil).
+			Once()
+		actions.
+			On("GetQueryResultsWithContext", anyContext, &cloudwatchlogs.GetQueryResultsInput{QueryId: &queryID}).
+			Return(&cloudwatchlogs.GetQueryResultsOutput{Status: sp(cloudwatchlogs.QueryStatusComplete)}, nil).
+			Once()
+		m := NewQueryManager(Config{
+			Actions:     actions,
+			RPS:         lotsOfRPS,
+			QueryMarker: "m",
+		})
+		t.Cleanup(func() {
+			_ = m.Close()
+		})
+
+		r, err := m.Query(QuerySpec{
+			Text:   "fields @message",
+			Groups: []string{"g"},
+			Start:  defaultStart,
+			End:    defaultEnd,
+		})
+		assert.NoError(t, err)
+		_, err = ReadAll(r)
+
+		assert.NoError(t, err)
+		actions.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,58 @@
+// Copyright 2022 The incite Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package incite
+
+import (
+	"fmt"
+	"time"
+)
+
+// A streamControl is a request from a stream's Pause or Resume method
+// to the mgr loop.
+type streamControl struct {
+	s      *stream
+	pause  bool          // True to stop running chunks of a paused stream, false to resume
+	paused time.Duration // Time the stream spent paused, if resuming
+}
+
+// controlStream handles a request to pause or resume a stream.
+func (m *mgr) controlStream(ctl streamControl) {
+	s := ctl.s
+	if ctl.pause {
+		m.stopPaused(s)
+		return
+	}
+	s.halted = false
+
+	m.logEvent("", fmt.Sprintf("query %q resumed after %s paused", s.Text, ctl.paused))
+	m.statsLock.Lock()
+	t := Stats{TimePaused: ctl.paused}
+	m.stats.add(&t)
+	m.addTenantStats(s.Tenant, &t)
+	m.statsLock.Unlock()
+
+	if s.sleeping {
+		s.sleeping = false
+		m.pushStream(s)
+	}
+	m.unpark(s)
+}
+
+// stopPaused asks the poller to give back the running chunks of a
+// paused stream, so that their Insights queries can be stopped and the
+// chunks restarted once the stream is resumed. Chunks still being
+// started are given back as soon as they reach the poller.
+func (m *mgr) stopPaused(s *stream) {
+	if !s.isPaused() {
+		return
+	}
+	s.halted = true
+	for c := range m.preemptible {
+		if c.stream == s {
+			m.logChunk(c, "stream paused, stopping", "")
+			m.preemptChunk(c)
+		}
+	}
+}
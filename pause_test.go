@@ -0,0 +1,323 @@
+// Copyright 2022 The incite Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package incite
+
+import (
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStream_PauseResume(t *testing.T) {
+	t.Run("Pause and Resume", func(t *testing.T) {
+		s := &stream{}
+
+		require.NoError(t, s.Pause(false))
+		paused := s.paused
+		require.NoError(t, s.Pause(true))
+
+		assert.True(t, s.isPaused())
+		assert.Equal(t, paused, s.paused, "second pause must have no effect")
+		time.Sleep(10 * time.Millisecond)
+		assert.GreaterOrEqual(t, s.GetStats().TimePaused, 10*time.Millisecond, "ongoing pause must be counted")
+
+		require.NoError(t, s.Resume())
+
+		assert.False(t, s.isPaused())
+		assert.GreaterOrEqual(t, s.stats.TimePaused, 10*time.Millisecond)
+		assert.NoError(t, s.Resume())
+	})
+
+	t.Run("Timeout Stopped While Paused", func(t *testing.T) {
+		expired := make(chan struct{})
+		s := &stream{
+			deadline: time.Now().Add(30 * time.Millisecond),
+			expiry: time.AfterFunc(30*time.Millisecond, func() {
+				close(expired)
+			}),
+		}
+
+		require.NoError(t, s.Pause(false))
+		time.Sleep(60 * time.Millisecond)
+
+		select {
+		case <-expired:
+			t.Fatal("stream timeout passed while paused")
+		default:
+		}
+		left := s.timeLeft
+		assert.Greater(t, left, time.Duration(0))
+		assert.LessOrEqual(t, left, 30*time.Millisecond)
+
+		before := time.Now()
+		require.NoError(t, s.Resume())
+
+		select {
+		case <-expired:
+			assert.GreaterOrEqual(t, time.Since(before), left)
+		case <-time.After(time.Second):
+			t.Fatal("stream timeout did not pass after resume")
+		}
+		assert.Equal(t, time.Duration(0), s.timeLeft)
+	})
+
+	t.Run("Closed", func(t *testing.T) {
+		s := &stream{err: ErrClosed}
+
+		assert.Same(t, ErrClosed, s.Pause(false))
+		assert.Same(t, ErrClosed, s.Resume())
+	})
+
+	t.Run("Finished", func(t *testing.T) {
+		s := &stream{err: io.EOF}
+
+		assert.NoError(t, s.Pause(false))
+		assert.False(t, s.isPaused())
+	})
+}
+
+func TestMgr_getReadyChunk_Paused(t *testing.T) {
+	m := &mgr{Config: Config{Logger: NopLogger}}
+	paused := newTenantStream("", 10)
+	require.NoError(t, paused.Pause(false))
+	other := newTenantStream("", 1)
+	m.pushStream(paused)
+	m.pushStream(other)
+
+	t.Run("Paused Stream Is Skipped", func(t *testing.T) {
+		c := m.getReadyChunk()
+
+		require.NotNil(t, c)
+		assert.Same(t, other, c.stream)
+		assert.Nil(t, m.getReadyChunk())
+		assert.True(t, paused.sleeping)
+	})
+
+	t.Run("Ready Chunk Is Held", func(t *testing.T) {
+		restarted := &chunk{stream: paused, chunkID: "0R"}
+		m.makeReady(restarted)
+
+		assert.Nil(t, m.getReadyChunk())
+		assert.Equal(t, []*chunk{restarted}, paused.held)
+	})
+
+	t.Run("Resumed Stream Is Scheduled", func(t *testing.T) {
+		require.NoError(t, paused.Resume())
+		m.controlStream(streamControl{s: paused, paused: time.Minute})
+
+		assert.False(t, paused.sleeping)
+		assert.Nil(t, paused.held)
+		c := m.getReadyChunk()
+		require.NotNil(t, c)
+		assert.Equal(t, "0R", c.chunkID, "held chunk must go first")
+		c = m.getReadyChunk()
+		require.NotNil(t, c)
+		assert.Same(t, paused, c.stream)
+		assert.Equal(t, time.Minute, m.GetStats().TimePaused)
+		assert.Equal(t, time.Minute, m.GetTenantStats("").TimePaused)
+	})
+}
+
+func TestMgr_stopPaused(t *testing.T) {
+	m := &mgr{
+		Config: Config{Logger: NopLogger},
+		stop:   make(chan *chunk, 1),
+	}
+	paused := newTenantStream("", 1)
+	other := newTenantStream("", 1)
+	c := m.runPreemptible(paused, time.Second)
+	d := m.runPreemptible(other, time.Second)
+	require.NoError(t, paused.Pause(true))
+
+	m.controlStream(streamControl{s: paused, pause: true})
+
+	assert.Equal(t, int32(1), c.preempt)
+	assert.Equal(t, int32(0), d.preempt)
+	assert.Equal(t, 1, m.preempting)
+
+	t.Run("Started After Pause", func(t *testing.T) {
+		e := m.runPreemptible(paused, 0)
+
+		assert.Equal(t, int32(1), e.preempt)
+		assert.Equal(t, 2, m.preempting)
+	})
+
+	t.Run("Requeued Until Resumed", func(t *testing.T) {
+		m.release(c)
+		c.err = errPreemptChunk
+
+		m.handlePollingError(c)
+
+		assert.Len(t, m.stop, 1)
+		assert.Equal(t, time.Duration(0), c.RangePreempted, "pause is not preemption")
+		assert.Equal(t, []*chunk{c}, paused.preempted)
+		assert.Nil(t, m.getReadyChunk())
+		assert.True(t, paused.sleeping)
+	})
+}
+
+func TestQueryManager_PauseResume(t *testing.T) {
+	text := "query which is paused"
+	actions := newMockActions(t)
+	var started, stopped sync.WaitGroup
+	started.Add(1)
+	stopped.Add(1)
+	actions.
+		On("StartQueryWithContext", anyContext, startQueryInput(text, defaultStart, defaultEnd, DefaultLimit, "grp")).
+		Run(func(_ mock.Arguments) { started.Done() }).
+		Return(&cloudwatchlogs.StartQueryOutput{QueryId: sp("before")}, nil).
+		Once()
+	actions.
+		On("GetQueryResultsWithContext", anyContext, &cloudwatchlogs.GetQueryResultsInput{QueryId: sp("before")}).
+		Return(&cloudwatchlogs.GetQueryResultsOutput{Status: sp(cloudwatchlogs.QueryStatusRunning)}, nil).
+		Maybe()
+	actions.
+		On("StopQueryWithContext", anyContext, &cloudwatchlogs.StopQueryInput{QueryId: sp("before")}).
+		Run(func(_ mock.Arguments) { stopped.Done() }).
+		Return(&cloudwatchlogs.StopQueryOutput{}, nil).
+		Once()
+	actions.
+		On("StartQueryWithContext", anyContext, startQueryInput(text, defaultStart, defaultEnd, DefaultLimit, "grp")).
+		Return(&cloudwatchlogs.StartQueryOutput{QueryId: sp("after")}, nil).
+		Once()
+	actions.
+		On("GetQueryResultsWithContext", anyContext, &cloudwatchlogs.GetQueryResultsInput{QueryId: sp("after")}).
+		Return(&cloudwatchlogs.GetQueryResultsOutput{
+			Results: [][]*cloudwatchlogs.ResultField{
+				{{Field: sp("@message"), Value: sp("resumed")}},
+			},
+			Status: sp(cloudwatchlogs.QueryStatusComplete),
+		}, nil).
+		Once()
+	m := NewQueryManager(Config{
+		Actions: actions,
+		RPS:     lotsOfRPS,
+	})
+	t.Cleanup(func() {
+		_ = m.Close()
+	})
+	s, err := m.Query(QuerySpec{
+		Text:   text,
+		Groups: []string{"grp"},
+		Start:  defaultStart,
+		End:    defaultEnd,
+	})
+	require.NoError(t, err)
+
+	started.Wait()
+	require.NoError(t, s.(Pauser).Pause(true))
+	stopped.Wait()
+	require.NoError(t, s.(Pauser).Resume())
+	r, err := ReadAll(s)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []Result{{{"@message", "resumed"}}}, r)
+	assert.Greater(t, s.GetStats().TimePaused, time.Duration(0))
+	assert.Equal(t, time.Duration(0), s.GetStats().RangePreempted)
+	assert.Equal(t, defaultEnd.Sub(defaultStart), s.GetStats().RangeStarted)
+	assert.Equal(t, defaultEnd.Sub(defaultStart), m.GetStats().RangeStarted)
+	actions.AssertExpectations(t)
+}
+
+func TestQueryManager_Close_Paused(t *testing.T) {
+	text := "query which is paused when closed"
+	first := startQueryInput(text, defaultStart, defaultStart.Add(time.Minute), DefaultLimit, "grp")
+
+	t.Run("Running Chunk Finishes", func(t *testing.T) {
+		actions := newMockActions(t)
+		started, paused := make(chan struct{}), make(chan struct{})
+		actions.
+			On("StartQueryWithContext", anyContext, first).
+			Run(func(_ mock.Arguments) {
+				close(started)
+				<-paused
+			}).
+			Return(&cloudwatchlogs.StartQueryOutput{QueryId: sp("first")}, nil).
+			Once()
+		actions.
+			On("GetQueryResultsWithContext", anyContext, &cloudwatchlogs.GetQueryResultsInput{QueryId: sp("first")}).
+			Return(&cloudwatchlogs.GetQueryResultsOutput{
+				Results: [][]*cloudwatchlogs.ResultField{
+					{{Field: sp("@message"), Value: sp("first")}},
+				},
+				Status: sp(cloudwatchlogs.QueryStatusComplete),
+			}, nil).
+			Once()
+		m := NewQueryManager(Config{
+			Actions:  actions,
+			Parallel: 1,
+			RPS:      lotsOfRPS,
+		})
+		s, err := m.Query(QuerySpec{
+			Text:   text,
+			Groups: []string{"grp"},
+			Start:  defaultStart,
+			End:    defaultEnd,
+			Chunk:  time.Minute,
+		})
+		require.NoError(t, err)
+		<-started
+		require.NoError(t, s.(Pauser).Pause(false))
+		close(paused)
+		r := make([]Result, 1)
+		n, err := s.Read(r)
+		require.NoError(t, err)
+		require.Equal(t, 1, n)
+
+		require.NoError(t, m.Close())
+		_, err = ReadAll(s)
+
+		assert.Same(t, ErrClosed, err)
+		actions.AssertExpectations(t)
+	})
+
+	t.Run("Running Chunk Stopped", func(t *testing.T) {
+		actions := newMockActions(t)
+		started := make(chan struct{})
+		actions.
+			On("StartQueryWithContext", anyContext, first).
+			Run(func(_ mock.Arguments) { close(started) }).
+			Return(&cloudwatchlogs.StartQueryOutput{QueryId: sp("first")}, nil).
+			Once()
+		actions.
+			On("GetQueryResultsWithContext", anyContext, &cloudwatchlogs.GetQueryResultsInput{QueryId: sp("first")}).
+			Return(&cloudwatchlogs.GetQueryResultsOutput{Status: sp(cloudwatchlogs.QueryStatusRunning)}, nil).
+			Maybe()
+		stopped := make(chan struct{})
+		actions.
+			On("StopQueryWithContext", anyContext, &cloudwatchlogs.StopQueryInput{QueryId: sp("first")}).
+			Run(func(_ mock.Arguments) { close(stopped) }).
+			Return(&cloudwatchlogs.StopQueryOutput{}, nil).
+			Once()
+		m := NewQueryManager(Config{
+			Actions:  actions,
+			Parallel: 1,
+			RPS:      lotsOfRPS,
+		})
+		s, err := m.Query(QuerySpec{
+			Text:   text,
+			Groups: []string{"grp"},
+			Start:  defaultStart,
+			End:    defaultEnd,
+			Chunk:  time.Minute,
+		})
+		require.NoError(t, err)
+		<-started
+		require.NoError(t, s.(Pauser).Pause(true))
+		<-stopped
+
+		require.NoError(t, m.Close())
+		_, err = ReadAll(s)
+
+		assert.Same(t, ErrClosed, err)
+		actions.AssertExpectations(t)
+	})
+}
@@ -6,6 +6,7 @@ package incite
 
 import (
 	"context"
+	"sync/atomic"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws/request"
@@ -21,12 +22,13 @@ const maxTempPollingErrs = 10
 func newPoller(m *mgr) *poller {
 	p := &poller{
 		worker: worker{
-			m:                 m,
-			regulator:         makeRegulator(m.close, m.RPS[GetQueryResults], RPSDefaults[GetQueryResults]),
-			in:                m.poll,
-			out:               m.update,
-			name:              "poller",
-			maxTemporaryError: maxTempPollingErrs,
+			m:           m,
+			regulator:   makeBurstRegulator(m.close, m.RPS[GetQueryResults], m.defaultRPS(GetQueryResults), m.Burst[GetQueryResults], rpsQuota(m.RPSQuota, GetQueryResults)),
+			in:          m.poll,
+			out:         m.update,
+			name:        "poller",
+			action:      GetQueryResults,
+			maxInFlight: m.MaxInFlight,
 		},
 	}
 	p.manipulator = p
@@ -42,6 +44,45 @@ func (p *poller) context(c *chunk) context.Context {
 // restarted before it is considered permanently failed.
 const maxRestart = 2
 
+const (
+	// pollAgeFactor controls how quickly the poll interval of a running
+	// chunk grows with the age of the chunk: a chunk is polled at most
+	// pollAgeFactor times during each period equal to its age.
+	pollAgeFactor = 10
+
+	// maxPollInterval is the longest time a chunk may go unpolled.
+	maxPollInterval = 5 * time.Second
+)
+
+// pollInterval returns how long the poller should wait before polling
+// again a chunk whose Insights query has the given age and status.
+//
+// Young queries are polled often, because short queries are common
+// and may finish at any moment. As a query ages it becomes less likely
+// to finish in the next instant, so the interval grows in proportion
+// to its age, up to maxPollInterval. Queries which CloudWatch Logs has
+// not yet begun running are polled half as often as running ones.
+func pollInterval(age time.Duration, status string) time.Duration {
+	d := age / pollAgeFactor
+	if status != cloudwatchlogs.QueryStatusRunning {
+		d *= 2
+	}
+	if d > maxPollInterval {
+		d = maxPollInterval
+	}
+	return d
+}
+
+// nextPoll returns the time at which a running chunk should next be
+// polled, which is never later than the chunk's deadline.
+func nextPoll(c *chunk, status string) time.Time {
+	due := time.Now().Add(pollInterval(time.Since(c.since), status))
+	if !c.expiry.IsZero() && c.expiry.Before(due) {
+		return c.expiry
+	}
+	return due
+}
+
 func (p *poller) manipulate(c *chunk) outcome {
 	// If the owning stream has died, send chunk back for cancellation.
 	if !c.stream.alive() {
@@ -49,16 +90,29 @@ func (p *poller) manipulate(c *chunk) outcome {
 		return finished
 	}
 
+	// If the chunk ran past its deadline, send it back to be stopped.
+	if !c.expiry.IsZero() && !time.Now().Before(c.expiry) {
+		c.err = errChunkDeadline
+		return finished
+	}
+
+	// If the chunk was preempted, send it back to be stopped and
+	// restarted later.
+	if atomic.LoadInt32(&c.preempt) != 0 {
+		c.err = errPreemptChunk
+		return finished
+	}
+
 	// Poll the chunk.
 	input := cloudwatchlogs.GetQueryResultsInput{
 		QueryId: &c.queryID,
 	}
 	output, err := p.m.Actions.GetQueryResultsWithContext(c.ctx, &input, request.WithAppendUserAgent(version()))
-	p.lastReq = time.Now()
+	p.m.breaker.record(false, err)
 
 	if err != nil {
 		c.err = &UnexpectedQueryError{c.queryID, c.stream.Text, err}
-		if isTemporary(err) {
+		if p.retry(c, err) {
 			p.m.logChunk(c, "temporary failure to poll", err.Error())
 			return temporaryError
 		}
@@ -74,16 +128,22 @@ func (p *poller) manipulate(c *chunk) outcome {
 	switch status {
 	case cloudwatchlogs.QueryStatusScheduled, "Unknown":
 		c.err = nil
+		c.due = nextPoll(c, status)
 		return inconclusive
 	case cloudwatchlogs.QueryStatusRunning:
 		c.err = nil
+		c.due = nextPoll(c, status)
 		if c.ptr == nil {
 			return inconclusive // Ignore non-previewable results.
 		}
+		n := len(c.ptr)
 		if !sendChunkBlock(c, output.Results) {
 			translateStats(output.Statistics, &c.Stats)
 			return finished
 		}
+		if len(c.ptr) > n {
+			c.due = time.Time{} // Re-poll immediately while the chunk is producing data.
+		}
 		return inconclusive
 	case cloudwatchlogs.QueryStatusComplete:
 		translateStats(output.Statistics, &c.Stats)
@@ -92,13 +152,16 @@ func (p *poller) manipulate(c *chunk) outcome {
 			return finished
 		}
 		c.err = nil
+		if c.stream.Hydrate && len(output.Results) > 0 {
+			return deferChunkBlock(c, output.Results)
+		}
 		c.Stats.RangeDone += c.duration()
 		if sendChunkBlock(c, output.Results) {
 			c.state = complete
 		}
 		return finished
 	case cloudwatchlogs.QueryStatusFailed:
-		if c.ptr == nil && c.restart < maxRestart {
+		if c.ptr == nil && c.stream.queryID == "" && p.restartable(c) {
 			translateStats(output.Statistics, &c.Stats)
 			c.restart++
 			c.err = errRestartChunk
@@ -107,11 +170,24 @@ func (p *poller) manipulate(c *chunk) outcome {
 		fallthrough
 	default:
 		translateStats(output.Statistics, &c.Stats)
+		if p.splittableStatus(c, status) {
+			p.m.logChunk(c, "will split chunk with terminal status", status)
+			c.err = errSplitChunk
+			return finished
+		}
 		c.err = &TerminalQueryStatusError{c.queryID, status, c.stream.Text}
 		return finished
 	}
 }
 
+// restartable consults the RetryPolicy about whether a chunk whose
+// query went into the "Failed" state should be restarted.
+func (p *poller) restartable(c *chunk) bool {
+	err := &TerminalQueryStatusError{c.queryID, cloudwatchlogs.QueryStatusFailed, c.stream.Text}
+	ok, _ := p.m.retryPolicy().Retry(StartQuery, err, c.restart+1)
+	return ok
+}
+
 func (p *poller) release(c *chunk) {
 	p.m.logChunk(c, "releasing pollable", "")
 }
@@ -131,16 +207,35 @@ func sendChunkBlock(c *chunk, results [][]*cloudwatchlogs.ResultField) bool {
 		return false
 	}
 
+	sendBlock(c.stream, block)
+	return true
+}
+
+// deferChunkBlock translates the final results of a completed chunk and
+// keeps them in the chunk, rather than sending them to the stream, so
+// they can be hydrated before they are sent.
+func deferChunkBlock(c *chunk, results [][]*cloudwatchlogs.ResultField) outcome {
+	block, err := translateResultsNoPreview(c, results)
+	if err != nil {
+		c.err = err
+		return finished
+	}
+
+	c.results = block
+	c.state = complete
+	return finished
+}
+
+func sendBlock(s *stream, block []Result) {
 	if len(block) == 0 {
-		return true
+		return
 	}
 
-	c.stream.lock.Lock()
-	defer c.stream.lock.Unlock()
+	s.lock.Lock()
+	defer s.lock.Unlock()
 
-	c.stream.blocks = append(c.stream.blocks, block)
-	c.stream.more.Signal()
-	return true
+	s.blocks = append(s.blocks, block)
+	s.more.Signal()
 }
 
 func translateStats(in *cloudwatchlogs.QueryStatistics, out *Stats) {
@@ -261,6 +356,18 @@ func deleteResult(ptr string) Result {
 // to facilitate unit testing.
 var maxLimit int64 = MaxLimit
 
+// splittableStatus returns true if a chunk whose query ended in the
+// given terminal status should be split into sub-chunks.
+func (p *poller) splittableStatus(c *chunk, status string) bool {
+	switch {
+	case status == queryStatusTimeout && c.stream.SplitOnTimeout:
+	case status == cloudwatchlogs.QueryStatusFailed && c.stream.SplitOnFailed:
+	default:
+		return false
+	}
+	return c.ptr == nil && c.stream.queryID == "" && c.duration() > c.stream.SplitUntil
+}
+
 func (p *poller) splittable(c *chunk, n int) bool {
 	// Short circuit if the chunk isn't maxed out.
 	if int64(n) < c.stream.Limit {
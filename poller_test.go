@@ -30,7 +30,7 @@ func TestNewPoller(t *testing.T) {
 	var out chan<- *chunk = p.m.update
 	assert.Equal(t, out, p.out)
 	assert.Equal(t, "poller", p.name)
-	assert.Equal(t, 10, p.maxTemporaryError)
+	assert.Equal(t, GetQueryResults, p.action)
 	a.AssertExpectations(t)
 	l.AssertExpectations(t)
 }
@@ -170,7 +170,7 @@ func TestPoller_manipulate(t *testing.T) {
 					},
 					Status: sp(cloudwatchlogs.QueryStatusRunning),
 				},
-				expectedStats: Stats{6, 5, 4, 0, 0, 0, 0, 0},
+				expectedStats: Stats{BytesScanned: 6, RecordsMatched: 5, RecordsScanned: 4},
 				expectedChunkErr: &UnexpectedQueryError{
 					QueryID: queryID,
 					Text:    text,
@@ -280,7 +280,7 @@ func TestPoller_manipulate(t *testing.T) {
 					},
 					Status: sp(cloudwatchlogs.QueryStatusComplete),
 				},
-				expectedStats:    Stats{50, 55, 60, 0, 0, 0, 0, 0},
+				expectedStats:    Stats{BytesScanned: 50, RecordsMatched: 55, RecordsScanned: 60},
 				expectedChunkErr: errSplitChunk,
 			},
 			{
@@ -294,7 +294,7 @@ func TestPoller_manipulate(t *testing.T) {
 					},
 					Status: sp(cloudwatchlogs.QueryStatusFailed),
 				},
-				expectedStats: Stats{70, 0, 0, 0, 0, 0, 0, 0},
+				expectedStats: Stats{BytesScanned: 70},
 				expectedChunkErr: &TerminalQueryStatusError{
 					QueryID: queryID,
 					Status:  cloudwatchlogs.QueryStatusFailed,
@@ -309,7 +309,7 @@ func TestPoller_manipulate(t *testing.T) {
 					},
 					Status: sp(cloudwatchlogs.QueryStatusFailed),
 				},
-				expectedStats:    Stats{0, 85, 0, 0, 0, 0, 0, 0},
+				expectedStats:    Stats{RecordsMatched: 85},
 				expectedChunkErr: errRestartChunk,
 				expectedRestart:  1,
 			},
@@ -324,7 +324,7 @@ func TestPoller_manipulate(t *testing.T) {
 					},
 					Status: sp(cloudwatchlogs.QueryStatusFailed),
 				},
-				expectedStats: Stats{0, 0, 90, 0, 0, 0, 0, 0},
+				expectedStats: Stats{RecordsScanned: 90},
 				expectedChunkErr: &TerminalQueryStatusError{
 					QueryID: queryID,
 					Status:  cloudwatchlogs.QueryStatusFailed,
@@ -332,6 +332,68 @@ func TestPoller_manipulate(t *testing.T) {
 				},
 				expectedRestart: maxRestart,
 			},
+			{
+				name: "Status Failed, Non-Preview, Non-Restartable, SplitOnFailed",
+				setup: func(t *testing.T, logger *mockLogger, c *chunk) {
+					c.restart = maxRestart
+					c.stream.SplitOnFailed = true
+					c.stream.SplitUntil = end.Sub(start) / 2
+					logger.ExpectPrintf("incite: QueryManager(%s) %s chunk %s %q [%s..%s): %s", t.Name(),
+						"will split chunk with terminal status", logID, text, start, end, cloudwatchlogs.QueryStatusFailed)
+				},
+				output: &cloudwatchlogs.GetQueryResultsOutput{
+					Statistics: &cloudwatchlogs.QueryStatistics{
+						RecordsScanned: float64p(95),
+					},
+					Status: sp(cloudwatchlogs.QueryStatusFailed),
+				},
+				expectedStats:    Stats{RecordsScanned: 95},
+				expectedChunkErr: errSplitChunk,
+				expectedRestart:  maxRestart,
+			},
+			{
+				name: "Status Timeout",
+				output: &cloudwatchlogs.GetQueryResultsOutput{
+					Status: sp("Timeout"),
+				},
+				expectedChunkErr: &TerminalQueryStatusError{
+					QueryID: queryID,
+					Status:  "Timeout",
+					Text:    text,
+				},
+			},
+			{
+				name: "Status Timeout, SplitOnTimeout",
+				setup: func(t *testing.T, logger *mockLogger, c *chunk) {
+					c.stream.SplitOnTimeout = true
+					c.stream.SplitUntil = end.Sub(start) / 2
+					logger.ExpectPrintf("incite: QueryManager(%s) %s chunk %s %q [%s..%s): %s", t.Name(),
+						"will split chunk with terminal status", logID, text, start, end, "Timeout")
+				},
+				output: &cloudwatchlogs.GetQueryResultsOutput{
+					Statistics: &cloudwatchlogs.QueryStatistics{
+						BytesScanned: float64p(99),
+					},
+					Status: sp("Timeout"),
+				},
+				expectedStats:    Stats{BytesScanned: 99},
+				expectedChunkErr: errSplitChunk,
+			},
+			{
+				name: "Status Timeout, SplitOnTimeout, Too Small",
+				setup: func(_ *testing.T, _ *mockLogger, c *chunk) {
+					c.stream.SplitOnTimeout = true
+					c.stream.SplitUntil = end.Sub(start)
+				},
+				output: &cloudwatchlogs.GetQueryResultsOutput{
+					Status: sp("Timeout"),
+				},
+				expectedChunkErr: &TerminalQueryStatusError{
+					QueryID: queryID,
+					Status:  "Timeout",
+					Text:    text,
+				},
+			},
 			{
 				name: "Status Cancelled",
 				output: &cloudwatchlogs.GetQueryResultsOutput{
@@ -342,7 +404,7 @@ func TestPoller_manipulate(t *testing.T) {
 					},
 					Status: sp(cloudwatchlogs.QueryStatusCancelled),
 				},
-				expectedStats: Stats{222, 221, 223, 0, 0, 0, 0, 0},
+				expectedStats: Stats{BytesScanned: 222, RecordsMatched: 221, RecordsScanned: 223},
 				expectedChunkErr: &TerminalQueryStatusError{
 					QueryID: queryID,
 					Status:  cloudwatchlogs.QueryStatusCancelled,
@@ -359,7 +421,7 @@ func TestPoller_manipulate(t *testing.T) {
 					},
 					Status: sp("Fake Status"),
 				},
-				expectedStats: Stats{375, 380, 385, 0, 0, 0, 0, 0},
+				expectedStats: Stats{BytesScanned: 375, RecordsMatched: 380, RecordsScanned: 385},
 				expectedChunkErr: &TerminalQueryStatusError{
 					QueryID: queryID,
 					Status:  "Fake Status",
@@ -398,13 +460,9 @@ func TestPoller_manipulate(t *testing.T) {
 					testCase.setup(t, logger, c)
 				}
 
-				before := time.Now()
 				actualResult := p.manipulate(c)
-				after := time.Now()
 
 				assert.Equal(t, testCase.expectedOutcome, actualResult)
-				assert.GreaterOrEqual(t, p.lastReq.Sub(before), time.Duration(0))
-				assert.GreaterOrEqual(t, after.Sub(p.lastReq), time.Duration(0))
 				assert.Equal(t, testCase.expectedStats, c.Stats)
 				assert.Equal(t, testCase.expectedChunkErr, c.err)
 				assert.Equal(t, testCase.expectedChunkState, c.state)
@@ -416,6 +474,151 @@ func TestPoller_manipulate(t *testing.T) {
 	})
 }
 
+func TestPoller_manipulate_Due(t *testing.T) {
+	queryID := "due"
+	testCases := []struct {
+		name    string
+		age     time.Duration
+		preview bool
+		output  *cloudwatchlogs.GetQueryResultsOutput
+		min     time.Duration
+		max     time.Duration
+	}{
+		{
+			name:   "Young Running",
+			age:    time.Second,
+			output: &cloudwatchlogs.GetQueryResultsOutput{Status: sp(cloudwatchlogs.QueryStatusRunning)},
+			min:    90 * time.Millisecond,
+			max:    110 * time.Millisecond,
+		},
+		{
+			name:   "Young Scheduled",
+			age:    time.Second,
+			output: &cloudwatchlogs.GetQueryResultsOutput{Status: sp(cloudwatchlogs.QueryStatusScheduled)},
+			min:    190 * time.Millisecond,
+			max:    210 * time.Millisecond,
+		},
+		{
+			name:   "Old Running",
+			age:    time.Hour,
+			output: &cloudwatchlogs.GetQueryResultsOutput{Status: sp(cloudwatchlogs.QueryStatusRunning)},
+			min:    maxPollInterval - 10*time.Millisecond,
+			max:    maxPollInterval,
+		},
+		{
+			name:    "Previewing Without New Data",
+			age:     time.Second,
+			preview: true,
+			output:  &cloudwatchlogs.GetQueryResultsOutput{Status: sp(cloudwatchlogs.QueryStatusRunning)},
+			min:     90 * time.Millisecond,
+			max:     110 * time.Millisecond,
+		},
+		{
+			name:    "Previewing With New Data",
+			age:     time.Second,
+			preview: true,
+			output: &cloudwatchlogs.GetQueryResultsOutput{
+				Status:  sp(cloudwatchlogs.QueryStatusRunning),
+				Results: backOut([]Result{{{"@ptr", "1"}}}),
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			p, actions, logger := newTestablePoller(t, 10_000_000)
+			actions.
+				On("GetQueryResultsWithContext", anyContext, &cloudwatchlogs.GetQueryResultsInput{QueryId: &queryID}).
+				Return(testCase.output, nil).
+				Once()
+			c := &chunk{
+				stream:  &stream{},
+				ctx:     context.Background(),
+				queryID: queryID,
+				since:   time.Now().Add(-testCase.age),
+			}
+			c.stream.more = sync.NewCond(&c.stream.lock)
+			if testCase.preview {
+				c.ptr = make(map[string]bool)
+			}
+
+			now := time.Now()
+			o := p.manipulate(c)
+
+			assert.Equal(t, inconclusive, o)
+			if testCase.max == 0 {
+				assert.True(t, c.due.IsZero(), "chunk producing data must be re-polled immediately")
+			} else {
+				assert.GreaterOrEqual(t, c.due.Sub(now), testCase.min)
+				assert.LessOrEqual(t, c.due.Sub(now), testCase.max+10*time.Millisecond)
+			}
+			actions.AssertExpectations(t)
+			logger.AssertExpectations(t)
+		})
+	}
+}
+
+func TestPoller_manipulate_Expired(t *testing.T) {
+	p, actions, logger := newTestablePoller(t, 10_000_000)
+	c := &chunk{
+		stream: &stream{},
+		expiry: time.Now().Add(-time.Millisecond),
+	}
+	c.stream.more = sync.NewCond(&c.stream.lock)
+
+	o := p.manipulate(c)
+
+	assert.Equal(t, finished, o)
+	assert.Same(t, errChunkDeadline, c.err)
+	actions.AssertExpectations(t)
+	logger.AssertExpectations(t)
+}
+
+func TestPoller_manipulate_Preempted(t *testing.T) {
+	p, actions, logger := newTestablePoller(t, 10_000_000)
+	c := &chunk{
+		stream:  &stream{},
+		preempt: 1,
+	}
+	c.stream.more = sync.NewCond(&c.stream.lock)
+
+	o := p.manipulate(c)
+
+	assert.Equal(t, finished, o)
+	assert.Same(t, errPreemptChunk, c.err)
+	actions.AssertExpectations(t)
+	logger.AssertExpectations(t)
+}
+
+func TestNextPoll(t *testing.T) {
+	t.Run("No Expiry", func(t *testing.T) {
+		c := &chunk{since: time.Now().Add(-time.Hour)}
+
+		due := nextPoll(c, cloudwatchlogs.QueryStatusRunning)
+
+		assert.WithinDuration(t, time.Now().Add(maxPollInterval), due, time.Second)
+	})
+
+	t.Run("Capped at Expiry", func(t *testing.T) {
+		expiry := time.Now().Add(time.Second)
+		c := &chunk{since: time.Now().Add(-time.Hour), expiry: expiry}
+
+		due := nextPoll(c, cloudwatchlogs.QueryStatusRunning)
+
+		assert.Equal(t, expiry, due)
+	})
+}
+
+func TestPollInterval(t *testing.T) {
+	assert.Equal(t, time.Duration(0), pollInterval(0, cloudwatchlogs.QueryStatusRunning))
+	assert.Equal(t, 100*time.Millisecond, pollInterval(time.Second, cloudwatchlogs.QueryStatusRunning))
+	assert.Equal(t, 200*time.Millisecond, pollInterval(time.Second, cloudwatchlogs.QueryStatusScheduled))
+	assert.Equal(t, 200*time.Millisecond, pollInterval(time.Second, "Unknown"))
+	assert.Equal(t, 3*time.Second, pollInterval(30*time.Second, cloudwatchlogs.QueryStatusRunning))
+	assert.Equal(t, maxPollInterval, pollInterval(time.Minute, cloudwatchlogs.QueryStatusRunning))
+	assert.Equal(t, maxPollInterval, pollInterval(time.Minute, cloudwatchlogs.QueryStatusScheduled))
+}
+
 func TestPoller_release(t *testing.T) {
 	p, actions, logger := newTestablePoller(t, 5_000_000)
 	logger.ExpectPrintf("incite: QueryManager(%s) %s chunk %s %q [%s..%s)", t.Name(),
@@ -0,0 +1,158 @@
+// Copyright 2022 The incite Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package incite
+
+import (
+	"strconv"
+	"sync/atomic"
+)
+
+// nextStream returns the stream whose chunk the mgr loop would start
+// next if the running chunk c gave up its Parallel slot, or nil if no
+// chunk could start, for example because the waiting streams are all
+// paused or capped by MaxParallel. Unlike getReadyChunk, nextStream
+// does not change any scheduling state.
+func (m *mgr) nextStream(c *chunk) *stream {
+	t := m.tenantOf(c.stream)
+	t.running--
+	c.stream.active--
+	defer func() {
+		t.running++
+		c.stream.active++
+	}()
+
+	var tried map[*tenant]bool
+	for {
+		var next *tenant
+		for _, u := range m.tenants {
+			if u.waiting() && !tried[u] && (next == nil || u.before(next)) {
+				next = u
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		if s := peekTenantStream(next); s != nil {
+			return s
+		}
+		if tried == nil {
+			tried = make(map[*tenant]bool)
+		}
+		tried[next] = true
+	}
+}
+
+// peekTenantStream returns the stream whose chunk getTenantChunk would
+// return next for a tenant, or nil if it would return no chunk, without
+// changing the tenant's ready ring or stream heap.
+func peekTenantStream(t *tenant) *stream {
+	if t.numReady > 0 {
+		for r := t.ready.Next(); r != &t.ready; r = r.Next() {
+			if s := r.Value.(*chunk).stream; !s.capped() && !s.isPaused() {
+				return s
+			}
+		}
+	}
+	next := -1
+	for i, s := range t.pq {
+		if s.alive() && !s.isPaused() && !s.capped() && (next < 0 || t.pq.Less(i, next)) {
+			next = i
+		}
+	}
+	if next < 0 {
+		return nil
+	}
+	return t.pq[next]
+}
+
+// addPreemptible records a chunk just handed off to the poller as a
+// candidate for preemption, either by a more urgent stream or by the
+// chunk's stream being paused. Chunks of previewable queries are never
+// preempted because their results have already been partly delivered.
+func (m *mgr) addPreemptible(c *chunk) {
+	if c.ptr != nil {
+		return
+	}
+	if m.preemptible == nil {
+		m.preemptible = make(map[*chunk]bool)
+	}
+	m.preemptible[c] = true
+	if c.stream.halted && c.stream.isPaused() {
+		m.logChunk(c, "stream paused, stopping", "")
+		m.preemptChunk(c)
+	}
+}
+
+// removePreemptible stops considering a chunk returned by a worker for
+// preemption, and counts it as no longer being preempted.
+func (m *mgr) removePreemptible(c *chunk) {
+	delete(m.preemptible, c)
+	if atomic.LoadInt32(&c.preempt) != 0 {
+		atomic.StoreInt32(&c.preempt, 0)
+		m.preempting--
+	}
+}
+
+// preempt asks the poller to give back a running chunk if every
+// Parallel slot is in use and the chunk which would take the freed slot
+// belongs to a stream whose Priority number is lower by at least
+// Preemption. Because Priority only orders streams within a tenant, a
+// chunk is never preempted if its slot would go to another tenant. Only
+// one chunk is preempted at a time, and the victim is the
+// lowest-priority chunk, or among those the most recently started one,
+// so as to waste the least work.
+func (m *mgr) preempt() {
+	if m.Preemption <= 0 || m.preempting > 0 || m.numStarting+m.numPolling+m.numStopping < m.parallel {
+		return
+	}
+
+	var victim *chunk
+	for c := range m.preemptible {
+		s := m.nextStream(c)
+		if s == nil || s.Tenant != c.stream.Tenant || c.stream.Priority-s.Priority < m.Preemption {
+			continue
+		}
+		if victim == nil || c.stream.Priority > victim.stream.Priority ||
+			c.stream.Priority == victim.stream.Priority && c.since.After(victim.since) {
+			victim = c
+		}
+	}
+	if victim == nil {
+		return
+	}
+
+	m.logChunk(victim, "preempting", "priority "+strconv.Itoa(victim.stream.Priority))
+	m.preemptChunk(victim)
+}
+
+// preemptChunk asks the poller to give back a running chunk so that
+// its Insights query can be stopped and the chunk restarted later.
+func (m *mgr) preemptChunk(c *chunk) {
+	delete(m.preemptible, c)
+	atomic.StoreInt32(&c.preempt, 1)
+	m.preempting++
+}
+
+// requeuePreempted stops the Insights query of a chunk which gave up its
+// Parallel slot, and queues the chunk to restart when its stream is
+// next scheduled.
+func (m *mgr) requeuePreempted(c *chunk) {
+	s := c.stream
+	if s.isPaused() {
+		m.logChunk(c, "paused, will stop and restart later", "")
+	} else {
+		m.logChunk(c, "preempted, will stop and restart later", "")
+		c.RangePreempted += c.duration()
+	}
+	stop := *c
+	m.stopChunk(&stop)
+
+	c.chunkID += "P"
+	c.err = nil
+	s.preempted = append(s.preempted, c)
+	if !s.queued && !s.parked && !s.sleeping {
+		m.pushStream(s)
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright 2022 The incite Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package incite
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMgr_preempt(t *testing.T) {
+	t.Run("Lowest Priority Newest Chunk Is Preempted", func(t *testing.T) {
+		m, low := newTestablePreemption(t, 2, 0)
+		older, newer := m.runPreemptible(low, time.Minute), m.runPreemptible(low, time.Second)
+
+		m.preempt()
+
+		assert.Equal(t, int32(0), older.preempt)
+		assert.Equal(t, int32(1), newer.preempt)
+		assert.Equal(t, 1, m.preempting)
+		assert.NotContains(t, m.preemptible, newer)
+
+		t.Run("Only One Chunk At A Time", func(t *testing.T) {
+			m.preempt()
+
+			assert.Equal(t, int32(0), older.preempt)
+			assert.Equal(t, 1, m.preempting)
+		})
+
+		t.Run("Returned Chunk Is No Longer Preempting", func(t *testing.T) {
+			m.release(newer)
+
+			assert.Equal(t, int32(0), newer.preempt)
+			assert.Equal(t, 0, m.preempting)
+		})
+	})
+
+	t.Run("Priority Gap Too Small", func(t *testing.T) {
+		m, low := newTestablePreemption(t, 2, 4)
+		c := m.runPreemptible(low, time.Second)
+
+		m.preempt()
+
+		assert.Equal(t, int32(0), c.preempt)
+		assert.Equal(t, 0, m.preempting)
+	})
+
+	t.Run("Free Slot", func(t *testing.T) {
+		m, low := newTestablePreemption(t, 2, 0)
+		c := m.runPreemptible(low, time.Second)
+		m.parallel = 3
+
+		m.preempt()
+
+		assert.Equal(t, int32(0), c.preempt)
+	})
+
+	t.Run("Other Tenant", func(t *testing.T) {
+		m, low := newTestablePreemption(t, 2, 0)
+		other := newTenantStream("other", 10)
+		other.Priority = low.Priority
+		c := m.runPreemptible(other, time.Second)
+
+		m.preempt()
+
+		assert.Equal(t, int32(0), c.preempt)
+	})
+
+	t.Run("Urgent Stream Capped", func(t *testing.T) {
+		m, low := newTestablePreemption(t, 2, 0)
+		urgent := m.tenants[""].pq[0]
+		urgent.MaxParallel = 1
+		urgent.active = 1
+		c := m.runPreemptible(low, time.Second)
+
+		m.preempt()
+
+		assert.Equal(t, int32(0), c.preempt)
+		assert.True(t, urgent.queued)
+		assert.False(t, urgent.parked)
+	})
+
+	t.Run("Urgent Stream Paused", func(t *testing.T) {
+		m, low := newTestablePreemption(t, 2, 0)
+		urgent := m.tenants[""].pq[0]
+		require.NoError(t, urgent.Pause(false))
+		c := m.runPreemptible(low, time.Second)
+
+		m.preempt()
+
+		assert.Equal(t, int32(0), c.preempt)
+		assert.True(t, urgent.queued)
+		assert.False(t, urgent.sleeping)
+	})
+
+	t.Run("Scheduling State Is Unchanged", func(t *testing.T) {
+		m, low := newTestablePreemption(t, 2, 0)
+		urgent := m.tenants[""].pq[0]
+		m.runPreemptible(low, time.Second)
+
+		m.preempt()
+
+		assert.Equal(t, 1, m.preempting)
+		assert.Equal(t, 0, m.numReady)
+		assert.Equal(t, int64(0), urgent.next)
+		assert.Equal(t, 1, m.tenants[""].running)
+		assert.Equal(t, 1, low.active)
+		c := m.getReadyChunk()
+		require.NotNil(t, c)
+		assert.Same(t, urgent, c.stream)
+		assert.Equal(t, "0", c.chunkID)
+		assert.Nil(t, m.getReadyChunk())
+	})
+
+	t.Run("Slot Would Go To Other Tenant", func(t *testing.T) {
+		m, low := newTestablePreemption(t, 2, 0)
+		older, newer := m.runPreemptible(low, time.Minute), m.runPreemptible(low, time.Second)
+		other := newTenantStream("other", 10)
+		other.Priority = 9
+		m.pushStream(other)
+
+		m.preempt()
+
+		assert.Equal(t, int32(0), older.preempt)
+		assert.Equal(t, int32(0), newer.preempt)
+		assert.Equal(t, 0, m.preempting)
+	})
+
+	t.Run("Other Tenant Has No Chunk To Start", func(t *testing.T) {
+		m, low := newTestablePreemption(t, 2, 0)
+		older, newer := m.runPreemptible(low, time.Minute), m.runPreemptible(low, time.Second)
+		other := newTenantStream("other", 10)
+		other.MaxParallel = 1
+		other.active = 1
+		m.pushStream(other)
+
+		m.preempt()
+
+		assert.Equal(t, int32(0), older.preempt)
+		assert.Equal(t, int32(1), newer.preempt)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		m, low := newTestablePreemption(t, 2, 0)
+		m.Preemption = 0
+		c := m.runPreemptible(low, time.Second)
+
+		m.preempt()
+
+		assert.Equal(t, int32(0), c.preempt)
+		assert.Equal(t, 0, m.preempting)
+	})
+}
+
+func TestMgr_requeuePreempted(t *testing.T) {
+	m, low := newTestablePreemption(t, 1, 0)
+	c := m.runPreemptible(low, time.Second)
+	m.release(c)
+	c.err = errPreemptChunk
+
+	m.handlePollingError(c)
+
+	require.Len(t, m.stop, 1)
+	stop := <-m.stop
+	assert.Equal(t, stopping, stop.state)
+	assert.Equal(t, c.queryID, stop.queryID)
+	assert.NoError(t, c.err)
+	assert.Equal(t, "0P", c.chunkID)
+	assert.Equal(t, time.Minute, c.RangePreempted)
+	assert.Equal(t, []*chunk{c}, low.preempted)
+	assert.True(t, low.queued)
+
+	first := m.getReadyChunk()
+	require.NotNil(t, first)
+	assert.Equal(t, 0, first.stream.Priority, "urgent stream must start first")
+	m.dispatch(make(chan *chunk, 1), first)
+	assert.Same(t, c, m.getReadyChunk(), "preempted chunk must restart once its stream is next")
+	c.started()
+	assert.Equal(t, time.Minute, c.RangeStarted, "restarted chunk must only be counted once")
+}
+
+// newTestablePreemption returns a mgr whose Parallel slots are all in
+// use, with an urgent stream of Priority 0 waiting, and a low-priority
+// stream of Priority 3 whose chunks may be run with runPreemptible.
+func newTestablePreemption(t *testing.T, preemption, urgentPriority int) (*mgr, *stream) {
+	m := &mgr{
+		Config: Config{
+			Preemption: preemption,
+			Logger:     NopLogger,
+		},
+		stop: make(chan *chunk, 1),
+	}
+	urgent := newTenantStream("", 1)
+	urgent.Priority = urgentPriority
+	m.pushStream(urgent)
+	low := newTenantStream("", 10)
+	low.Priority = 3
+	low.next = 1
+	low.n = 1
+	return m, low
+}
+
+// runPreemptible hands off a chunk of the stream to the poller as if it
+// had been started the given time ago.
+func (m *mgr) runPreemptible(s *stream, age time.Duration) *chunk {
+	c := &chunk{
+		stream:  s,
+		chunkID: "0",
+		queryID: "q",
+		start:   s.Start,
+		end:     s.Start.Add(time.Minute),
+		since:   time.Now().Add(-age),
+	}
+	c.started()
+	m.dispatch(make(chan *chunk, 1), c)
+	m.addPreemptible(c)
+	m.numPolling++
+	m.parallel = m.numPolling
+	return c
+}
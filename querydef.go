@@ -0,0 +1,274 @@
+// Copyright 2022 The incite Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package incite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+)
+
+// queryDefinitionRPS is the rate at which Query may call the CloudWatch
+// Logs DescribeQueryDefinitions act to look up query definitions which
+// are not in the cache.
+const queryDefinitionRPS = 2
+
+// QueryDefinition is a saved CloudWatch Logs Insights query, as shown
+// in the "Saved queries" section of the CloudWatch Logs console.
+type QueryDefinition struct {
+	// ID is the unique query definition ID assigned by CloudWatch Logs.
+	// It is empty for a query definition that has not yet been saved.
+	ID string
+	// Name is the query definition name. Names may include slashes,
+	// which the CloudWatch Logs console uses to organize saved queries
+	// into folders.
+	Name string
+	// Text is the text of the saved Insights query.
+	Text string
+	// Groups lists the names of the log groups associated with the
+	// saved query. It may be empty.
+	Groups []string
+	// LastModified is the time the query definition was last saved.
+	// It is ignored by PutQueryDefinition.
+	LastModified time.Time
+}
+
+// ListQueryDefinitions returns all query definitions whose names begin
+// with prefix, using the CloudWatch Logs actions provided by a. If
+// prefix is empty, all query definitions are returned.
+func ListQueryDefinitions(ctx context.Context, a QueryDefinitionActions, prefix string) ([]QueryDefinition, error) {
+	if ctx == nil {
+		panic(nilContextMsg)
+	}
+	if a == nil {
+		panic(nilActionsMsg)
+	}
+
+	var defs []QueryDefinition
+	input := cloudwatchlogs.DescribeQueryDefinitionsInput{}
+	if prefix != "" {
+		input.QueryDefinitionNamePrefix = &prefix
+	}
+	for {
+		output, err := a.DescribeQueryDefinitionsWithContext(ctx, &input, request.WithAppendUserAgent(version()))
+		if err != nil {
+			return nil, errDescribeQueryDefinitions(err)
+		}
+		for _, def := range output.QueryDefinitions {
+			if def != nil {
+				defs = append(defs, translateQueryDefinition(def))
+			}
+		}
+		if output.NextToken == nil || *output.NextToken == "" {
+			break
+		}
+		input.NextToken = output.NextToken
+	}
+
+	return defs, nil
+}
+
+// GetQueryDefinition returns the query definition whose name or ID is
+// nameOrID, using the CloudWatch Logs actions provided by a. A name
+// match takes precedence over an ID match. If there is no matching
+// query definition, the error returned wraps
+// ErrQueryDefinitionNotFound.
+func GetQueryDefinition(ctx context.Context, a QueryDefinitionActions, nameOrID string) (QueryDefinition, error) {
+	if strings.TrimSpace(nameOrID) == "" {
+		return QueryDefinition{}, errors.New(queryDefinitionBlankMsg)
+	}
+
+	// Try to find the query definition by name first, since the name
+	// can be used as a prefix to narrow down the search.
+	defs, err := ListQueryDefinitions(ctx, a, nameOrID)
+	if err != nil {
+		return QueryDefinition{}, err
+	}
+	for _, def := range defs {
+		if def.Name == nameOrID {
+			return def, nil
+		}
+	}
+
+	// Fall back to finding the query definition by ID.
+	defs, err = ListQueryDefinitions(ctx, a, "")
+	if err != nil {
+		return QueryDefinition{}, err
+	}
+	for _, def := range defs {
+		if def.ID == nameOrID {
+			return def, nil
+		}
+	}
+
+	return QueryDefinition{}, fmt.Errorf("%w: %q", ErrQueryDefinitionNotFound, nameOrID)
+}
+
+// PutQueryDefinition saves def, using the CloudWatch Logs actions
+// provided by a, and returns the ID of the saved query definition.
+//
+// If def.ID is empty, a new query definition is created. Otherwise, the
+// existing query definition having the ID def.ID is updated.
+func PutQueryDefinition(ctx context.Context, a QueryDefinitionActions, def QueryDefinition) (string, error) {
+	if ctx == nil {
+		panic(nilContextMsg)
+	}
+	if a == nil {
+		panic(nilActionsMsg)
+	}
+	if strings.TrimSpace(def.Name) == "" {
+		return "", errors.New(queryDefinitionNameBlankMsg)
+	}
+	if strings.TrimSpace(def.Text) == "" {
+		return "", errors.New(textBlankMsg)
+	}
+
+	input := cloudwatchlogs.PutQueryDefinitionInput{
+		Name:        &def.Name,
+		QueryString: &def.Text,
+	}
+	if def.ID != "" {
+		input.QueryDefinitionId = &def.ID
+	}
+	if len(def.Groups) > 0 {
+		input.LogGroupNames = make([]*string, len(def.Groups))
+		for i := range def.Groups {
+			input.LogGroupNames[i] = &def.Groups[i]
+		}
+	}
+	output, err := a.PutQueryDefinitionWithContext(ctx, &input, request.WithAppendUserAgent(version()))
+	if err != nil {
+		return "", errPutQueryDefinition(def.Name, err)
+	}
+	if output.QueryDefinitionId == nil {
+		return "", errors.New(outputMissingQueryDefinitionIDMsg)
+	}
+
+	return *output.QueryDefinitionId, nil
+}
+
+func translateQueryDefinition(in *cloudwatchlogs.QueryDefinition) QueryDefinition {
+	var out QueryDefinition
+	if in.QueryDefinitionId != nil {
+		out.ID = *in.QueryDefinitionId
+	}
+	if in.Name != nil {
+		out.Name = *in.Name
+	}
+	if in.QueryString != nil {
+		out.Text = *in.QueryString
+	}
+	for _, g := range in.LogGroupNames {
+		if g != nil {
+			out.Groups = append(out.Groups, *g)
+		}
+	}
+	if in.LastModified != nil {
+		out.LastModified = time.Unix(0, *in.LastModified*int64(time.Millisecond)).UTC()
+	}
+	return out
+}
+
+// A queryDefCache caches the query definitions looked up by Query,
+// keyed by the name or ID used to look them up. It is safe for
+// concurrent use by multiple goroutines.
+type queryDefCache struct {
+	lock    sync.Mutex
+	entries map[string]queryDefCacheEntry // Keyed by query definition name or ID
+}
+
+type queryDefCacheEntry struct {
+	def     QueryDefinition // Query definition found
+	expires time.Time       // Time after which the entry is stale
+}
+
+func (qc *queryDefCache) get(nameOrID string, now time.Time) (QueryDefinition, bool) {
+	qc.lock.Lock()
+	defer qc.lock.Unlock()
+
+	e, ok := qc.entries[nameOrID]
+	if !ok || !now.Before(e.expires) {
+		return QueryDefinition{}, false
+	}
+	return e.def, true
+}
+
+func (qc *queryDefCache) put(nameOrID string, def QueryDefinition, expires time.Time) {
+	qc.lock.Lock()
+	defer qc.lock.Unlock()
+
+	if qc.entries == nil {
+		qc.entries = make(map[string]queryDefCacheEntry)
+	}
+	qc.entries[nameOrID] = queryDefCacheEntry{def, expires}
+}
+
+// A limitedQueryDefinitionActions limits the rate of the
+// DescribeQueryDefinitions requests made by its owning mgr to
+// queryDefinitionRPS, so that a burst of Query calls cannot exceed the
+// CloudWatch Logs quota for the act.
+type limitedQueryDefinitionActions struct {
+	QueryDefinitionActions
+	m *mgr
+}
+
+func (a limitedQueryDefinitionActions) DescribeQueryDefinitionsWithContext(ctx context.Context, input *cloudwatchlogs.DescribeQueryDefinitionsInput, opts ...request.Option) (*cloudwatchlogs.DescribeQueryDefinitionsOutput, error) {
+	if err := a.m.queryDefLimit.wait(ctx); err != nil {
+		return nil, err
+	}
+	return a.QueryDefinitionActions.DescribeQueryDefinitionsWithContext(ctx, input, opts...)
+}
+
+// applyQueryDefinition fills in the text and log groups of q from the
+// query definition named in q.QueryDefinition, if any. Text and log
+// groups already present in q take precedence over those in the query
+// definition. The query definition is taken from the cache if possible
+// and otherwise looked up using the CloudWatch Logs
+// DescribeQueryDefinitions act, at a rate of at most queryDefinitionRPS.
+func (m *mgr) applyQueryDefinition(ctx context.Context, q *QuerySpec) error {
+	if q.QueryDefinition == "" {
+		return nil
+	}
+
+	a, ok := m.Actions.(QueryDefinitionActions)
+	if !ok {
+		return errors.New(noQueryDefinitionActionsMsg)
+	}
+
+	ttl := m.QueryDefinitionCacheTTL
+	if ttl == 0 {
+		ttl = DefaultQueryDefinitionCacheTTL
+	}
+
+	now := time.Now()
+	def, found := QueryDefinition{}, false
+	if ttl > 0 {
+		def, found = m.queryDefs.get(q.QueryDefinition, now)
+	}
+	if !found {
+		var err error
+		def, err = GetQueryDefinition(ctx, limitedQueryDefinitionActions{a, m}, q.QueryDefinition)
+		if err != nil {
+			return err
+		}
+		if ttl > 0 {
+			m.queryDefs.put(q.QueryDefinition, def, now.Add(ttl))
+		}
+	}
+
+	if strings.TrimSpace(q.Text) == "" {
+		q.Text = def.Text
+	}
+	if !hasGroups(q) {
+		q.Groups = def.Groups
+	}
+	return nil
+}
@@ -0,0 +1,519 @@
+// Copyright 2022 The incite Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package incite
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListQueryDefinitions(t *testing.T) {
+	t.Run("Invalid Input", func(t *testing.T) {
+		assert.PanicsWithValue(t, nilContextMsg, func() {
+			_, _ = ListQueryDefinitions(nil, newMockActions(t), "")
+		})
+		assert.PanicsWithValue(t, nilActionsMsg, func() {
+			_, _ = ListQueryDefinitions(context.Background(), nil, "")
+		})
+	})
+
+	t.Run("Describe Fails", func(t *testing.T) {
+		cause := errors.New("no definitions today")
+		actions := newMockActions(t)
+		actions.
+			On("DescribeQueryDefinitionsWithContext", anyContext, &cloudwatchlogs.DescribeQueryDefinitionsInput{}).
+			Return(nil, cause).
+			Once()
+
+		defs, err := ListQueryDefinitions(context.Background(), actions, "")
+
+		assert.Nil(t, defs)
+		assert.ErrorIs(t, err, cause)
+		actions.AssertExpectations(t)
+	})
+
+	t.Run("Multiple Pages", func(t *testing.T) {
+		actions := newMockActions(t)
+		actions.
+			On("DescribeQueryDefinitionsWithContext", anyContext, &cloudwatchlogs.DescribeQueryDefinitionsInput{
+				QueryDefinitionNamePrefix: sp("ops/"),
+			}).
+			Return(&cloudwatchlogs.DescribeQueryDefinitionsOutput{
+				QueryDefinitions: []*cloudwatchlogs.QueryDefinition{
+					{
+						QueryDefinitionId: sp("1"),
+						Name:              sp("ops/errors"),
+						QueryString:       sp("filter @message like /ERROR/"),
+						LogGroupNames:     []*string{sp("a"), sp("b")},
+						LastModified:      int64p(1_654_041_600_000),
+					},
+				},
+				NextToken: sp("more"),
+			}, nil).
+			Once()
+		actions.
+			On("DescribeQueryDefinitionsWithContext", anyContext, &cloudwatchlogs.DescribeQueryDefinitionsInput{
+				QueryDefinitionNamePrefix: sp("ops/"),
+				NextToken:                 sp("more"),
+			}).
+			Return(&cloudwatchlogs.DescribeQueryDefinitionsOutput{
+				QueryDefinitions: []*cloudwatchlogs.QueryDefinition{
+					{
+						QueryDefinitionId: sp("2"),
+						Name:              sp("ops/latency"),
+						QueryString:       sp("stats avg(latency)"),
+					},
+				},
+			}, nil).
+			Once()
+
+		defs, err := ListQueryDefinitions(context.Background(), actions, "ops/")
+
+		assert.NoError(t, err)
+		assert.Equal(t, []QueryDefinition{
+			{
+				ID:           "1",
+				Name:         "ops/errors",
+				Text:         "filter @message like /ERROR/",
+				Groups:       []string{"a", "b"},
+				LastModified: time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC),
+			},
+			{
+				ID:   "2",
+				Name: "ops/latency",
+				Text: "stats avg(latency)",
+			},
+		}, defs)
+		actions.AssertExpectations(t)
+	})
+}
+
+func TestGetQueryDefinition(t *testing.T) {
+	defs := []*cloudwatchlogs.QueryDefinition{
+		{
+			QueryDefinitionId: sp("id-1"),
+			Name:              sp("errors-extended"),
+			QueryString:       sp("text-1"),
+		},
+		{
+			QueryDefinitionId: sp("id-2"),
+			Name:              sp("errors"),
+			QueryString:       sp("text-2"),
+		},
+	}
+
+	t.Run("Blank", func(t *testing.T) {
+		_, err := GetQueryDefinition(context.Background(), newMockActions(t), " ")
+
+		assert.EqualError(t, err, queryDefinitionBlankMsg)
+	})
+
+	t.Run("By Name", func(t *testing.T) {
+		actions := newMockActions(t)
+		actions.
+			On("DescribeQueryDefinitionsWithContext", anyContext, &cloudwatchlogs.DescribeQueryDefinitionsInput{
+				QueryDefinitionNamePrefix: sp("errors"),
+			}).
+			Return(&cloudwatchlogs.DescribeQueryDefinitionsOutput{QueryDefinitions: defs}, nil).
+			Once()
+
+		def, err := GetQueryDefinition(context.Background(), actions, "errors")
+
+		assert.NoError(t, err)
+		assert.Equal(t, QueryDefinition{ID: "id-2", Name: "errors", Text: "text-2"}, def)
+		actions.AssertExpectations(t)
+	})
+
+	t.Run("By ID", func(t *testing.T) {
+		actions := newMockActions(t)
+		actions.
+			On("DescribeQueryDefinitionsWithContext", anyContext, &cloudwatchlogs.DescribeQueryDefinitionsInput{
+				QueryDefinitionNamePrefix: sp("id-1"),
+			}).
+			Return(&cloudwatchlogs.DescribeQueryDefinitionsOutput{}, nil).
+			Once()
+		actions.
+			On("DescribeQueryDefinitionsWithContext", anyContext, &cloudwatchlogs.DescribeQueryDefinitionsInput{}).
+			Return(&cloudwatchlogs.DescribeQueryDefinitionsOutput{QueryDefinitions: defs}, nil).
+			Once()
+
+		def, err := GetQueryDefinition(context.Background(), actions, "id-1")
+
+		assert.NoError(t, err)
+		assert.Equal(t, QueryDefinition{ID: "id-1", Name: "errors-extended", Text: "text-1"}, def)
+		actions.AssertExpectations(t)
+	})
+
+	t.Run("Not Found", func(t *testing.T) {
+		actions := newMockActions(t)
+		actions.
+			On("DescribeQueryDefinitionsWithContext", anyContext, &cloudwatchlogs.DescribeQueryDefinitionsInput{
+				QueryDefinitionNamePrefix: sp("nope"),
+			}).
+			Return(&cloudwatchlogs.DescribeQueryDefinitionsOutput{}, nil).
+			Once()
+		actions.
+			On("DescribeQueryDefinitionsWithContext", anyContext, &cloudwatchlogs.DescribeQueryDefinitionsInput{}).
+			Return(&cloudwatchlogs.DescribeQueryDefinitionsOutput{QueryDefinitions: defs}, nil).
+			Once()
+
+		_, err := GetQueryDefinition(context.Background(), actions, "nope")
+
+		assert.ErrorIs(t, err, ErrQueryDefinitionNotFound)
+		actions.AssertExpectations(t)
+	})
+}
+
+func TestPutQueryDefinition(t *testing.T) {
+	t.Run("Invalid Input", func(t *testing.T) {
+		testCases := []struct {
+			name string
+			def  QueryDefinition
+			err  string
+		}{
+			{
+				name: "Name.Blank",
+				def:  QueryDefinition{Text: "fields @message"},
+				err:  queryDefinitionNameBlankMsg,
+			},
+			{
+				name: "Text.Blank",
+				def:  QueryDefinition{Name: "foo"},
+				err:  textBlankMsg,
+			},
+		}
+
+		for _, testCase := range testCases {
+			t.Run(testCase.name, func(t *testing.T) {
+				id, err := PutQueryDefinition(context.Background(), newMockActions(t), testCase.def)
+
+				assert.Empty(t, id)
+				assert.EqualError(t, err, testCase.err)
+			})
+		}
+	})
+
+	t.Run("Create", func(t *testing.T) {
+		actions := newMockActions(t)
+		actions.
+			On("PutQueryDefinitionWithContext", anyContext, &cloudwatchlogs.PutQueryDefinitionInput{
+				Name:          sp("new"),
+				QueryString:   sp("fields @message"),
+				LogGroupNames: []*string{sp("g")},
+			}).
+			Return(&cloudwatchlogs.PutQueryDefinitionOutput{QueryDefinitionId: sp("fresh")}, nil).
+			Once()
+
+		id, err := PutQueryDefinition(context.Background(), actions, QueryDefinition{
+			Name:   "new",
+			Text:   "fields @message",
+			Groups: []string{"g"},
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "fresh", id)
+		actions.AssertExpectations(t)
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		actions := newMockActions(t)
+		actions.
+			On("PutQueryDefinitionWithContext", anyContext, &cloudwatchlogs.PutQueryDefinitionInput{
+				QueryDefinitionId: sp("old"),
+				Name:              sp("existing"),
+				QueryString:       sp("fields @timestamp"),
+			}).
+			Return(&cloudwatchlogs.PutQueryDefinitionOutput{QueryDefinitionId: sp("old")}, nil).
+			Once()
+
+		id, err := PutQueryDefinition(context.Background(), actions, QueryDefinition{
+			ID:   "old",
+			Name: "existing",
+			Text: "fields @timestamp",
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "old", id)
+		actions.AssertExpectations(t)
+	})
+
+	t.Run("Put Fails", func(t *testing.T) {
+		cause := errors.New("cannot save")
+		actions := newMockActions(t)
+		actions.
+			On("PutQueryDefinitionWithContext", anyContext, &cloudwatchlogs.PutQueryDefinitionInput{
+				Name:        sp("x"),
+				QueryString: sp("y"),
+			}).
+			Return(nil, cause).
+			Once()
+
+		_, err := PutQueryDefinition(context.Background(), actions, QueryDefinition{Name: "x", Text: "y"})
+
+		assert.ErrorIs(t, err, cause)
+		actions.AssertExpectations(t)
+	})
+
+	t.Run("Nil ID", func(t *testing.T) {
+		actions := newMockActions(t)
+		actions.
+			On("PutQueryDefinitionWithContext", anyContext, &cloudwatchlogs.PutQueryDefinitionInput{
+				Name:        sp("x"),
+				QueryString: sp("y"),
+			}).
+			Return(&cloudwatchlogs.PutQueryDefinitionOutput{}, nil).
+			Once()
+
+		_, err := PutQueryDefinition(context.Background(), actions, QueryDefinition{Name: "x", Text: "y"})
+
+		assert.EqualError(t, err, outputMissingQueryDefinitionIDMsg)
+		actions.AssertExpectations(t)
+	})
+}
+
+func TestQueryManager_Query_QueryDefinition(t *testing.T) {
+	t.Run("Actions.NotQueryDefinitionActions", func(t *testing.T) {
+		m := NewQueryManager(Config{
+			Actions: struct {
+				CloudWatchLogsActions
+			}{newMockActions(t)},
+		})
+		t.Cleanup(func() {
+			_ = m.Close()
+		})
+
+		s, err := m.Query(QuerySpec{
+			QueryDefinition: "foo",
+			Start:           defaultStart,
+			End:             defaultEnd,
+		})
+
+		assert.Nil(t, s)
+		assert.EqualError(t, err, noQueryDefinitionActionsMsg)
+	})
+
+	t.Run("Text and Groups Inherited", func(t *testing.T) {
+		text := "fields @message | filter level = 'ERROR'"
+		queryID := "from-definition"
+		actions := newMockActions(t)
+		actions.
+			On("DescribeQueryDefinitionsWithContext", anyContext, &cloudwatchlogs.DescribeQueryDefinitionsInput{
+				QueryDefinitionNamePrefix: sp("team/errors"),
+			}).
+			Return(&cloudwatchlogs.DescribeQueryDefinitionsOutput{
+				QueryDefinitions: []*cloudwatchlogs.QueryDefinition{
+					{
+						QueryDefinitionId: sp("abc"),
+						Name:              sp("team/errors"),
+						QueryString:       &text,
+						LogGroupNames:     []*string{sp("svc-a"), sp("svc-b")},
+					},
+				},
+			}, nil).
+			Once()
+		actions.
+			On("StartQueryWithContext", anyContext, startQueryInput(text, defaultStart, defaultEnd, DefaultLimit, "svc-a", "svc-b")).
+			Return(&cloudwatchlogs.StartQueryOutput{QueryId: &queryID}, nil).
+			Once()
+		actions.
+			On("GetQueryResultsWithContext", anyContext, &cloudwatchlogs.GetQueryResultsInput{QueryId: &queryID}).
+			Return(&cloudwatchlogs.GetQueryResultsOutput{
+				Status:  sp(cloudwatchlogs.QueryStatusComplete),
+				Results: backOut([]Result{{{"@message", "boom"}}}),
+			}, nil).
+			Once()
+		m := NewQueryManager(Config{
+			Actions: actions,
+			RPS:     lotsOfRPS,
+		})
+		t.Cleanup(func() {
+			_ = m.Close()
+		})
+
+		s, err := m.Query(QuerySpec{
+			QueryDefinition: "team/errors",
+			Start:           defaultStart,
+			End:             defaultEnd,
+		})
+		require.NoError(t, err)
+		require.NotNil(t, s)
+		r, err := ReadAll(s)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []Result{{{"@message", "boom"}}}, r)
+		actions.AssertExpectations(t)
+	})
+
+	t.Run("Validated Before Lookup", func(t *testing.T) {
+		actions := newMockActions(t)
+		m := NewQueryManager(Config{
+			Actions: actions,
+		})
+		t.Cleanup(func() {
+			_ = m.Close()
+		})
+
+		s, err := m.Query(QuerySpec{
+			QueryDefinition: "team/errors",
+			Start:           defaultEnd,
+			End:             defaultStart,
+		})
+
+		assert.Nil(t, s)
+		assert.EqualError(t, err, endNotBeforeStartMsg)
+		actions.AssertExpectations(t)
+	})
+
+	t.Run("Blank Definition Text", func(t *testing.T) {
+		actions := newMockActions(t)
+		actions.
+			On("DescribeQueryDefinitionsWithContext", anyContext, &cloudwatchlogs.DescribeQueryDefinitionsInput{
+				QueryDefinitionNamePrefix: sp("empty"),
+			}).
+			Return(&cloudwatchlogs.DescribeQueryDefinitionsOutput{
+				QueryDefinitions: []*cloudwatchlogs.QueryDefinition{
+					{
+						QueryDefinitionId: sp("abc"),
+						Name:              sp("empty"),
+						LogGroupNames:     []*string{sp("svc-a")},
+					},
+				},
+			}, nil).
+			Once()
+		m := NewQueryManager(Config{
+			Actions: actions,
+		})
+		t.Cleanup(func() {
+			_ = m.Close()
+		})
+
+		s, err := m.Query(QuerySpec{
+			QueryDefinition: "empty",
+			Start:           defaultStart,
+			End:             defaultEnd,
+		})
+
+		assert.Nil(t, s)
+		assert.EqualError(t, err, textBlankMsg)
+		actions.AssertExpectations(t)
+	})
+}
+
+func TestMgr_applyQueryDefinition(t *testing.T) {
+	def := &cloudwatchlogs.QueryDefinition{
+		QueryDefinitionId: sp("abc"),
+		Name:              sp("team/errors"),
+		QueryString:       sp("fields @message"),
+		LogGroupNames:     []*string{sp("svc-a")},
+	}
+
+	t.Run("Cached", func(t *testing.T) {
+		actions := newMockActions(t)
+		actions.
+			On("DescribeQueryDefinitionsWithContext", anyContext, &cloudwatchlogs.DescribeQueryDefinitionsInput{
+				QueryDefinitionNamePrefix: sp("team/errors"),
+			}).
+			Return(&cloudwatchlogs.DescribeQueryDefinitionsOutput{
+				QueryDefinitions: []*cloudwatchlogs.QueryDefinition{def},
+			}, nil).
+			Once()
+		m := NewQueryManager(Config{
+			Actions: actions,
+		})
+		t.Cleanup(func() {
+			_ = m.Close()
+		})
+
+		for i := 0; i < 3; i++ {
+			q := QuerySpec{QueryDefinition: "team/errors"}
+			require.NoError(t, m.(*mgr).applyQueryDefinition(context.Background(), &q))
+			assert.Equal(t, "fields @message", q.Text)
+			assert.Equal(t, []string{"svc-a"}, q.Groups)
+		}
+		actions.AssertExpectations(t)
+	})
+
+	t.Run("Not Cached", func(t *testing.T) {
+		actions := newMockActions(t)
+		actions.
+			On("DescribeQueryDefinitionsWithContext", anyContext, &cloudwatchlogs.DescribeQueryDefinitionsInput{
+				QueryDefinitionNamePrefix: sp("team/errors"),
+			}).
+			Return(&cloudwatchlogs.DescribeQueryDefinitionsOutput{
+				QueryDefinitions: []*cloudwatchlogs.QueryDefinition{def},
+			}, nil).
+			Twice()
+		m := NewQueryManager(Config{
+			Actions:                 actions,
+			QueryDefinitionCacheTTL: -1,
+		})
+		t.Cleanup(func() {
+			_ = m.Close()
+		})
+
+		for i := 0; i < 2; i++ {
+			q := QuerySpec{QueryDefinition: "team/errors"}
+			require.NoError(t, m.(*mgr).applyQueryDefinition(context.Background(), &q))
+		}
+		actions.AssertExpectations(t)
+	})
+
+	t.Run("Rate Limited", func(t *testing.T) {
+		actions := newMockActions(t)
+		actions.
+			On("DescribeQueryDefinitionsWithContext", anyContext, &cloudwatchlogs.DescribeQueryDefinitionsInput{
+				QueryDefinitionNamePrefix: sp("abc"),
+			}).
+			Return(&cloudwatchlogs.DescribeQueryDefinitionsOutput{}, nil).
+			Once()
+		actions.
+			On("DescribeQueryDefinitionsWithContext", anyContext, &cloudwatchlogs.DescribeQueryDefinitionsInput{}).
+			Return(&cloudwatchlogs.DescribeQueryDefinitionsOutput{
+				QueryDefinitions: []*cloudwatchlogs.QueryDefinition{def},
+			}, nil).
+			Once()
+		m := NewQueryManager(Config{
+			Actions: actions,
+		})
+		t.Cleanup(func() {
+			_ = m.Close()
+		})
+		q := QuerySpec{QueryDefinition: "abc"}
+
+		before := time.Now()
+		err := m.(*mgr).applyQueryDefinition(context.Background(), &q)
+		after := time.Now()
+
+		require.NoError(t, err)
+		assert.Equal(t, "fields @message", q.Text)
+		assert.GreaterOrEqual(t, after.Sub(before), time.Second/queryDefinitionRPS-10*time.Millisecond)
+		actions.AssertExpectations(t)
+	})
+
+	t.Run("Closed While Waiting", func(t *testing.T) {
+		actions := newMockActions(t)
+		m := NewQueryManager(Config{
+			Actions: actions,
+		})
+		m2 := m.(*mgr)
+		m2.queryDefLimit.reserve()
+		m2.queryDefLimit.reserve()
+		ctx, cancel := m2.lookupContext()
+		defer cancel()
+		q := QuerySpec{QueryDefinition: "team/errors"}
+
+		_ = m.Close()
+		err := m2.applyQueryDefinition(ctx, &q)
+
+		assert.ErrorIs(t, err, context.Canceled)
+		actions.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,191 @@
+// Copyright 2022 The incite Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package incite
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimiter limits the combined rate of CloudWatch Logs requests made
+// by every QueryManager which shares it.
+//
+// Each QueryManager regulates its own request rate using the RPS and
+// Burst fields of its Config. Since CloudWatch Logs applies its RPS
+// quotas to the whole AWS account and region, several QueryManagers
+// running at the same time can together exceed the quotas even though
+// each one stays within its own limits. Setting the RateLimiter field
+// of Config to the same RateLimiter in each QueryManager makes them
+// share a single request budget.
+//
+// Use NewRateLimiter to create a RateLimiter which is shared by
+// QueryManagers within the same process. To share a request budget
+// across processes, implement RateLimiter on top of a distributed
+// coordination service.
+//
+// Implementations must be safe for concurrent use by multiple
+// goroutines.
+type RateLimiter interface {
+	// Wait blocks until a request for the given CloudWatch Logs action
+	// may be made, or until ctx is done. If ctx is done before the
+	// request is permitted, Wait returns the context error, and the
+	// request is not made.
+	Wait(ctx context.Context, action CloudWatchLogsAction) error
+}
+
+// NewRateLimiter returns a new in-process RateLimiter which limits the
+// combined request rate of all QueryManagers sharing it.
+//
+// The rps and burst parameters have the same meaning as the RPS and
+// Burst fields of Config, but apply to all the sharing QueryManagers
+// together. If rps has a missing, zero, or negative value for any
+// CloudWatch Logs action, the value specified in RPSDefaults is used
+// instead. If burst has a missing, zero, negative, or one value for an
+// action, requests for the action are evenly spaced at 1/RPS seconds.
+func NewRateLimiter(rps, burst map[CloudWatchLogsAction]int) RateLimiter {
+	rl := &rateLimiter{}
+	for action := CloudWatchLogsAction(0); action < numActions; action++ {
+		r := rps[action]
+		if r <= 0 {
+			r = RPSDefaults[action]
+		}
+		b := burst[action]
+		if b < 1 {
+			b = 1
+		}
+		rl.buckets[action] = bucket{
+			minDelay: time.Second / time.Duration(r),
+			burst:    b,
+			tokens:   float64(b),
+			refill:   time.Now(),
+		}
+	}
+	return rl
+}
+
+type rateLimiter struct {
+	buckets [numActions]bucket
+}
+
+// A bucket is a goroutine-safe token bucket which hands out
+// reservations. A waiter always takes a token, possibly leaving the
+// bucket in debt, and then sleeps until the debt it incurred would be
+// paid off. This keeps waiters in first-come, first-served order.
+type bucket struct {
+	lock     sync.Mutex
+	minDelay time.Duration // Time to refill one token
+	burst    int           // Maximum number of tokens in the bucket
+	tokens   float64       // Tokens in the bucket as of time refill, negative if in debt
+	refill   time.Time     // Time tokens was last brought up to date
+}
+
+func (rl *rateLimiter) Wait(ctx context.Context, action CloudWatchLogsAction) error {
+	if ctx == nil {
+		panic(nilContextMsg)
+	}
+	if action < 0 || action >= numActions {
+		panic(badActionMsg)
+	}
+
+	return rl.buckets[action].wait(ctx)
+}
+
+// wait blocks until the caller may use a token from the bucket, or
+// until ctx is done.
+func (b *bucket) wait(ctx context.Context) error {
+	d := b.reserve()
+	if d <= 0 {
+		return nil
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		b.cancel()
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// reserve takes a token from the bucket and returns how long the
+// caller must wait before using it.
+func (b *bucket) reserve() time.Duration {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	now := time.Now()
+	b.tokens += float64(now.Sub(b.refill)) / float64(b.minDelay)
+	if b.tokens > float64(b.burst) {
+		b.tokens = float64(b.burst)
+	}
+	b.refill = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens * float64(b.minDelay))
+}
+
+// setMinDelay changes the time to refill one token. Tokens refilled at
+// the old rate are kept.
+func (b *bucket) setMinDelay(d time.Duration) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	now := time.Now()
+	b.tokens += float64(now.Sub(b.refill)) / float64(b.minDelay)
+	if b.tokens > float64(b.burst) {
+		b.tokens = float64(b.burst)
+	}
+	b.refill = now
+	b.minDelay = d
+}
+
+// cancel returns a token reserved by a waiter which gave up.
+func (b *bucket) cancel() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.tokens++
+}
+
+// sharedWait waits for the RateLimiter in the owning mgr's Config, if
+// there is one. It returns errClosing if the mgr is closed while
+// waiting.
+func (w *worker) sharedWait(ctx context.Context) error {
+	if w.m.RateLimiter == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-w.close:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	err := w.m.RateLimiter.Wait(ctx, w.action)
+	select {
+	case <-w.close:
+		return errClosing
+	default:
+		return err
+	}
+}
+
+// sharedPace waits for the RateLimiter in the owning mgr's Config, if
+// there is one. Unlike sharedWait, sharedPace ignores the close channel,
+// so it can regulate calls which must still be made while the owning
+// mgr is shutting down.
+func (w *worker) sharedPace() {
+	if w.m.RateLimiter == nil {
+		return
+	}
+
+	_ = w.m.RateLimiter.Wait(context.Background(), w.action)
+}
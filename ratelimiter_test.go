@@ -0,0 +1,237 @@
+// Copyright 2022 The incite Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package incite
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRateLimiter(t *testing.T) {
+	t.Run("Defaults", func(t *testing.T) {
+		rl := NewRateLimiter(nil, nil)
+
+		require.IsType(t, &rateLimiter{}, rl)
+		for action := CloudWatchLogsAction(0); action < numActions; action++ {
+			b := &rl.(*rateLimiter).buckets[action]
+			assert.Equal(t, time.Second/time.Duration(RPSDefaults[action]), b.minDelay)
+			assert.Equal(t, 1, b.burst)
+			assert.Equal(t, 1.0, b.tokens)
+		}
+	})
+
+	t.Run("Explicit", func(t *testing.T) {
+		rl := NewRateLimiter(map[CloudWatchLogsAction]int{
+			StartQuery: 10,
+			StopQuery:  -1,
+		}, map[CloudWatchLogsAction]int{
+			StartQuery:      4,
+			GetQueryResults: -1,
+		})
+
+		require.IsType(t, &rateLimiter{}, rl)
+		buckets := &rl.(*rateLimiter).buckets
+		assert.Equal(t, time.Second/10, buckets[StartQuery].minDelay)
+		assert.Equal(t, 4, buckets[StartQuery].burst)
+		assert.Equal(t, time.Second/time.Duration(RPSDefaults[StopQuery]), buckets[StopQuery].minDelay)
+		assert.Equal(t, 1, buckets[GetQueryResults].burst)
+	})
+}
+
+func TestRateLimiter_Wait(t *testing.T) {
+	t.Run("Invalid Input", func(t *testing.T) {
+		rl := NewRateLimiter(nil, nil)
+
+		assert.PanicsWithValue(t, nilContextMsg, func() {
+			_ = rl.Wait(nil, StartQuery) // nolint:staticcheck
+		})
+		assert.PanicsWithValue(t, badActionMsg, func() {
+			_ = rl.Wait(context.Background(), numActions)
+		})
+	})
+
+	t.Run("Burst Then Steady", func(t *testing.T) {
+		rl := NewRateLimiter(map[CloudWatchLogsAction]int{
+			GetQueryResults: 20,
+		}, map[CloudWatchLogsAction]int{
+			GetQueryResults: 3,
+		})
+
+		before := time.Now()
+		for i := 0; i < 3; i++ {
+			err := rl.Wait(context.Background(), GetQueryResults)
+			require.NoError(t, err)
+		}
+		assert.Less(t, time.Since(before), 50*time.Millisecond, "burst of three must not wait")
+
+		before = time.Now()
+		err := rl.Wait(context.Background(), GetQueryResults)
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, time.Since(before), 25*time.Millisecond, "fourth request must wait for a token")
+	})
+
+	t.Run("Context Ended", func(t *testing.T) {
+		rl := NewRateLimiter(map[CloudWatchLogsAction]int{
+			StopQuery: 1,
+		}, nil)
+		err := rl.Wait(context.Background(), StopQuery)
+		require.NoError(t, err)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		defer cancel()
+
+		err = rl.Wait(ctx, StopQuery)
+
+		assert.Equal(t, context.DeadlineExceeded, err)
+		b := &rl.(*rateLimiter).buckets[StopQuery]
+		assert.Greater(t, b.tokens, -0.5, "cancelled reservation must be returned")
+	})
+
+	t.Run("Actions Are Independent", func(t *testing.T) {
+		rl := NewRateLimiter(map[CloudWatchLogsAction]int{
+			StartQuery: 1,
+		}, nil)
+		err := rl.Wait(context.Background(), StartQuery)
+		require.NoError(t, err)
+
+		before := time.Now()
+		err = rl.Wait(context.Background(), StopQuery)
+
+		assert.NoError(t, err)
+		assert.Less(t, time.Since(before), 50*time.Millisecond)
+	})
+
+	t.Run("Concurrent Waiters Share Rate", func(t *testing.T) {
+		rl := NewRateLimiter(map[CloudWatchLogsAction]int{
+			StartQuery: 100,
+		}, nil)
+		var wg sync.WaitGroup
+		before := time.Now()
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				assert.NoError(t, rl.Wait(context.Background(), StartQuery))
+			}()
+		}
+		wg.Wait()
+
+		assert.GreaterOrEqual(t, time.Since(before), 80*time.Millisecond)
+	})
+}
+
+func TestQueryManager_RateLimiter(t *testing.T) {
+	t.Run("Shared By Two Managers", func(t *testing.T) {
+		rl := &countingRateLimiter{RateLimiter: NewRateLimiter(lotsOfRPS, nil)}
+		var streams []Stream
+		for _, queryID := range []string{"q1", "q2"} {
+			queryID := queryID
+			text := "query " + queryID
+			actions := newMockActions(t)
+			actions.
+				On("StartQueryWithContext", anyContext, startQueryInput(text, defaultStart, defaultEnd, DefaultLimit, "grp")).
+				Return(&cloudwatchlogs.StartQueryOutput{QueryId: &queryID}, nil).
+				Once()
+			actions.
+				On("GetQueryResultsWithContext", anyContext, &cloudwatchlogs.GetQueryResultsInput{QueryId: &queryID}).
+				Return(&cloudwatchlogs.GetQueryResultsOutput{
+					Status: sp(cloudwatchlogs.QueryStatusComplete),
+				}, nil).
+				Once()
+			m := NewQueryManager(Config{
+				Actions:     actions,
+				RPS:         lotsOfRPS,
+				RateLimiter: rl,
+			})
+			t.Cleanup(func() {
+				_ = m.Close()
+			})
+			s, err := m.Query(QuerySpec{
+				Text:   text,
+				Groups: []string{"grp"},
+				Start:  defaultStart,
+				End:    defaultEnd,
+			})
+			require.NoError(t, err)
+			streams = append(streams, s)
+		}
+
+		for _, s := range streams {
+			_, err := ReadAll(s)
+			assert.NoError(t, err)
+		}
+
+		assert.Equal(t, map[CloudWatchLogsAction]int{
+			StartQuery:      2,
+			GetQueryResults: 2,
+		}, rl.counts())
+	})
+
+	t.Run("Close While Waiting", func(t *testing.T) {
+		rl := blockingRateLimiter{}
+		actions := newMockActions(t)
+		m := NewQueryManager(Config{
+			Actions:     actions,
+			RPS:         lotsOfRPS,
+			RateLimiter: rl,
+		})
+		s, err := m.Query(QuerySpec{
+			Text:   "never started",
+			Groups: []string{"grp"},
+			Start:  defaultStart,
+			End:    defaultEnd,
+		})
+		require.NoError(t, err)
+
+		err = m.Close()
+		require.NoError(t, err)
+		_, err = ReadAll(s)
+
+		assert.ErrorIs(t, err, ErrClosed)
+		actions.AssertExpectations(t)
+	})
+}
+
+type countingRateLimiter struct {
+	RateLimiter
+	lock sync.Mutex
+	n    map[CloudWatchLogsAction]int
+}
+
+func (rl *countingRateLimiter) Wait(ctx context.Context, action CloudWatchLogsAction) error {
+	rl.lock.Lock()
+	if rl.n == nil {
+		rl.n = make(map[CloudWatchLogsAction]int)
+	}
+	rl.n[action]++
+	rl.lock.Unlock()
+	return rl.RateLimiter.Wait(ctx, action)
+}
+
+func (rl *countingRateLimiter) counts() map[CloudWatchLogsAction]int {
+	rl.lock.Lock()
+	defer rl.lock.Unlock()
+	return rl.n
+}
+
+type failingRateLimiter struct {
+	err error
+}
+
+func (rl failingRateLimiter) Wait(context.Context, CloudWatchLogsAction) error {
+	return rl.err
+}
+
+type blockingRateLimiter struct{}
+
+func (rl blockingRateLimiter) Wait(ctx context.Context, _ CloudWatchLogsAction) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
@@ -0,0 +1,104 @@
+// Copyright 2022 The incite Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package incite
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+func (m *mgr) SetParallel(n int) error {
+	if n <= 0 {
+		n = belowQuota(m.quota)
+	} else if n > m.quota {
+		n = m.quota
+	}
+
+	select {
+	case m.resize <- n:
+		return nil
+	case <-m.close:
+		return ErrClosed
+	}
+}
+
+func (m *mgr) SetRPS(action CloudWatchLogsAction, rps int) error {
+	if !validAction(action) {
+		return errors.New(badActionMsg)
+	}
+	select {
+	case <-m.close:
+		return ErrClosed
+	default:
+	}
+
+	if rps <= 0 {
+		rps = m.defaultRPS(action)
+	}
+	if action == DescribeLogGroups {
+		m.logEvent("", fmt.Sprintf("reconfiguring DescribeLogGroups RPS to %d", rps))
+		m.groupLimit.setMinDelay(time.Second / time.Duration(rps))
+		return nil
+	}
+	w := m.worker(action)
+	m.logEvent(w.name, fmt.Sprintf("reconfiguring RPS to %d", rps))
+	w.setMinDelay(time.Second / time.Duration(rps))
+	return nil
+}
+
+func (m *mgr) SetLogger(logger Logger) {
+	if logger == nil {
+		logger = NopLogger
+	}
+	m.log.Store(loggerBox{logger})
+}
+
+// worker returns the worker which calls a CloudWatch Logs action other
+// than DescribeLogGroups, which Query calls directly.
+func (m *mgr) worker(action CloudWatchLogsAction) *worker {
+	switch action {
+	case StartQuery:
+		return &m.starter.worker
+	case StopQuery:
+		return &m.stopper.worker
+	case GetQueryResults:
+		return &m.poller.worker
+	default:
+		return &m.hydrator.worker
+	}
+}
+
+// resizeParallel changes the Parallel value of the Config to a value
+// received from SetParallel. Chunks already handed off to workers carry
+// on, but if Parallel decreases, no new chunks are handed off until the
+// number in flight is below the new value.
+func (m *mgr) resizeParallel(n int) {
+	if n == m.Parallel {
+		return
+	}
+	m.logEvent("", fmt.Sprintf("reconfiguring Parallel from %d to %d", m.Parallel, n))
+	m.Parallel = n
+	if !m.AdaptiveParallel {
+		m.parallel = n
+	} else if m.parallel > n {
+		m.setParallel(n)
+	}
+}
+
+// A loggerBox wraps a Logger so that Loggers of different concrete
+// types may be stored in the same atomic.Value.
+type loggerBox struct {
+	Logger
+}
+
+// logger returns the Logger most recently set by SetLogger, or the
+// Logger in Config if SetLogger was never called.
+func (m *mgr) logger() Logger {
+	if box, ok := m.log.Load().(loggerBox); ok {
+		return box.Logger
+	}
+	return m.Logger
+}
@@ -0,0 +1,222 @@
+// Copyright 2022 The incite Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package incite
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryManager_SetParallel(t *testing.T) {
+	m := NewQueryManager(Config{
+		Actions:               newMockActions(t),
+		Parallel:              2,
+		QueryConcurrencyQuota: 20,
+	})
+	m2 := m.(*mgr)
+	t.Cleanup(func() {
+		_ = m.Close()
+	})
+	// Sending the same value twice guarantees the mgr loop has finished
+	// applying it when the second call returns.
+	setParallel := func(n int) {
+		require.NoError(t, m.(Reconfigurer).SetParallel(n))
+		require.NoError(t, m.(Reconfigurer).SetParallel(n))
+	}
+
+	t.Run("Increase", func(t *testing.T) {
+		setParallel(15)
+
+		assert.Equal(t, 15, m2.Parallel)
+		assert.Equal(t, 15, m2.parallel)
+	})
+
+	t.Run("Decrease", func(t *testing.T) {
+		setParallel(3)
+
+		assert.Equal(t, 3, m2.Parallel)
+		assert.Equal(t, 3, m2.parallel)
+	})
+
+	t.Run("Capped at Quota", func(t *testing.T) {
+		setParallel(1000)
+
+		assert.Equal(t, 20, m2.Parallel)
+	})
+
+	t.Run("Default", func(t *testing.T) {
+		setParallel(0)
+
+		assert.Equal(t, belowQuota(20), m2.Parallel)
+	})
+
+	t.Run("Closed", func(t *testing.T) {
+		_ = m.Close()
+
+		assert.Same(t, ErrClosed, m.(Reconfigurer).SetParallel(1))
+	})
+}
+
+func TestMgr_resizeParallel(t *testing.T) {
+	t.Run("Adaptive Below New Value", func(t *testing.T) {
+		m := &mgr{
+			Config: Config{
+				Parallel:         8,
+				AdaptiveParallel: true,
+				Logger:           NopLogger,
+			},
+			parallel: 2,
+		}
+
+		m.resizeParallel(10)
+
+		assert.Equal(t, 10, m.Parallel)
+		assert.Equal(t, 2, m.parallel, "adaptive parallel must grow on its own")
+	})
+
+	t.Run("Adaptive Above New Value", func(t *testing.T) {
+		m := &mgr{
+			Config: Config{
+				Parallel:         8,
+				AdaptiveParallel: true,
+				Logger:           NopLogger,
+			},
+			parallel: 8,
+		}
+
+		m.resizeParallel(4)
+
+		assert.Equal(t, 4, m.Parallel)
+		assert.Equal(t, 4, m.parallel)
+		assert.Equal(t, 4, m.GetStats().Parallel)
+	})
+}
+
+func TestQueryManager_SetRPS(t *testing.T) {
+	m := NewQueryManager(Config{
+		Actions: newMockActions(t),
+	})
+	m2 := m.(*mgr)
+	t.Cleanup(func() {
+		_ = m.Close()
+	})
+
+	t.Run("Explicit", func(t *testing.T) {
+		require.NoError(t, m.(Reconfigurer).SetRPS(StartQuery, 4))
+
+		assert.Equal(t, time.Second/4, <-m2.starter.rate)
+	})
+
+	t.Run("Default", func(t *testing.T) {
+		require.NoError(t, m.(Reconfigurer).SetRPS(GetQueryResults, 0))
+
+		assert.Equal(t, time.Second/time.Duration(m2.defaultRPS(GetQueryResults)), <-m2.poller.rate)
+	})
+
+	t.Run("Latest Value Wins", func(t *testing.T) {
+		require.NoError(t, m.(Reconfigurer).SetRPS(StopQuery, 1))
+		require.NoError(t, m.(Reconfigurer).SetRPS(StopQuery, 2))
+
+		assert.Equal(t, time.Second/2, <-m2.stopper.rate)
+	})
+
+	t.Run("DescribeLogGroups", func(t *testing.T) {
+		require.NoError(t, m.(Reconfigurer).SetRPS(DescribeLogGroups, 4))
+
+		m2.groupLimit.lock.Lock()
+		defer m2.groupLimit.lock.Unlock()
+		assert.Equal(t, time.Second/4, m2.groupLimit.minDelay)
+	})
+
+	t.Run("Bad Action", func(t *testing.T) {
+		for _, action := range []CloudWatchLogsAction{-1, numActions} {
+			err := m.(Reconfigurer).SetRPS(action, 1)
+
+			assert.EqualError(t, err, badActionMsg)
+		}
+	})
+
+	t.Run("Above Quota", func(t *testing.T) {
+		require.NoError(t, m.(Reconfigurer).SetRPS(StartQuery, RPSQuotaLimits[StartQuery]+1))
+
+		assert.Equal(t, time.Second/time.Duration(RPSQuotaLimits[StartQuery]+1), <-m2.starter.rate)
+	})
+
+	t.Run("Closed", func(t *testing.T) {
+		_ = m.Close()
+
+		assert.Same(t, ErrClosed, m.(Reconfigurer).SetRPS(GetLogRecord, 1))
+	})
+}
+
+func TestRegulator_setMinDelay(t *testing.T) {
+	t.Run("Without Burst", func(t *testing.T) {
+		r := makeRegulator(make(chan struct{}), 1000, 0)
+		r.setMinDelay(time.Second)
+
+		require.NoError(t, r.wait(context.Background()))
+
+		assert.Equal(t, time.Second, r.minDelay)
+	})
+
+	t.Run("With Burst", func(t *testing.T) {
+		r := makeBurstRegulator(make(chan struct{}), 1000, 0, 3, 0)
+		r.setMinDelay(time.Second)
+
+		require.NoError(t, r.wait(context.Background()))
+
+		assert.Equal(t, time.Second, r.minDelay)
+		assert.InDelta(t, 2, r.tokens, 0.01, "tokens already in bucket must be kept")
+	})
+
+	t.Run("Burst Resized", func(t *testing.T) {
+		r := makeBurstRegulator(make(chan struct{}), 2, 0, 10, 10)
+		require.Equal(t, 8, r.burst)
+		r.setMinDelay(time.Second / 8)
+
+		require.NoError(t, r.wait(context.Background()))
+
+		assert.Equal(t, 2, r.burst)
+		assert.LessOrEqual(t, r.tokens, 2.0)
+	})
+
+	t.Run("Burst Disabled", func(t *testing.T) {
+		r := makeBurstRegulator(make(chan struct{}), 2, 0, 10, 10)
+		r.setMinDelay(time.Second / 9)
+
+		require.NoError(t, r.wait(context.Background()))
+
+		assert.Equal(t, 0, r.burst)
+		assert.Equal(t, 0.0, r.tokens)
+	})
+}
+
+func TestQueryManager_SetLogger(t *testing.T) {
+	before := newMockLogger(t)
+	before.On("Printf", mock.Anything, mock.Anything).Return()
+	m := NewQueryManager(Config{
+		Actions: newMockActions(t),
+		Logger:  before,
+		Name:    "SetLogger",
+	})
+	after := newMockLogger(t)
+	after.ExpectPrintf("incite: QueryManager(%s) %s", "SetLogger", "stopping...").Once()
+	after.ExpectPrintf("incite: QueryManager(%s) %s", "SetLogger", "stopped").Once()
+	after.On("Printf", mock.Anything, mock.Anything).Return()
+
+	m.(Reconfigurer).SetLogger(after)
+	err := m.Close()
+	time.Sleep(100 * time.Millisecond) // Give the mgr loop time to log its final stop event.
+
+	assert.NoError(t, err)
+	after.AssertExpectations(t)
+	m.(Reconfigurer).SetLogger(nil)
+	assert.Equal(t, NopLogger, m.(*mgr).logger())
+}
@@ -13,27 +13,60 @@ import (
 // happen. It is a component of a worker and is used to limit the rate
 // at which the worker manipulates chunks flowing into the worker. This
 // helps the worker stay under CloudWatch Logs API RPS limits.
+//
+// By default, a regulator enforces a strict minimum delay between
+// consecutive events. If the regulator's burst size is more than one,
+// it instead acts as a token bucket: the bucket holds up to burst
+// tokens, refills at one token per minimum delay, and each event takes
+// one token. This allows a short burst of events after an idle period
+// while keeping the same long-run rate. Because the bucket keeps
+// refilling during a burst, a full bucket followed by steady events
+// allows burst+RPS events in one second, so the bucket size is capped
+// at quota-RPS to keep every one-second window within the RPS quota.
 type regulator struct {
-	close    <-chan struct{} // Short-circuits a wait when owning mgr is closed
-	minDelay time.Duration   // Minimum delay enforced by wait between consecutive events
-	lastReq  time.Time       // Time of last event
-	timer    *time.Timer     // Timer for rate limiting
-	ding     bool            // Flag indicating whether timer channel has been read
+	close    <-chan struct{}    // Short-circuits a wait when owning mgr is closed
+	minDelay time.Duration      // Minimum delay enforced by wait between consecutive events
+	lastReq  time.Time          // Time of last event
+	timer    *time.Timer        // Timer for rate limiting
+	ding     bool               // Flag indicating whether timer channel has been read
+	penalty  time.Duration      // Extra delay between events while backing off, token bucket not used if positive
+	burst    int                // Token bucket size, token bucket not used if one or less
+	maxBurst int                // Requested token bucket size, before capping to stay within quota
+	quota    int                // RPS quota which burst plus RPS may not exceed, no cap if zero or less
+	tokens   float64            // Tokens in the bucket as of time refill
+	refill   time.Time          // Time tokens was last brought up to date
+	rate     chan time.Duration // Receives a new minimum delay from any goroutine
 }
 
 func makeRegulator(close <-chan struct{}, rps, defaultRPS int) regulator {
+	return makeBurstRegulator(close, rps, defaultRPS, 1, 0)
+}
+
+func makeBurstRegulator(close <-chan struct{}, rps, defaultRPS, burst, quota int) regulator {
 	if rps <= 0 {
 		rps = defaultRPS
 	}
-	return regulator{
+	r := regulator{
 		close:    close,
 		minDelay: time.Second / time.Duration(rps),
 		timer:    time.NewTimer(1<<63 - 1),
+		rate:     make(chan time.Duration, 1),
+		maxBurst: burst,
+		quota:    quota,
 	}
+	r.resizeBucket()
+	r.tokens = float64(r.burst)
+	return r
 }
 
 func (r *regulator) wait(ctx context.Context) error {
-	if !r.setTimerRPS() {
+	select {
+	case d := <-r.rate:
+		r.changeMinDelay(d)
+	default:
+	}
+
+	if !r.setTimerNext() {
 		return nil
 	}
 
@@ -43,11 +76,89 @@ func (r *regulator) wait(ctx context.Context) error {
 	case <-ctx.Done():
 		return ctx.Err()
 	case <-r.timer.C:
-		r.ding = true
+		r.fired()
 		return nil
 	}
 }
 
+// pace blocks until the regulator allows the next event. Unlike wait,
+// pace ignores the close channel, so it can regulate events which must
+// still happen while the owning mgr is shutting down.
+func (r *regulator) pace() {
+	if r.setTimerNext() {
+		<-r.timer.C
+		r.fired()
+	}
+}
+
+// setTimerNext sets the timer for the next event, using the token
+// bucket unless it is disabled or backing off, and reports whether the
+// caller needs to wait for the timer.
+func (r *regulator) setTimerNext() bool {
+	if r.burst > 1 && r.penalty <= 0 {
+		return r.setTimerTokens()
+	}
+	return r.setTimerRPS()
+}
+
+// fired records that the timer set by setTimerNext has fired.
+func (r *regulator) fired() {
+	r.ding = true
+	if r.burst > 1 && r.penalty <= 0 {
+		r.takeToken()
+	}
+}
+
+// setMinDelay asks the regulator to enforce a new minimum delay, which
+// takes effect from its next wait. Unlike the other regulator methods,
+// setMinDelay may be called from any goroutine.
+func (r *regulator) setMinDelay(d time.Duration) {
+	for {
+		select {
+		case r.rate <- d:
+			return
+		case <-r.rate:
+			// Discard a change not yet picked up.
+		}
+	}
+}
+
+// changeMinDelay switches to a new minimum delay received from
+// setMinDelay. Tokens accumulated so far are kept, up to the bucket
+// size allowed at the new rate.
+func (r *regulator) changeMinDelay(d time.Duration) {
+	if r.burst > 1 {
+		r.refillTokens()
+	}
+	r.minDelay = d
+	r.resizeBucket()
+}
+
+// resizeBucket sets the token bucket size to the requested burst size,
+// capped so that a full bucket plus one second of refills at the
+// current minimum delay does not exceed the RPS quota. A bucket which
+// is newly enabled starts out empty.
+func (r *regulator) resizeBucket() {
+	burst := r.maxBurst
+	if r.quota > 0 {
+		if room := r.quota - int(time.Second/r.minDelay); burst > room {
+			burst = room
+		}
+	}
+	if burst <= 1 {
+		r.burst = 0
+		r.tokens = 0
+		return
+	}
+	if r.burst <= 1 {
+		r.refill = time.Now()
+	}
+	r.burst = burst
+	if r.tokens > float64(burst) {
+		r.tokens = float64(burst)
+	}
+}
+
 func (r *regulator) setTimer(d time.Duration) bool {
 	if !r.ding && !r.timer.Stop() {
 		<-r.timer.C
@@ -66,9 +177,35 @@ func (r *regulator) setTimer(d time.Duration) bool {
 
 func (r *regulator) setTimerRPS() bool {
 	delaySoFar := time.Since(r.lastReq)
-	delayRem := r.minDelay - delaySoFar
+	delayRem := r.minDelay + r.penalty - delaySoFar
 	if delayRem <= 0 {
 		return false
 	}
 	return r.setTimer(delayRem)
 }
+
+func (r *regulator) setTimerTokens() bool {
+	r.refillTokens()
+	if r.tokens >= 1 {
+		r.tokens--
+		return false
+	}
+	return r.setTimer(time.Duration((1 - r.tokens) * float64(r.minDelay)))
+}
+
+func (r *regulator) refillTokens() {
+	now := time.Now()
+	r.tokens += float64(now.Sub(r.refill)) / float64(r.minDelay)
+	if r.tokens > float64(r.burst) {
+		r.tokens = float64(r.burst)
+	}
+	r.refill = now
+}
+
+func (r *regulator) takeToken() {
+	r.refillTokens()
+	r.tokens--
+	if r.tokens < 0 {
+		r.tokens = 0
+	}
+}
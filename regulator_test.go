@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestMakeRegulator(t *testing.T) {
@@ -59,6 +60,45 @@ func TestMakeRegulator(t *testing.T) {
 	}
 }
 
+func TestMakeBurstRegulator(t *testing.T) {
+	t.Run("No Burst", func(t *testing.T) {
+		for _, burst := range []int{-1, 0, 1} {
+			r := makeBurstRegulator(nil, 2, 0, burst, 0)
+			r.timer.Stop()
+
+			assert.Equal(t, time.Second/2, r.minDelay)
+			assert.Equal(t, 0, r.burst)
+			assert.Equal(t, 0.0, r.tokens)
+		}
+	})
+
+	t.Run("Burst", func(t *testing.T) {
+		r := makeBurstRegulator(nil, 0, 4, 3, 0)
+		r.timer.Stop()
+
+		assert.Equal(t, time.Second/4, r.minDelay)
+		assert.Equal(t, 3, r.burst)
+		assert.Equal(t, 3.0, r.tokens)
+		assert.False(t, r.refill.IsZero())
+	})
+
+	t.Run("Burst Capped at Quota Less RPS", func(t *testing.T) {
+		r := makeBurstRegulator(nil, 4, 0, 10, 10)
+		r.timer.Stop()
+
+		assert.Equal(t, 6, r.burst)
+		assert.Equal(t, 6.0, r.tokens)
+	})
+
+	t.Run("No Room to Burst", func(t *testing.T) {
+		r := makeBurstRegulator(nil, 4, 0, 10, 5)
+		r.timer.Stop()
+
+		assert.Equal(t, 0, r.burst)
+		assert.Equal(t, 0.0, r.tokens)
+	})
+}
+
 func TestRegulator_Wait(t *testing.T) {
 	t.Run("Already Ready", func(t *testing.T) {
 		r := makeRegulator(nil, 1, 0)
@@ -117,6 +157,81 @@ func TestRegulator_Wait(t *testing.T) {
 	})
 }
 
+func TestRegulator_Wait_Burst(t *testing.T) {
+	t.Run("Burst Then Steady", func(t *testing.T) {
+		r := makeBurstRegulator(nil, 20, 0, 3, 0)
+		t.Cleanup(func() {
+			r.timer.Stop()
+		})
+
+		before := time.Now()
+		for i := 0; i < 3; i++ {
+			err := r.wait(context.Background())
+			assert.NoError(t, err)
+			r.lastReq = time.Now()
+		}
+		assert.Less(t, time.Since(before), r.minDelay, "burst of three must not wait")
+
+		before = time.Now()
+		err := r.wait(context.Background())
+		assert.NoError(t, err)
+		assert.GreaterOrEqual(t, time.Since(before), r.minDelay/2, "fourth event must wait for a token")
+		assert.Less(t, r.tokens, 1.0)
+	})
+
+	t.Run("Calls Per Window Within Quota", func(t *testing.T) {
+		const quota = 10
+		r := makeBurstRegulator(nil, 5, 0, quota, quota)
+		t.Cleanup(func() {
+			r.timer.Stop()
+		})
+
+		var calls []time.Time
+		end := time.Now().Add(1500 * time.Millisecond)
+		for time.Now().Before(end) {
+			err := r.wait(context.Background())
+			require.NoError(t, err)
+			calls = append(calls, time.Now())
+			r.lastReq = calls[len(calls)-1]
+		}
+
+		for i := range calls {
+			n := 0
+			for j := i; j < len(calls) && calls[j].Sub(calls[i]) < time.Second; j++ {
+				n++
+			}
+			assert.LessOrEqual(t, n, quota, "calls in the one-second window starting with call %d", i)
+		}
+	})
+
+	t.Run("Refill Capped at Burst", func(t *testing.T) {
+		r := makeBurstRegulator(nil, 1_000, 0, 2, 0)
+		t.Cleanup(func() {
+			r.timer.Stop()
+		})
+		r.refill = time.Now().Add(-time.Hour)
+
+		r.refillTokens()
+
+		assert.Equal(t, 2.0, r.tokens)
+	})
+
+	t.Run("Context Ended", func(t *testing.T) {
+		r := makeBurstRegulator(nil, 1, 0, 2, 0)
+		t.Cleanup(func() {
+			r.timer.Stop()
+		})
+		r.tokens = 0
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		defer cancel()
+
+		err := r.wait(ctx)
+
+		assert.Equal(t, context.DeadlineExceeded, err)
+		assert.Less(t, r.tokens, 1.0)
+	})
+}
+
 func TestRegulator_SetTimer(t *testing.T) {
 	r := makeRegulator(nil, 1, 0)
 	t.Cleanup(func() {
@@ -0,0 +1,117 @@
+// Copyright 2022 The incite Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package incite
+
+import (
+	"errors"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy decides whether, and after how long, the QueryManager
+// retries a failed CloudWatch Logs request.
+//
+// By default, a QueryManager uses DefaultRetryPolicy. To customize the
+// retry behavior, for example to treat some errors as fatal or to keep
+// retrying others indefinitely, set the RetryPolicy field of Config.
+// A custom policy can delegate to DefaultRetryPolicy for the cases it
+// does not handle itself:
+//
+//	policy := incite.RetryPolicyFunc(func(action incite.CloudWatchLogsAction, err error, n int) (bool, time.Duration) {
+//		var x awserr.Error
+//		if errors.As(err, &x) {
+//			switch x.Code() {
+//			case "AccessDeniedException":
+//				return false, 0
+//			case "InternalFailure":
+//				return true, time.Second
+//			}
+//		}
+//		return incite.DefaultRetryPolicy.Retry(action, err, n)
+//	})
+//
+// Implementations must be safe for concurrent use by multiple
+// goroutines.
+type RetryPolicy interface {
+	// Retry is called when a request for the given CloudWatch Logs
+	// action fails with error err for the nth consecutive time, where
+	// n starts at one. It returns true if the request should be
+	// retried, together with the minimum delay before the retry.
+	//
+	// Retry is also called when a CloudWatch Logs Insights query ends
+	// in the "Failed" status. In this case, action is StartQuery, err
+	// is a *TerminalQueryStatusError, n is the number of times the
+	// query has failed, and returning true restarts the query. The
+	// same applies, with err a *DeadlineError, when a query is stopped
+	// because it exceeded the ChunkTimeout of its QuerySpec. The delay
+	// is ignored for restarts.
+	Retry(action CloudWatchLogsAction, err error, n int) (bool, time.Duration)
+}
+
+// RetryPolicyFunc is an adapter which allows an ordinary function to be
+// used as a RetryPolicy.
+type RetryPolicyFunc func(action CloudWatchLogsAction, err error, n int) (bool, time.Duration)
+
+// Retry returns f(action, err, n).
+func (f RetryPolicyFunc) Retry(action CloudWatchLogsAction, err error, n int) (bool, time.Duration) {
+	return f(action, err, n)
+}
+
+// DefaultRetryPolicy is the RetryPolicy used by a QueryManager whose
+// Config does not specify one.
+//
+// DefaultRetryPolicy retries errors which are likely to be temporary,
+// such as throttling, service unavailability, and network timeouts, up
+// to a fixed number of tries per action. It restarts queries which end
+// in the "Failed" status, or exceed their ChunkTimeout, up to two times.
+// It never requests a delay, so retries are only delayed by the RPS
+// limits and the Backoff field of Config.
+var DefaultRetryPolicy RetryPolicy = defaultRetryPolicy{}
+
+// maxTempErrs is the maximum number of tries DefaultRetryPolicy makes
+// for each CloudWatch Logs action when the request keeps failing with a
+// temporary error.
+var maxTempErrs = [numActions]int{
+	StartQuery:      maxTempStartingErrs,
+	StopQuery:       maxTempStoppingErrs,
+	GetQueryResults: maxTempPollingErrs,
+	GetLogRecord:    maxTempHydratingErrs,
+}
+
+type defaultRetryPolicy struct{}
+
+func (p defaultRetryPolicy) Retry(action CloudWatchLogsAction, err error, n int) (bool, time.Duration) {
+	var terminal *TerminalQueryStatusError
+	var deadline *DeadlineError
+	if errors.As(err, &terminal) || errors.As(err, &deadline) {
+		return n <= maxRestart, 0
+	}
+	if action < 0 || action >= numActions || !isTemporary(err) {
+		return false, 0
+	}
+	return n < maxTempErrs[action], 0
+}
+
+func (m *mgr) retryPolicy() RetryPolicy {
+	if m.RetryPolicy == nil {
+		return DefaultRetryPolicy
+	}
+	return m.RetryPolicy
+}
+
+// retry consults the RetryPolicy about the failure of the worker's
+// request for chunk c with error err. If the request should be retried,
+// retry records the delay chosen by the policy in c and returns true.
+func (w *worker) retry(c *chunk, err error) bool {
+	ok, delay := w.m.retryPolicy().Retry(w.action, err, c.tmp+1)
+	if !ok {
+		if c.tmp > 0 {
+			w.m.logChunk(c, w.name+" stopped retrying", "after "+strconv.Itoa(c.tmp+1)+" tries")
+		}
+		return false
+	}
+	c.delay = delay
+	return true
+}
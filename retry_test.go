@@ -0,0 +1,204 @@
+// Copyright 2022 The incite Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package incite
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultRetryPolicy(t *testing.T) {
+	temporary := cwlErr(cloudwatchlogs.ErrCodeServiceUnavailableException, "try later")
+	permanent := cwlErr(cloudwatchlogs.ErrCodeInvalidParameterException, "no way")
+	failed := &TerminalQueryStatusError{"q", cloudwatchlogs.QueryStatusFailed, "text"}
+
+	testCases := []struct {
+		name   string
+		action CloudWatchLogsAction
+		err    error
+		n      int
+		retry  bool
+	}{
+		{"StartQuery Temporary First", StartQuery, temporary, 1, true},
+		{"StartQuery Temporary Last", StartQuery, temporary, maxTempStartingErrs - 1, true},
+		{"StartQuery Temporary Exceeded", StartQuery, temporary, maxTempStartingErrs, false},
+		{"StopQuery Temporary Last", StopQuery, temporary, 2, true},
+		{"StopQuery Temporary Exceeded", StopQuery, temporary, 3, false},
+		{"GetQueryResults Temporary Exceeded", GetQueryResults, syscall.ECONNRESET, maxTempPollingErrs, false},
+		{"GetLogRecord Temporary", GetLogRecord, syscall.ECONNRESET, 1, true},
+		{"Permanent", StartQuery, permanent, 1, false},
+		{"Unknown Action", numActions, temporary, 1, false},
+		{"Restart First", StartQuery, failed, 1, true},
+		{"Restart Last", StartQuery, failed, maxRestart, true},
+		{"Restart Exceeded", StartQuery, failed, maxRestart + 1, false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			retry, delay := DefaultRetryPolicy.Retry(testCase.action, testCase.err, testCase.n)
+
+			assert.Equal(t, testCase.retry, retry)
+			assert.Equal(t, time.Duration(0), delay)
+		})
+	}
+}
+
+func TestRetryPolicyFunc(t *testing.T) {
+	var actualAction CloudWatchLogsAction
+	var actualErr error
+	var actualN int
+	f := RetryPolicyFunc(func(action CloudWatchLogsAction, err error, n int) (bool, time.Duration) {
+		actualAction, actualErr, actualN = action, err, n
+		return true, time.Minute
+	})
+	err := errors.New("foo")
+
+	retry, delay := f.Retry(GetLogRecord, err, 3)
+
+	assert.True(t, retry)
+	assert.Equal(t, time.Minute, delay)
+	assert.Equal(t, GetLogRecord, actualAction)
+	assert.Same(t, err, actualErr)
+	assert.Equal(t, 3, actualN)
+}
+
+func TestWorker_retry(t *testing.T) {
+	t.Run("Retry With Delay", func(t *testing.T) {
+		w := &worker{
+			m: &mgr{
+				Config: Config{
+					RetryPolicy: RetryPolicyFunc(func(action CloudWatchLogsAction, _ error, n int) (bool, time.Duration) {
+						assert.Equal(t, StopQuery, action)
+						assert.Equal(t, 3, n)
+						return true, time.Hour
+					}),
+				},
+			},
+			action: StopQuery,
+		}
+		c := &chunk{tmp: 2}
+
+		assert.True(t, w.retry(c, errors.New("foo")))
+		assert.Equal(t, time.Hour, c.delay)
+
+		w.handle(manipulation{c, temporaryError})
+
+		assert.Equal(t, 3, c.tmp)
+		assert.False(t, c.due.Before(time.Now().Add(59*time.Minute)))
+		assert.Equal(t, 1, w.numChunks)
+	})
+
+	t.Run("Give Up", func(t *testing.T) {
+		logger := newMockLogger(t)
+		logger.ExpectPrintf("incite: QueryManager(%s) %s chunk %s %q [%s..%s): %s", "", "test stopped retrying", "c", "", mock.Anything, mock.Anything, "after 2 tries").Once()
+		w := &worker{
+			m: &mgr{
+				Config: Config{
+					Logger: logger,
+				},
+			},
+			name:   "test",
+			action: StartQuery,
+		}
+		c := &chunk{chunkID: "c", stream: &stream{}, tmp: 1}
+
+		assert.False(t, w.retry(c, errors.New("not temporary")))
+		logger.AssertExpectations(t)
+	})
+}
+
+func TestQueryManager_RetryPolicy(t *testing.T) {
+	policy := RetryPolicyFunc(func(action CloudWatchLogsAction, err error, n int) (bool, time.Duration) {
+		var x awserr.Error
+		if errors.As(err, &x) {
+			switch x.Code() {
+			case "AccessDeniedException":
+				return false, 0
+			case "InternalFailure":
+				return true, 0
+			}
+		}
+		return DefaultRetryPolicy.Retry(action, err, n)
+	})
+
+	t.Run("Retry Indefinitely", func(t *testing.T) {
+		text := "retry indefinitely"
+		queryID := "q"
+		n := maxTempStartingErrs + 5
+		actions := newMockActions(t)
+		actions.
+			On("StartQueryWithContext", anyContext, startQueryInput(text, defaultStart, defaultEnd, DefaultLimit, "grp")).
+			Return(nil, cwlErr("InternalFailure", "oops")).
+			Times(n)
+		actions.
+			On("StartQueryWithContext", anyContext, startQueryInput(text, defaultStart, defaultEnd, DefaultLimit, "grp")).
+			Return(&cloudwatchlogs.StartQueryOutput{QueryId: &queryID}, nil).
+			Once()
+		actions.
+			On("GetQueryResultsWithContext", anyContext, &cloudwatchlogs.GetQueryResultsInput{QueryId: &queryID}).
+			Return(&cloudwatchlogs.GetQueryResultsOutput{
+				Status: sp(cloudwatchlogs.QueryStatusComplete),
+			}, nil).
+			Once()
+		m := NewQueryManager(Config{
+			Actions:     actions,
+			RPS:         lotsOfRPS,
+			RetryPolicy: policy,
+		})
+		t.Cleanup(func() {
+			_ = m.Close()
+		})
+		s, err := m.Query(QuerySpec{
+			Text:   text,
+			Groups: []string{"grp"},
+			Start:  defaultStart,
+			End:    defaultEnd,
+		})
+		require.NoError(t, err)
+
+		_, err = ReadAll(s)
+
+		assert.NoError(t, err)
+		actions.AssertExpectations(t)
+	})
+
+	t.Run("Fatal Immediately", func(t *testing.T) {
+		text := "fatal immediately"
+		actions := newMockActions(t)
+		actions.
+			On("StartQueryWithContext", anyContext, startQueryInput(text, defaultStart, defaultEnd, DefaultLimit, "grp")).
+			Return(nil, awserr.NewRequestFailure(awserr.New("AccessDeniedException", "go away", nil), 503, "req")).
+			Once()
+		m := NewQueryManager(Config{
+			Actions:     actions,
+			RPS:         lotsOfRPS,
+			RetryPolicy: policy,
+		})
+		t.Cleanup(func() {
+			_ = m.Close()
+		})
+		s, err := m.Query(QuerySpec{
+			Text:   text,
+			Groups: []string{"grp"},
+			Start:  defaultStart,
+			End:    defaultEnd,
+		})
+		require.NoError(t, err)
+
+		_, err = ReadAll(s)
+
+		var sqe *StartQueryError
+		assert.ErrorAs(t, err, &sqe)
+		actions.AssertExpectations(t)
+	})
+}
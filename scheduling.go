@@ -0,0 +1,96 @@
+// Copyright 2022 The incite Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package incite
+
+import (
+	"container/heap"
+	"math"
+	"time"
+)
+
+// SchedulingPolicy selects how a QueryManager shares its query
+// capacity between streams which have chunks waiting to start.
+type SchedulingPolicy int
+
+const (
+	// StrictPriority always starts the next chunk of the waiting
+	// stream with the lowest Priority number. Among streams with the
+	// same Priority, the stream which has started the fewest chunks
+	// goes first. If the PriorityAging field of Config is positive, a
+	// waiting stream's effective Priority number decreases as it waits,
+	// so that low-priority streams are not starved by a continuous flow
+	// of high-priority streams.
+	//
+	// StrictPriority is the default policy.
+	StrictPriority SchedulingPolicy = iota
+	// WeightedFairShare shares query capacity between waiting streams
+	// in proportion to a weight derived from their Priority: a stream
+	// receives twice the share of a stream whose Priority number is one
+	// higher. Every waiting stream makes progress, however low its
+	// priority.
+	WeightedFairShare
+	// RoundRobin starts one chunk from each waiting stream in turn,
+	// ignoring Priority.
+	RoundRobin
+	// numSchedulingPolicies is the number of valid scheduling
+	// policies.
+	numSchedulingPolicies
+)
+
+// maxWeightShift bounds the Priority numbers used to compute weights
+// under WeightedFairShare, so that weights stay finite and non-zero.
+const maxWeightShift = 30
+
+// priorityWeight returns the WeightedFairShare weight of a Priority.
+func priorityWeight(priority int) float64 {
+	if priority > maxWeightShift {
+		priority = maxWeightShift
+	} else if priority < -maxWeightShift {
+		priority = -maxWeightShift
+	}
+	return math.Ldexp(1, -priority)
+}
+
+// pushStream adds a stream with chunks waiting to start to its
+// tenant's stream heap, ranking it according to the scheduling policy.
+func (m *mgr) pushStream(s *stream) {
+	t := m.tenantOf(s)
+	m.seq++
+	s.seq = m.seq
+	switch m.Scheduling {
+	case WeightedFairShare:
+		// A stream which was idle may not bank credit while idle.
+		if s.vtime < t.vtime {
+			s.vtime = t.vtime
+		}
+		s.rank = s.vtime
+	case RoundRobin:
+		s.rank = float64(s.seq)
+	default:
+		s.rank = float64(s.Priority)
+		if m.PriorityAging > 0 {
+			// Improving every waiting stream's effective priority by
+			// one per PriorityAging interval orders the streams in the
+			// same way as worsening each stream's rank by its push time
+			// in units of PriorityAging, which does not change as time
+			// passes.
+			s.rank += float64(time.Since(m.epoch)) / float64(m.PriorityAging)
+		}
+	}
+	s.queued = true
+	heap.Push(&t.pq, s)
+}
+
+// popStream removes the next stream to start a chunk from a tenant's
+// stream heap.
+func (m *mgr) popStream(t *tenant) *stream {
+	s := heap.Pop(&t.pq).(*stream)
+	s.queued = false
+	if m.Scheduling == WeightedFairShare {
+		t.vtime = s.vtime
+		s.vtime += 1 / priorityWeight(s.Priority)
+	}
+	return s
+}
@@ -0,0 +1,161 @@
+// Copyright 2022 The incite Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package incite
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPriorityWeight(t *testing.T) {
+	assert.Equal(t, 1.0, priorityWeight(0))
+	assert.Equal(t, 0.5, priorityWeight(1))
+	assert.Equal(t, 4.0, priorityWeight(-2))
+	assert.Equal(t, priorityWeight(maxWeightShift), priorityWeight(1_000_000))
+	assert.Equal(t, priorityWeight(-maxWeightShift), priorityWeight(-1_000_000))
+}
+
+func TestMgr_Scheduling(t *testing.T) {
+	t.Run("Invalid Policy", func(t *testing.T) {
+		assert.PanicsWithValue(t, badSchedulingPolicyMsg, func() {
+			NewQueryManager(Config{
+				Actions:    newMockActions(t),
+				Scheduling: numSchedulingPolicies,
+			})
+		})
+		assert.PanicsWithValue(t, badSchedulingPolicyMsg, func() {
+			NewQueryManager(Config{
+				Actions:    newMockActions(t),
+				Scheduling: -1,
+			})
+		})
+	})
+
+	t.Run("StrictPriority", func(t *testing.T) {
+		m, streams := newTestableScheduler(StrictPriority, 0)
+
+		order := popSchedule(m, streams, 6)
+
+		assert.Equal(t, "aaaaaa", order)
+	})
+
+	t.Run("StrictPriority Ties", func(t *testing.T) {
+		m := &mgr{Config: Config{Scheduling: StrictPriority}, epoch: time.Now()}
+		streams := map[*stream]string{
+			{QuerySpec: QuerySpec{Priority: 1}}: "a",
+			{QuerySpec: QuerySpec{Priority: 1}}: "b",
+		}
+		for s := range streams {
+			m.pushStream(s)
+		}
+
+		order := popSchedule(m, streams, 4)
+
+		assert.Contains(t, []string{"abab", "baba"}, order)
+	})
+
+	t.Run("StrictPriority With Aging", func(t *testing.T) {
+		m := &mgr{
+			Config: Config{
+				Scheduling:    StrictPriority,
+				PriorityAging: time.Millisecond,
+			},
+			epoch: time.Now(),
+		}
+		low := &stream{QuerySpec: QuerySpec{Priority: 3}}
+		m.pushStream(low)
+		m.epoch = m.epoch.Add(-10 * time.Millisecond) // Simulate the passing of time.
+		high := &stream{QuerySpec: QuerySpec{Priority: 0}}
+		m.pushStream(high)
+
+		assert.Same(t, low, m.popStream(m.tenants[""]), "low-priority stream waited long enough to go first")
+		m.pushStream(low)
+		assert.Same(t, high, m.popStream(m.tenants[""]))
+	})
+
+	t.Run("WeightedFairShare", func(t *testing.T) {
+		m, streams := newTestableScheduler(WeightedFairShare, 0)
+
+		order := popSchedule(m, streams, 70)
+
+		counts := map[rune]int{}
+		for _, r := range order {
+			counts[r]++
+		}
+		assert.Equal(t, map[rune]int{'a': 40, 'b': 20, 'c': 10}, counts)
+	})
+
+	t.Run("WeightedFairShare Idle Stream Does Not Bank Credit", func(t *testing.T) {
+		m, streams := newTestableScheduler(WeightedFairShare, 0)
+		popSchedule(m, streams, 70)
+		late := &stream{QuerySpec: QuerySpec{Priority: 2}}
+		streams[late] = "d"
+		m.pushStream(late)
+
+		order := popSchedule(m, streams, 8)
+
+		n := countRune(order, 'd')
+		assert.GreaterOrEqual(t, n, 1)
+		assert.LessOrEqual(t, n, 2, "one eighth share of eight starts, plus at most one for rounding")
+	})
+
+	t.Run("RoundRobin", func(t *testing.T) {
+		m, streams := newTestableScheduler(RoundRobin, 0)
+
+		order := popSchedule(m, streams, 7)
+
+		assert.Len(t, order, 7)
+		assert.Equal(t, order[0:3], order[3:6])
+		assert.Equal(t, order[0], order[6])
+		for _, r := range "abc" {
+			assert.Equal(t, 1, countRune(order[0:3], r))
+		}
+	})
+}
+
+// newTestableScheduler returns a mgr with three waiting streams named
+// a, b, and c, with Priority 0, 1, and 2 respectively.
+func newTestableScheduler(policy SchedulingPolicy, aging time.Duration) (*mgr, map[*stream]string) {
+	m := &mgr{
+		Config: Config{
+			Scheduling:    policy,
+			PriorityAging: aging,
+		},
+		epoch: time.Now(),
+	}
+	streams := make(map[*stream]string)
+	for i, name := range []string{"a", "b", "c"} {
+		s := &stream{QuerySpec: QuerySpec{Priority: i}}
+		streams[s] = name
+		m.pushStream(s)
+	}
+	return m, streams
+}
+
+// popSchedule pops n streams from the mgr, pushing each one back as if
+// it had infinitely many chunks, and returns the names of the streams
+// in the order popped.
+func popSchedule(m *mgr, streams map[*stream]string, n int) string {
+	var order string
+	for i := 0; i < n; i++ {
+		s := m.popStream(m.tenants[""])
+		s.next++
+		order += streams[s]
+		m.pushStream(s)
+	}
+	return order
+}
+
+func countRune(s string, r rune) int {
+	n := 0
+	for _, x := range s {
+		if x == r {
+			n++
+		}
+	}
+	return n
+}
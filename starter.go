@@ -19,15 +19,24 @@ type starter struct {
 
 const maxTempStartingErrs = 10
 
+// Backoff parameters for restarting a chunk rejected with a
+// LimitExceededException under AdaptiveParallel, used if the Backoff
+// field of Config is not enabled.
+const (
+	limitExceededBase = time.Second
+	limitExceededMax  = time.Minute
+)
+
 func newStarter(m *mgr) *starter {
 	s := &starter{
 		worker: worker{
-			m:                 m,
-			regulator:         makeRegulator(m.close, m.RPS[StartQuery], RPSDefaults[StopQuery]),
-			in:                m.start,
-			out:               m.update,
-			name:              "starter",
-			maxTemporaryError: maxTempStartingErrs,
+			m:           m,
+			regulator:   makeBurstRegulator(m.close, m.RPS[StartQuery], m.defaultRPS(StartQuery), m.Burst[StartQuery], rpsQuota(m.RPSQuota, StartQuery)),
+			in:          m.start,
+			out:         m.update,
+			name:        "starter",
+			action:      StartQuery,
+			maxInFlight: m.MaxInFlight,
 		},
 	}
 	s.manipulator = s
@@ -49,18 +58,33 @@ func (s *starter) manipulate(c *chunk) outcome {
 	ends := epochMillisecond(c.end.Add(-time.Millisecond)) // CWL uses inclusive time ranges, we use exclusive ranges.
 
 	// Start the chunk.
+	text := markQuery(c.stream.Text, s.m.QueryMarker)
 	input := cloudwatchlogs.StartQueryInput{
-		QueryString:   &c.stream.Text,
+		QueryString:   &text,
 		StartTime:     &starts,
 		EndTime:       &ends,
 		LogGroupNames: c.stream.groups,
 		Limit:         &c.stream.Limit,
 	}
+	probe, err := s.m.breaker.allow()
+	if err == errProbeInFlight {
+		c.err = err
+		s.m.logChunk(c, "circuit breaker probing, holding", "")
+		return finished
+	} else if err != nil {
+		c.err = &StartQueryError{c.stream.Text, c.start, c.end, err}
+		s.m.logChunk(c, "circuit breaker open, failing", "")
+		return finished
+	}
 	output, err := s.m.Actions.StartQueryWithContext(c.ctx, &input, request.WithAppendUserAgent(version()))
-	s.lastReq = time.Now()
+	s.m.breaker.record(probe, err)
 	if err != nil {
 		c.err = &StartQueryError{c.stream.Text, c.start, c.end, err}
-		if isTemporary(err) {
+		if s.m.AdaptiveParallel && isLimitExceeded(err) {
+			s.m.logChunk(c, "concurrency limit exceeded for", err.Error())
+			return finished
+		}
+		if s.retry(c, err) {
 			s.m.logChunk(c, "temporary failure to start", err.Error())
 			return temporaryError
 		} else {
@@ -77,6 +101,7 @@ func (s *starter) manipulate(c *chunk) outcome {
 		return finished
 	}
 	c.queryID = *queryID
+	c.since = time.Now()
 
 	// Chunk is started successfully.
 	c.state = started
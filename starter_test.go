@@ -29,7 +29,7 @@ func TestNewStarter(t *testing.T) {
 	var out chan<- *chunk = s.m.update
 	assert.Equal(t, out, s.out)
 	assert.Equal(t, "starter", s.name)
-	assert.Equal(t, 10, s.maxTemporaryError)
+	assert.Equal(t, StartQuery, s.action)
 	a.AssertExpectations(t)
 	l.AssertExpectations(t)
 }
@@ -144,13 +144,9 @@ func TestStarter_manipulate(t *testing.T) {
 					end:     end,
 				}
 
-				before := time.Now()
 				actual := s.manipulate(c)
-				after := time.Now()
 
 				assert.Equal(t, testCase.expected, actual)
-				assert.GreaterOrEqual(t, s.lastReq.Sub(before), time.Duration(0))
-				assert.GreaterOrEqual(t, after.Sub(s.lastReq), time.Duration(0))
 				if testCase.err != nil {
 					assert.Equal(t, &StartQueryError{text, start, end, testCase.err}, c.err)
 				}
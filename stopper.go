@@ -16,15 +16,18 @@ type stopper struct {
 	worker
 }
 
+const maxTempStoppingErrs = 3
+
 func newStopper(m *mgr) *stopper {
 	s := &stopper{
 		worker: worker{
-			m:                 m,
-			regulator:         makeRegulator(m.close, m.RPS[StopQuery], RPSDefaults[StopQuery]),
-			in:                m.stop,
-			out:               m.update,
-			name:              "stopper",
-			maxTemporaryError: 3,
+			m:           m,
+			regulator:   makeBurstRegulator(m.close, m.RPS[StopQuery], m.defaultRPS(StopQuery), m.Burst[StopQuery], rpsQuota(m.RPSQuota, StopQuery)),
+			in:          m.stop,
+			out:         m.update,
+			name:        "stopper",
+			action:      StopQuery,
+			maxInFlight: m.MaxInFlight,
 		},
 	}
 	s.manipulator = s
@@ -39,8 +42,7 @@ func (s *stopper) manipulate(c *chunk) outcome {
 	output, err := s.m.Actions.StopQueryWithContext(context.Background(), &cloudwatchlogs.StopQueryInput{
 		QueryId: &c.queryID,
 	}, request.WithAppendUserAgent(version()))
-	s.lastReq = time.Now()
-	if err != nil && isTemporary(err) {
+	if err != nil && s.retry(c, err) {
 		return temporaryError
 	} else if err != nil {
 		s.m.logChunk(c, "failed to stop", "error from CloudWatch Logs: "+err.Error())
@@ -55,10 +57,8 @@ func (s *stopper) manipulate(c *chunk) outcome {
 }
 
 func (s *stopper) release(c *chunk) {
-	if s.setTimerRPS() {
-		<-s.timer.C
-		s.ding = true
-	}
-
+	s.pace()
+	s.sharedPace()
 	_ = s.manipulate(c)
+	s.lastReq = time.Now()
 }
@@ -29,7 +29,7 @@ func TestNewStopper(t *testing.T) {
 	var out chan<- *chunk = s.m.update
 	assert.Equal(t, out, s.out)
 	assert.Equal(t, "stopper", s.name)
-	assert.Equal(t, 3, s.maxTemporaryError)
+	assert.Equal(t, StopQuery, s.action)
 	a.AssertExpectations(t)
 	l.AssertExpectations(t)
 }
@@ -132,13 +132,9 @@ func TestStopper_manipulate(t *testing.T) {
 				queryID: "bar",
 			}
 
-			before := time.Now()
 			actual := s.manipulate(c)
-			after := time.Now()
 
 			assert.Equal(t, testCase.expected, actual)
-			assert.GreaterOrEqual(t, s.lastReq.Sub(before), time.Duration(0))
-			assert.GreaterOrEqual(t, after.Sub(s.lastReq), time.Duration(0))
 			actions.AssertExpectations(t)
 			logger.AssertExpectations(t)
 		})
@@ -190,6 +186,47 @@ func TestStopper_release(t *testing.T) {
 			}
 		})
 	}
+
+	t.Run("Token Bucket", func(t *testing.T) {
+		s, actions, logger := newTestableStopper(t, 10)
+		stopperManipulateCases[len(stopperManipulateCases)-1].setup(t, actions, logger)
+		s.burst = 3
+		s.tokens = 2
+		s.refill = time.Now()
+		s.lastReq = time.Now()
+		c := &chunk{
+			stream:  &stream{},
+			chunkID: "foo",
+			queryID: "bar",
+		}
+
+		before := time.Now()
+		s.release(c)
+
+		assert.Less(t, time.Since(before), s.minDelay/2, "release must use a token instead of waiting 1/RPS")
+		assert.InDelta(t, 1.0, s.tokens, 0.1)
+		actions.AssertExpectations(t)
+		logger.AssertExpectations(t)
+	})
+
+	t.Run("Shared Rate Limiter", func(t *testing.T) {
+		s, actions, logger := newTestableStopper(t, 1_000_000)
+		stopperManipulateCases[len(stopperManipulateCases)-1].setup(t, actions, logger)
+		rl := &countingRateLimiter{RateLimiter: NewRateLimiter(lotsOfRPS, nil)}
+		s.m.RateLimiter = rl
+		close(s.m.close)
+		c := &chunk{
+			stream:  &stream{},
+			chunkID: "foo",
+			queryID: "bar",
+		}
+
+		s.release(c)
+
+		assert.Equal(t, map[CloudWatchLogsAction]int{StopQuery: 1}, rl.counts())
+		actions.AssertExpectations(t)
+		logger.AssertExpectations(t)
+	})
 }
 
 func newTestableStopper(t *testing.T, rps int) (s *stopper, a *mockActions, l *mockLogger) {
@@ -6,6 +6,7 @@ package incite
 
 import (
 	"context"
+	"io"
 	"sync"
 	"time"
 )
@@ -19,9 +20,31 @@ type stream struct {
 	n      int64              // Number of total chunks
 	groups []*string          // Preprocessed slice for StartQuery
 
+	// Immutable field used by Pause and Resume.
+	mgr *mgr // Owning mgr, nil if the stream is not managed
+
+	// Immutable field only used by streams with a deadline. The timer
+	// is stopped while the stream is paused.
+	expiry *time.Timer // Notifies mgr loop when the stream deadline passes
+
+	// Immutable fields only used by attached streams.
+	queryID     string // Insights query ID of the attached query
+	stopOnClose bool   // Whether to stop the attached query if the stream dies
+
 	// Mutable fields only read/written by mgr loop goroutine.
-	next int64 // Next chunk to create
-	m    int64 // Number of chunks completed
+	next      int64         // Next chunk to create
+	m         int64         // Number of chunks completed
+	failed    []FailedRange // Failed time ranges, if partial results allowed
+	rank      float64       // Position in the stream heap under the scheduling policy
+	seq       int64         // Order in which the stream was last pushed onto the stream heap
+	vtime     float64       // Virtual time consumed under WeightedFairShare
+	active    int           // Number of chunks handed off to workers
+	parked    bool          // Whether the stream was left out of the stream heap by MaxParallel
+	held      []*chunk      // Ready chunks held back by MaxParallel
+	queued    bool          // Whether the stream is in the stream heap
+	preempted []*chunk      // Preempted chunks waiting to restart
+	sleeping  bool          // Whether the stream was left out of the stream heap because it is paused
+	halted    bool          // Whether running chunks are stopped while the stream is paused
 
 	// Lock controlling access to the below mutable fields.
 	lock sync.RWMutex
@@ -32,6 +55,18 @@ type stream struct {
 	i, j   int        // Block index and position within block
 	more   *sync.Cond // Used to block a Read pending more blocks
 	err    error      // Error to return, if any
+	paused time.Time  // Time the stream was paused, zero if not paused
+
+	// Mutable fields only used by streams with a deadline, controlled
+	// by stream using lock.
+	deadline time.Time     // Time the stream deadline passes, as of the last resume
+	timeLeft time.Duration // Time left before the deadline when the stream was paused
+}
+
+// capped returns true if the stream is already running as many chunks
+// as its MaxParallel allows.
+func (s *stream) capped() bool {
+	return s.MaxParallel > 0 && s.active >= s.MaxParallel
 }
 
 func (s *stream) Close() error {
@@ -61,7 +96,77 @@ func (s *stream) GetStats() Stats {
 	s.lock.RLock()
 	defer s.lock.RUnlock()
 
-	return s.stats
+	stats := s.stats
+	if !s.paused.IsZero() {
+		stats.TimePaused += time.Since(s.paused)
+	}
+	return stats
+}
+
+func (s *stream) Pause(stopRunning bool) error {
+	s.lock.Lock()
+	if s.err == ErrClosed {
+		s.lock.Unlock()
+		return ErrClosed
+	}
+	if s.err != nil || !s.paused.IsZero() {
+		s.lock.Unlock()
+		return nil
+	}
+	s.paused = time.Now()
+	if s.expiry != nil && s.expiry.Stop() {
+		s.timeLeft = s.deadline.Sub(s.paused)
+	}
+	s.lock.Unlock()
+
+	if stopRunning {
+		s.control(streamControl{s: s, pause: true})
+	}
+	return nil
+}
+
+func (s *stream) Resume() error {
+	s.lock.Lock()
+	if s.err == ErrClosed {
+		s.lock.Unlock()
+		return ErrClosed
+	}
+	if s.paused.IsZero() {
+		s.lock.Unlock()
+		return nil
+	}
+	d := time.Since(s.paused)
+	s.paused = time.Time{}
+	s.stats.TimePaused += d
+	if s.timeLeft > 0 && s.err == nil {
+		s.deadline = time.Now().Add(s.timeLeft)
+		s.expiry.Reset(s.timeLeft)
+	}
+	s.timeLeft = 0
+	s.lock.Unlock()
+
+	s.control(streamControl{s: s, paused: d})
+	return nil
+}
+
+// control sends a request to pause or resume the stream to the owning
+// mgr, if there is one.
+func (s *stream) control(ctl streamControl) {
+	if s.mgr == nil {
+		return
+	}
+	select {
+	case s.mgr.control <- ctl:
+	case <-s.mgr.close:
+	}
+}
+
+// isPaused returns true if the stream is paused.
+func (s *stream) isPaused() bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return !s.paused.IsZero()
 }
 
 func (s *stream) read(r []Result) (int, error) {
@@ -92,12 +197,33 @@ func (s *stream) setErr(err error, lock bool, stats Stats) bool {
 		return false
 	}
 
-	s.err = err
+	if err != nil {
+		s.err = err
+		if s.expiry != nil {
+			s.expiry.Stop()
+		}
+	}
 	s.stats.add(&stats)
 	s.more.Signal()
 	return true
 }
 
+// eof returns the error which ends a stream once all its chunks are
+// done: io.EOF, or a *PartialResultsError if some chunks failed.
+func (s *stream) eof() error {
+	if len(s.failed) == 0 {
+		return io.EOF
+	}
+	return &PartialResultsError{s.Text, s.failed}
+}
+
+// stoppable returns true if the Insights query of a running chunk of
+// the stream should be stopped when the stream dies before the chunk
+// is complete.
+func (s *stream) stoppable() bool {
+	return s.queryID == "" || s.stopOnClose
+}
+
 func (s *stream) alive() bool {
 	s.lock.RLock()
 	defer s.lock.RUnlock()
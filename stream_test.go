@@ -5,6 +5,7 @@
 package incite
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"sync"
@@ -186,6 +187,29 @@ func TestStream_Read(t *testing.T) {
 	})
 }
 
+func TestStream_setErr(t *testing.T) {
+	t.Run("Nil Error Keeps Earlier Error", func(t *testing.T) {
+		s := &stream{}
+		s.more = sync.NewCond(&s.lock)
+		err := errors.New("first")
+
+		assert.True(t, s.setErr(err, true, Stats{}))
+		assert.True(t, s.setErr(nil, true, Stats{RangeDone: time.Second}))
+
+		assert.Same(t, err, s.err)
+		assert.Equal(t, time.Second, s.stats.RangeDone)
+	})
+
+	t.Run("Error Stops Expiry Timer", func(t *testing.T) {
+		s := &stream{expiry: time.NewTimer(time.Hour)}
+		s.more = sync.NewCond(&s.lock)
+
+		s.setErr(ErrClosed, true, Stats{})
+
+		assert.False(t, s.expiry.Stop(), "timer should already be stopped")
+	})
+}
+
 func TestStream_NextChunkRange(t *testing.T) {
 	testCases := []*struct {
 		name string
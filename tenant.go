@@ -0,0 +1,158 @@
+// Copyright 2022 The incite Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package incite
+
+import (
+	"container/list"
+	"container/ring"
+)
+
+// A tenant holds the scheduling state of all the streams of a
+// QueryManager which share the same Tenant label. The mgr loop shares
+// the Parallel slots between tenants by weighted fair queueing, and
+// within a tenant between streams according to the SchedulingPolicy.
+type tenant struct {
+	name     string
+	weight   int        // Share of Parallel slots relative to other tenants
+	pq       streamHeap // Streams with chunks waiting to start
+	ready    ring.Ring  // Chunks ready to start
+	numReady int        // Number of chunks ready to start
+	running  int        // Number of chunks handed off to workers
+	served   int64      // Value of mgr turn when tenant was last given a slot
+	vtime    float64    // Virtual time of the last stream popped under WeightedFairShare
+}
+
+// waiting returns true if the tenant has chunks waiting to start.
+func (t *tenant) waiting() bool {
+	return t.numReady > 0 || len(t.pq) > 0
+}
+
+// idle returns true if the tenant has no chunks waiting or running.
+func (t *tenant) idle() bool {
+	return !t.waiting() && t.running == 0
+}
+
+// before returns true if tenant t should be given the next Parallel
+// slot in preference to tenant u, because t has the lower number of
+// running chunks relative to its weight.
+func (t *tenant) before(u *tenant) bool {
+	a, b := t.running*u.weight, u.running*t.weight
+	if a != b {
+		return a < b
+	}
+	return t.served < u.served
+}
+
+// tenantWeight returns the weight of the named tenant.
+func (m *mgr) tenantWeight(name string) int {
+	if w := m.TenantWeights[name]; w > 0 {
+		return w
+	}
+	return 1
+}
+
+// tenantOf returns the scheduling state of the tenant which owns a
+// stream, creating it if the tenant is not yet known.
+func (m *mgr) tenantOf(s *stream) *tenant {
+	t := m.tenants[s.Tenant]
+	if t == nil {
+		if m.tenants == nil {
+			m.tenants = make(map[string]*tenant)
+		}
+		t = &tenant{
+			name:   s.Tenant,
+			weight: m.tenantWeight(s.Tenant),
+		}
+		m.tenants[s.Tenant] = t
+	}
+	return t
+}
+
+// forgetIdle discards the scheduling state of a tenant which has no
+// more work, so that a QueryManager serving a large number of
+// short-lived tenants does not leak memory.
+func (m *mgr) forgetIdle(t *tenant) {
+	if t.idle() {
+		delete(m.tenants, t.name)
+	}
+}
+
+// nextTenant returns the tenant which should be given the next
+// Parallel slot, or nil if no tenant has chunks waiting to start.
+func (m *mgr) nextTenant() *tenant {
+	var next *tenant
+	for _, t := range m.tenants {
+		if t.waiting() && (next == nil || t.before(next)) {
+			next = t
+		}
+	}
+	return next
+}
+
+// dispatch hands a chunk off to a worker, counting it against the
+// Parallel slots in use by its tenant and stream.
+func (m *mgr) dispatch(ch chan<- *chunk, c *chunk) {
+	m.tenantOf(c.stream).running++
+	c.stream.active++
+	ch <- c
+}
+
+// release stops counting a chunk returned by a worker against the
+// Parallel slots in use by its tenant and stream.
+func (m *mgr) release(c *chunk) {
+	t := m.tenantOf(c.stream)
+	t.running--
+	c.stream.active--
+	m.removePreemptible(c)
+	m.unpark(c.stream)
+	m.forgetIdle(t)
+}
+
+// tenantStats holds the running statistics of one tenant.
+type tenantStats struct {
+	name  string
+	stats Stats
+}
+
+// addTenantStats adds to the running statistics of the named tenant.
+// If the tenant's statistics are new and MaxTenantStats tenants are
+// already remembered, the statistics of the tenant least recently
+// updated are forgotten. The caller must hold the stats lock.
+func (m *mgr) addTenantStats(name string, t *Stats) {
+	e := m.tenantStats[name]
+	if e != nil {
+		m.tenantLRU.MoveToFront(e)
+	} else {
+		if m.tenantStats == nil {
+			m.tenantStats = make(map[string]*list.Element)
+		}
+		if len(m.tenantStats) >= m.maxTenantStats() {
+			oldest := m.tenantLRU.Back()
+			delete(m.tenantStats, oldest.Value.(*tenantStats).name)
+			m.tenantLRU.Remove(oldest)
+		}
+		e = m.tenantLRU.PushFront(&tenantStats{name: name})
+		m.tenantStats[name] = e
+	}
+	e.Value.(*tenantStats).stats.add(t)
+}
+
+// maxTenantStats returns the number of tenants whose statistics are
+// remembered.
+func (m *mgr) maxTenantStats() int {
+	if m.MaxTenantStats > 0 {
+		return m.MaxTenantStats
+	}
+	return DefaultMaxTenantStats
+}
+
+func (m *mgr) GetTenantStats(name string) Stats {
+	m.statsLock.RLock()
+	defer m.statsLock.RUnlock()
+	if e := m.tenantStats[name]; e != nil {
+		return e.Value.(*tenantStats).stats
+	}
+	return Stats{}
+}
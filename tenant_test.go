@@ -0,0 +1,195 @@
+// Copyright 2022 The incite Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package incite
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMgr_getReadyChunk_Tenants(t *testing.T) {
+	t.Run("Slots Are Shared By Weight", func(t *testing.T) {
+		m := &mgr{
+			Config: Config{
+				TenantWeights: map[string]int{"a": 2},
+			},
+		}
+		m.pushStream(newTenantStream("a", 100))
+		m.pushStream(newTenantStream("b", 100))
+
+		running := takeChunks(m, 6)
+
+		assert.Equal(t, map[string]int{"a": 4, "b": 2}, running)
+		assert.Equal(t, 4, m.tenants["a"].running)
+		assert.Equal(t, 2, m.tenants["b"].running)
+	})
+
+	t.Run("Released Slot Goes To Tenant Below Its Share", func(t *testing.T) {
+		m := &mgr{}
+		m.pushStream(newTenantStream("a", 100))
+		m.pushStream(newTenantStream("b", 100))
+		takeChunks(m, 4)
+		m.tenants["a"].running--
+
+		c := m.getReadyChunk()
+
+		require.NotNil(t, c)
+		assert.Equal(t, "a", c.stream.Tenant)
+	})
+
+	t.Run("Tenant With Many Streams Gets No More Than Its Share", func(t *testing.T) {
+		m := &mgr{}
+		for i := 0; i < 5; i++ {
+			m.pushStream(newTenantStream("busy", 10))
+		}
+		m.pushStream(newTenantStream("quiet", 10))
+
+		running := takeChunks(m, 4)
+
+		assert.Equal(t, map[string]int{"busy": 2, "quiet": 2}, running)
+	})
+
+	t.Run("Idle Tenant Is Forgotten", func(t *testing.T) {
+		m := &mgr{}
+		m.pushStream(newTenantStream("a", 1))
+
+		c := m.getReadyChunk()
+		require.NotNil(t, c)
+		m.dispatch(make(chan *chunk, 1), c)
+		m.release(c)
+
+		assert.Nil(t, m.getReadyChunk())
+		assert.Empty(t, m.tenants)
+	})
+
+	t.Run("Tenant With Only Dead Streams Is Skipped", func(t *testing.T) {
+		m := &mgr{}
+		dead := newTenantStream("dead", 10)
+		dead.err = ErrClosed
+		m.pushStream(dead)
+		m.pushStream(newTenantStream("live", 10))
+		m.tenants["live"].running = 5
+
+		c := m.getReadyChunk()
+
+		require.NotNil(t, c)
+		assert.Equal(t, "live", c.stream.Tenant)
+		assert.NotContains(t, m.tenants, "dead")
+	})
+}
+
+func TestQueryManager_GetTenantStats(t *testing.T) {
+	text := "tenant query"
+	actions := newMockActions(t)
+	actions.
+		On("StartQueryWithContext", anyContext, startQueryInput(text, defaultStart, defaultEnd, DefaultLimit, "grp")).
+		Return(&cloudwatchlogs.StartQueryOutput{QueryId: sp("q")}, nil).
+		Twice()
+	actions.
+		On("GetQueryResultsWithContext", anyContext, &cloudwatchlogs.GetQueryResultsInput{QueryId: sp("q")}).
+		Return(&cloudwatchlogs.GetQueryResultsOutput{
+			Status:     sp(cloudwatchlogs.QueryStatusComplete),
+			Statistics: &cloudwatchlogs.QueryStatistics{RecordsMatched: float64p(3)},
+		}, nil).
+		Twice()
+	m := NewQueryManager(Config{
+		Actions:       actions,
+		RPS:           lotsOfRPS,
+		TenantWeights: map[string]int{"a": 3},
+	})
+	t.Cleanup(func() {
+		_ = m.Close()
+	})
+
+	for _, tenant := range []string{"a", "b"} {
+		s, err := m.Query(QuerySpec{
+			Text:   text,
+			Groups: []string{"grp"},
+			Start:  defaultStart,
+			End:    defaultEnd,
+			Tenant: tenant,
+		})
+		require.NoError(t, err)
+		_, err = ReadAll(s)
+		require.NoError(t, err)
+	}
+
+	a, b := m.(TenantStatsGetter).GetTenantStats("a"), m.(TenantStatsGetter).GetTenantStats("b")
+	assert.Equal(t, float64(3), a.RecordsMatched)
+	assert.Equal(t, defaultEnd.Sub(defaultStart), a.RangeRequested)
+	assert.Equal(t, defaultEnd.Sub(defaultStart), a.RangeDone)
+	assert.Equal(t, a, b)
+	assert.Equal(t, Stats{}, m.(TenantStatsGetter).GetTenantStats("unknown"))
+	assert.Equal(t, float64(6), m.GetStats().RecordsMatched)
+	actions.AssertExpectations(t)
+}
+
+func TestMgr_addTenantStats(t *testing.T) {
+	t.Run("Default Limit", func(t *testing.T) {
+		m := &mgr{}
+
+		assert.Equal(t, DefaultMaxTenantStats, m.maxTenantStats())
+	})
+
+	t.Run("Least Recently Updated Forgotten", func(t *testing.T) {
+		m := &mgr{Config: Config{MaxTenantStats: 2}}
+		m.addTenantStats("a", &Stats{RecordsMatched: 1})
+		m.addTenantStats("b", &Stats{RecordsMatched: 2})
+		m.addTenantStats("a", &Stats{RecordsMatched: 1})
+
+		m.addTenantStats("c", &Stats{RecordsMatched: 3})
+
+		assert.Equal(t, Stats{RecordsMatched: 2}, m.GetTenantStats("a"))
+		assert.Equal(t, Stats{}, m.GetTenantStats("b"))
+		assert.Equal(t, Stats{RecordsMatched: 3}, m.GetTenantStats("c"))
+		assert.Len(t, m.tenantStats, 2)
+		assert.Equal(t, 2, m.tenantLRU.Len())
+	})
+}
+
+func TestNewQueryManager_TenantWeights(t *testing.T) {
+	assert.PanicsWithValue(t, badTenantWeightMsg, func() {
+		NewQueryManager(Config{
+			Actions:       newMockActions(t),
+			TenantWeights: map[string]int{"a": -1},
+		})
+	})
+}
+
+// newTenantStream returns a stream belonging to the named tenant with n
+// one-minute chunks.
+func newTenantStream(tenant string, n int64) *stream {
+	return &stream{
+		QuerySpec: QuerySpec{
+			Start:  defaultStart,
+			End:    defaultStart.Add(time.Duration(n) * time.Minute),
+			Chunk:  time.Minute,
+			Tenant: tenant,
+		},
+		ctx: context.Background(),
+		n:   n,
+	}
+}
+
+// takeChunks gets up to n ready chunks from the mgr, dispatching each
+// one, and returns the number of chunks taken from each tenant.
+func takeChunks(m *mgr, n int) map[string]int {
+	running := make(map[string]int)
+	ch := make(chan *chunk, n)
+	for i := 0; i < n; i++ {
+		c := m.getReadyChunk()
+		if c == nil {
+			break
+		}
+		m.dispatch(ch, c)
+		running[c.stream.Tenant]++
+	}
+	return running
+}
@@ -7,7 +7,7 @@ package incite
 import (
 	"container/ring"
 	"context"
-	"strconv"
+	"time"
 )
 
 // A nextStep is an instruction returned from a manipulator's manipulate
@@ -21,9 +21,8 @@ const (
 	// inconclusive indicates that the manipulation did not obtain a
 	// final result and the manipulation should be retried.
 	inconclusive
-	// temporaryError indicates that the manipulation encountered a
-	// temporary error which should be retried up to the worker's
-	// maximum try limit.
+	// temporaryError indicates that the manipulation encountered an
+	// error which the RetryPolicy decided should be retried.
 	temporaryError
 )
 
@@ -45,61 +44,139 @@ type manipulator interface {
 // limiting or necessary retries. The worker keeps these in-progress
 // chunks in the ring named chunks.
 //
+// So that the rate of CloudWatch Logs requests is limited by the
+// regulator rather than by request latency, the worker does not wait
+// for one manipulation to finish before starting the next. Instead,
+// each time the regulator allows, the loop goroutine hands the next
+// chunk to a new goroutine which manipulates it and reports the
+// outcome on channel done. Up to maxInFlight manipulations may be in
+// flight at once. Chunks are only ever touched by one goroutine at a
+// time, and the ring and all other worker state are owned by the loop
+// goroutine.
+//
 // The worker goroutine exits when one of two conditions is met: either
 // its regulator's close channel is closed while waiting for the rate
 // limiting timer; or the in channel is closed while the worker is
 // trying to read the next chunk from it. Before exiting, the worker
-// calls the manipulator's release method once for every in-progress
-// chunk, and sends the in-progress chunk to channel out.
+// waits for in-flight manipulations to finish, then calls the
+// manipulator's release method once for every in-progress chunk, and
+// sends the in-progress chunk to channel out.
 type worker struct {
-	m                 *mgr          // Owning mgr
-	regulator                       // Used to rate limit the work loop
-	in                <-chan *chunk // Provides chunks to the worker
-	out               chan<- *chunk // Receives chunks manipulated or released by the worker
-	chunks            ring.Ring     // In-progress chunks
-	numChunks         int           // Number of in-progress chunks
-	name              string        // Worker name for logging purposes
-	maxTemporaryError int           // Maximum number of temporary errors per chunk
-	manipulator       manipulator   // Specializes the worker
+	m           *mgr                 // Owning mgr
+	regulator                        // Used to rate limit the work loop
+	in          <-chan *chunk        // Provides chunks to the worker
+	out         chan<- *chunk        // Receives chunks manipulated or released by the worker
+	chunks      ring.Ring            // In-progress chunks
+	numChunks   int                  // Number of in-progress chunks
+	name        string               // Worker name for logging purposes
+	action      CloudWatchLogsAction // CloudWatch Logs action the worker calls
+	maxInFlight int                  // Maximum number of concurrent manipulations, one if not positive
+	numInFlight int                  // Number of manipulations in flight
+	done        chan manipulation    // Receives outcomes of in-flight manipulations
+	manipulator manipulator          // Specializes the worker
+}
+
+// A manipulation is the outcome of manipulating a chunk.
+type manipulation struct {
+	c *chunk
+	o outcome
 }
 
 func (w *worker) loop() {
 	defer w.shutdown()
 
+	if w.maxInFlight < 1 {
+		w.maxInFlight = 1
+	}
+	w.done = make(chan manipulation, w.maxInFlight)
+
 	w.m.logEvent(w.name, "started")
 
 	for {
+		w.collect()
+		for w.numInFlight >= w.maxInFlight {
+			w.handle(<-w.done)
+		}
 		c := w.pop()
 		if c == nil {
 			return
 		}
 		ctx := w.manipulator.context(c)
 		err := w.wait(ctx)
+		if err == nil {
+			err = w.sharedWait(ctx)
+		}
 		if err == errClosing {
 			w.push(c)
 			return // mgr is closing, so stop working
 		}
-		o := w.manipulator.manipulate(c)
-		switch o {
-		case finished:
-			w.out <- c
-		case inconclusive:
-			w.push(c)
-		case temporaryError:
-			c.tmp++
-			if c.tmp < w.maxTemporaryError {
-				w.push(c)
-			} else {
-				w.m.logChunk(c, w.name+" exceeded max tries for", strconv.Itoa(c.tmp))
-				w.out <- c
+		w.lastReq = time.Now()
+		w.numInFlight++
+		go func() {
+			w.done <- manipulation{c, w.manipulator.manipulate(c)}
+		}()
+	}
+}
+
+// collect handles the outcomes of all in-flight manipulations which
+// have already finished, without blocking.
+func (w *worker) collect() {
+	for {
+		select {
+		case x := <-w.done:
+			w.handle(x)
+		default:
+			return
+		}
+	}
+}
+
+func (w *worker) handle(x manipulation) {
+	w.numInFlight--
+	c := x.c
+	if w.m.Backoff.enabled() {
+		if x.o != temporaryError {
+			w.speedUp()
+		} else if isThrottled(c.err) {
+			w.slowDown()
+		}
+	}
+	switch x.o {
+	case finished:
+		w.out <- c
+	case inconclusive:
+		w.push(c)
+	case temporaryError:
+		c.tmp++
+		d := c.delay
+		if w.m.Backoff.enabled() {
+			if b := w.m.Backoff.delay(c.tmp); b > d {
+				d = b
 			}
 		}
+		if d > 0 {
+			c.due = time.Now().Add(d)
+		}
+		w.push(c)
 	}
 }
 
 func (w *worker) shutdown() {
 	w.m.logEvent(w.name, "stopping...")
 
+	// Wait for in-flight manipulations. Finished chunks go back to the
+	// mgr as normal, so that, for example, a query which was started
+	// can still be stopped. Unfinished chunks are released below.
+	for w.numInFlight > 0 {
+		x := <-w.done
+		w.numInFlight--
+		if x.o == finished {
+			w.out <- x.c
+		} else {
+			w.push(x.c)
+		}
+	}
+
 	// Release the chunks we already queued for manipulation.
 	w.chunks.Do(func(i interface{}) {
 		if i != nil {
@@ -133,32 +210,99 @@ func (w *worker) push(c *chunk) {
 }
 
 func (w *worker) pop() *chunk {
-	var c *chunk
-	var closing bool
-	if w.numChunks == 0 {
-		c, closing = w.blockPop()
-	} else {
-		c, closing = w.noBlockPop()
+	for {
+		var c *chunk
+		var closing bool
+		if w.numChunks == 0 {
+			c, closing = w.blockPop()
+		} else {
+			c, closing = w.noBlockPop()
+		}
+		if closing {
+			return nil
+		}
+		if c != nil {
+			w.receive(c)
+		}
+		c, due := w.popDue()
+		if c != nil {
+			c.try++
+			return c
+		}
+		if w.sleep(time.Until(due)) {
+			return nil
+		}
 	}
-	if closing {
-		return nil
+}
+
+// receive adds a chunk newly received from the in channel to the ring.
+func (w *worker) receive(c *chunk) {
+	c.try = 0
+	c.tmp = 0
+	c.delay = 0
+	c.due = c.hold
+	c.hold = time.Time{}
+	w.push(c)
+}
+
+// popDue removes and returns the first chunk in the ring which is due
+// to be manipulated. If no chunk is due, popDue returns nil and the
+// earliest time at which a chunk will be due.
+func (w *worker) popDue() (*chunk, time.Time) {
+	now := time.Now()
+	var earliest time.Time
+	r := w.chunks.Next()
+	for i := 0; i < w.numChunks; i++ {
+		c := r.Value.(*chunk)
+		if !c.due.After(now) {
+			r.Prev().Unlink(1)
+			w.numChunks--
+			return c, time.Time{}
+		}
+		if earliest.IsZero() || c.due.Before(earliest) {
+			earliest = c.due
+		}
+		r = r.Next()
 	}
-	if c != nil {
-		c.try = 0
-		c.tmp = 0
-		w.push(c)
+	return nil, earliest
+}
+
+// sleep waits until d has elapsed, a new chunk arrives, or an in-flight
+// manipulation finishes. It returns true if the worker should stop
+// because the mgr is closing or the in channel is closed.
+func (w *worker) sleep(d time.Duration) bool {
+	if !w.setTimer(d) {
+		return false
 	}
-	r := w.chunks.Next()
-	c = r.Value.(*chunk)
-	w.chunks.Unlink(1)
-	w.numChunks--
-	c.try++
-	return c
+
+	select {
+	case <-w.close:
+		return true
+	case c := <-w.in:
+		if c == nil {
+			return true
+		}
+		w.receive(c)
+	case x := <-w.done:
+		w.handle(x)
+	case <-w.timer.C:
+		w.ding = true
+	}
+	return false
 }
 
 func (w *worker) blockPop() (*chunk, bool) {
-	c := <-w.in
-	return c, c == nil
+	for {
+		select {
+		case c := <-w.in:
+			return c, c == nil
+		case x := <-w.done:
+			w.handle(x)
+			if w.numChunks > 0 {
+				return nil, false
+			}
+		}
+	}
 }
 
 func (w *worker) noBlockPop() (*chunk, bool) {
@@ -18,7 +18,7 @@ import (
 
 func TestWorker_LoopAndShutdown(t *testing.T) {
 	t.Run("Loop Exits When In Channel Is Closed", func(t *testing.T) {
-		w, l, m, in, _, _ := newTestableWorker(t, 1, 1)
+		w, l, m, in, _, _ := newTestableWorker(t, 1)
 		w.expectLoopLogs(l)
 		close(in)
 
@@ -28,8 +28,8 @@ func TestWorker_LoopAndShutdown(t *testing.T) {
 		m.AssertExpectations(t)
 	})
 
-	t.Run("Chunk Is Pushed When Manipulate Fails and Retries Not Exceeded", func(t *testing.T) {
-		w, l, m, in, out, _ := newTestableWorker(t, 100_000, 2)
+	t.Run("Chunk Is Pushed When Manipulate Fails With Temporary Error", func(t *testing.T) {
+		w, l, m, in, out, _ := newTestableWorker(t, 100_000)
 		out2 := make(chan *chunk)
 		w.expectLoopLogs(l)
 		c := &chunk{}
@@ -58,40 +58,8 @@ func TestWorker_LoopAndShutdown(t *testing.T) {
 		assert.Same(t, c, d)
 	})
 
-	t.Run("Chunk Is Sent to Out Channel When Manipulate Fails and Retries Exceeded", func(t *testing.T) {
-		w, l, m, in, out, _ := newTestableWorker(t, 100_000, 1)
-		out2 := make(chan *chunk)
-		w.expectLoopLogs(l)
-		c := &chunk{
-			stream: &stream{},
-		}
-
-		go func() {
-			in <- c
-		}()
-		go func() {
-			d := <-out
-			out2 <- d
-		}()
-		l.ExpectPrintf("incite: QueryManager(%s) %s chunk %s %q [%s..%s): %s", w.m.Name)
-		m.On("context", c).Return(context.Background()).Once()
-		m.On("manipulate", c).
-			Run(func(_ mock.Arguments) {
-				close(in)
-			}).
-			Return(temporaryError).
-			Once()
-
-		w.loop()
-		d := <-out2
-
-		l.AssertExpectations(t)
-		m.AssertExpectations(t)
-		assert.Same(t, c, d)
-	})
-
 	t.Run("Chunk Is Sent to Out Channel When Manipulate Succeeds", func(t *testing.T) {
-		w, l, m, in, out, _ := newTestableWorker(t, 100_000, 1)
+		w, l, m, in, out, _ := newTestableWorker(t, 100_000)
 		out2 := make(chan *chunk)
 		w.expectLoopLogs(l)
 		c := &chunk{}
@@ -120,7 +88,7 @@ func TestWorker_LoopAndShutdown(t *testing.T) {
 	})
 
 	t.Run("Leftover Chunks in Channel are Released In Shutdown", func(t *testing.T) {
-		w, l, m, in, out, closer := newTestableWorker(t, 1, 1)
+		w, l, m, in, out, closer := newTestableWorker(t, 1)
 		w.expectLoopLogs(l)
 		n := 15
 		readFromOut := make(map[int]bool, n)
@@ -186,9 +154,62 @@ func TestWorker_LoopAndShutdown(t *testing.T) {
 	})
 }
 
+func TestWorker_InFlight(t *testing.T) {
+	const n = 10
+	const rps = 100
+	const latency = 50 * time.Millisecond
+
+	run := func(t *testing.T, maxInFlight int) (time.Duration, []time.Time) {
+		w, l, _, in, out, closer := newTestableWorker(t, rps)
+		w.expectLoopLogs(l)
+		sm := &sleepyManipulator{latency: latency}
+		w.manipulator = sm
+		w.maxInFlight = maxInFlight
+
+		go func() {
+			for i := 0; i < n; i++ {
+				in <- &chunk{chunkID: strconv.Itoa(i)}
+			}
+		}()
+		stopped := make(chan struct{})
+		before := time.Now()
+		go func() {
+			w.loop()
+			close(stopped)
+		}()
+		for i := 0; i < n; i++ {
+			<-out
+		}
+		elapsed := time.Since(before)
+		close(closer)
+		close(in)
+		<-stopped
+
+		l.AssertExpectations(t)
+		return elapsed, sm.starts()
+	}
+
+	t.Run("Serial", func(t *testing.T) {
+		elapsed, starts := run(t, 1)
+
+		assert.GreaterOrEqual(t, elapsed, n*latency, "serial throughput must be bounded by latency")
+		assert.Len(t, starts, n)
+	})
+
+	t.Run("Concurrent", func(t *testing.T) {
+		elapsed, starts := run(t, n)
+
+		assert.Less(t, elapsed, n*latency/2, "concurrent throughput must not be bounded by latency")
+		require.Len(t, starts, n)
+		for i := 1; i < n; i++ {
+			assert.GreaterOrEqual(t, starts[i].Sub(starts[i-1]), time.Second/rps-time.Millisecond, "rate must be respected")
+		}
+	})
+}
+
 func TestWorker_PushAndPop(t *testing.T) {
 	t.Run("Empty Pop, Not Closed", func(t *testing.T) {
-		w, _, _, in, _, _ := newTestableWorker(t, 1, 1)
+		w, _, _, in, _, _ := newTestableWorker(t, 1)
 		expected := &chunk{}
 		go func() {
 			in <- expected
@@ -200,7 +221,7 @@ func TestWorker_PushAndPop(t *testing.T) {
 	})
 
 	t.Run("Empty Pop, Closed", func(t *testing.T) {
-		w, _, _, in, _, _ := newTestableWorker(t, 1, 1)
+		w, _, _, in, _, _ := newTestableWorker(t, 1)
 		close(in)
 
 		actual := w.pop()
@@ -209,7 +230,7 @@ func TestWorker_PushAndPop(t *testing.T) {
 	})
 
 	t.Run("Single Push, Pop without Receive", func(t *testing.T) {
-		w, _, _, _, _, _ := newTestableWorker(t, 1, 1)
+		w, _, _, _, _, _ := newTestableWorker(t, 1)
 		expected := &chunk{}
 
 		t.Run("Push", func(t *testing.T) {
@@ -227,7 +248,7 @@ func TestWorker_PushAndPop(t *testing.T) {
 	})
 
 	t.Run("Single Push, Pop with Receive, Pop without Receive", func(t *testing.T) {
-		w, _, _, in, _, _ := newTestableWorker(t, 1, 1)
+		w, _, _, in, _, _ := newTestableWorker(t, 1)
 		first, second := &chunk{}, &chunk{}
 		var x, y *chunk
 
@@ -263,7 +284,70 @@ func TestWorker_PushAndPop(t *testing.T) {
 	})
 }
 
-func newTestableWorker(t *testing.T, rps, tmp int) (w *worker, l *mockLogger, m *mockManipulator, in, out chan *chunk, closer chan struct{}) {
+func TestWorker_PopDue(t *testing.T) {
+	t.Run("Chunk Not Due Is Skipped", func(t *testing.T) {
+		w, _, _, _, _, _ := newTestableWorker(t, 1)
+		later := &chunk{chunkID: "later", due: time.Now().Add(time.Hour)}
+		now := &chunk{chunkID: "now"}
+		w.push(later)
+		w.push(now)
+
+		actual := w.pop()
+
+		assert.Same(t, now, actual)
+		assert.Equal(t, 1, w.numChunks)
+	})
+
+	t.Run("Sleeps Until Due", func(t *testing.T) {
+		w, _, _, _, _, _ := newTestableWorker(t, 1)
+		t.Cleanup(func() {
+			w.timer.Stop()
+		})
+		due := time.Now().Add(20 * time.Millisecond)
+		expected := &chunk{due: due}
+		w.push(expected)
+
+		actual := w.pop()
+
+		assert.Same(t, expected, actual)
+		assert.False(t, time.Now().Before(due))
+	})
+
+	t.Run("New Chunk Interrupts Sleep", func(t *testing.T) {
+		w, _, _, in, _, _ := newTestableWorker(t, 1)
+		t.Cleanup(func() {
+			w.timer.Stop()
+		})
+		w.push(&chunk{due: time.Now().Add(time.Hour)})
+		expected := &chunk{due: time.Now().Add(time.Hour)}
+		go func() {
+			in <- expected
+		}()
+
+		actual := w.pop()
+
+		assert.Same(t, expected, actual)
+		assert.True(t, actual.due.IsZero())
+	})
+
+	t.Run("Close Interrupts Sleep", func(t *testing.T) {
+		w, _, _, _, _, closer := newTestableWorker(t, 1)
+		t.Cleanup(func() {
+			w.timer.Stop()
+		})
+		w.push(&chunk{due: time.Now().Add(time.Hour)})
+		go func() {
+			close(closer)
+		}()
+
+		actual := w.pop()
+
+		assert.Nil(t, actual)
+		assert.Equal(t, 1, w.numChunks)
+	})
+}
+
+func newTestableWorker(t *testing.T, rps int) (w *worker, l *mockLogger, m *mockManipulator, in, out chan *chunk, closer chan struct{}) {
 	closer = make(chan struct{})
 	l = newMockLogger(t)
 	m = newMockManipulator(t)
@@ -277,12 +361,11 @@ func newTestableWorker(t *testing.T, rps, tmp int) (w *worker, l *mockLogger, m
 			},
 			close: closer,
 		},
-		regulator:         makeRegulator(closer, rps, 0),
-		in:                in,
-		out:               out,
-		name:              "worker:" + t.Name(),
-		maxTemporaryError: tmp,
-		manipulator:       m,
+		regulator:   makeRegulator(closer, rps, 0),
+		in:          in,
+		out:         out,
+		name:        "worker:" + t.Name(),
+		manipulator: m,
 	}
 	return
 }
@@ -311,6 +394,34 @@ func (m *mockManipulator) release(c *chunk) {
 	m.Called(c)
 }
 
+// sleepyManipulator is a manipulator which simulates request latency
+// and records when each manipulation started.
+type sleepyManipulator struct {
+	latency time.Duration
+	lock    sync.Mutex
+	t       []time.Time
+}
+
+func (m *sleepyManipulator) context(_ *chunk) context.Context {
+	return context.Background()
+}
+
+func (m *sleepyManipulator) manipulate(_ *chunk) outcome {
+	m.lock.Lock()
+	m.t = append(m.t, time.Now())
+	m.lock.Unlock()
+	time.Sleep(m.latency)
+	return finished
+}
+
+func (m *sleepyManipulator) release(_ *chunk) {}
+
+func (m *sleepyManipulator) starts() []time.Time {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.t
+}
+
 func (w *worker) expectLoopLogs(m *mockLogger) {
 	m.ExpectPrintf("incite: QueryManager(%s) %s %s", w.m.Name, w.name, "started").Once()
 	m.ExpectPrintf("incite: QueryManager(%s) %s %s", w.m.Name, w.name, "stopping...").Once()